@@ -0,0 +1,129 @@
+// Copyright (c) 2022 Target Brands, Inc. All rights reserved.
+//
+// Use of this source code is governed by the LICENSE file in this repository.
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/uber/makisu/lib/docker/image"
+)
+
+const (
+	// ociConfigMediaType represents the media type for an OCI image configuration.
+	ociConfigMediaType = "application/vnd.oci.image.config.v1+json"
+
+	// ociIndexMediaType represents the media type for an OCI image index.
+	ociIndexMediaType = "application/vnd.oci.image.index.v1+json"
+
+	// ociManifestMediaType represents the media type for an OCI image manifest.
+	ociManifestMediaType = "application/vnd.oci.image.manifest.v1+json"
+
+	// manifestListMediaType represents the media type for a Docker manifest list.
+	manifestListMediaType = "application/vnd.docker.distribution.manifest.list.v2+json"
+)
+
+type (
+	// ociDescriptor represents a reference to content within an OCI image.
+	//
+	// https://github.com/opencontainers/image-spec/blob/main/descriptor.md
+	ociDescriptor struct {
+		MediaType   string            `json:"mediaType"`
+		Digest      string            `json:"digest"`
+		Size        int64             `json:"size"`
+		Annotations map[string]string `json:"annotations,omitempty"`
+	}
+
+	// ociManifest represents an OCI image manifest.
+	//
+	// https://github.com/opencontainers/image-spec/blob/main/manifest.md
+	ociManifest struct {
+		SchemaVersion int               `json:"schemaVersion"`
+		MediaType     string            `json:"mediaType"`
+		Config        ociDescriptor     `json:"config"`
+		Layers        []ociDescriptor   `json:"layers"`
+		Annotations   map[string]string `json:"annotations,omitempty"`
+	}
+
+	// artifactLayer represents the content of a layer
+	// for an artifact pushed alongside an image.
+	artifactLayer struct {
+		// annotations for the layer
+		Annotations map[string]string
+		// content of the layer
+		Data []byte
+		// media type of the layer
+		MediaType string
+	}
+)
+
+// PushArtifact uploads an artifact with the provided layers to the
+// repository of the Docker Registry with the provided tag.
+func (c *Client) PushArtifact(tag string, layers []*artifactLayer) error {
+	logrus.Tracef("pushing artifact %s for %s", tag, c.Name)
+
+	// the artifact uses an empty configuration
+	config := []byte("{}")
+
+	manifest := &ociManifest{
+		SchemaVersion: 2,
+		MediaType:     ociManifestMediaType,
+		Config:        descriptor(ociConfigMediaType, config),
+	}
+
+	// variable to store the blobs for the artifact
+	blobs := [][]byte{config}
+
+	for _, layer := range layers {
+		d := descriptor(layer.MediaType, layer.Data)
+		d.Annotations = layer.Annotations
+
+		manifest.Layers = append(manifest.Layers, d)
+
+		blobs = append(blobs, layer.Data)
+	}
+
+	for _, blob := range blobs {
+		err := c.PushBlob(digestOf(blob), int64(len(blob)), bytes.NewReader(blob))
+		if err != nil {
+			return err
+		}
+	}
+
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+
+	return c.PushManifest(tag, ociManifestMediaType, data)
+}
+
+// artifactTag is a helper function to return the tag for an artifact
+// pushed alongside the image with the provided digest.
+//
+// i.e. "sha256:abc" with "sig" becomes "sha256-abc.sig".
+func artifactTag(digest, suffix string) string {
+	return fmt.Sprintf("%s.%s", strings.Replace(digest, ":", "-", 1), suffix)
+}
+
+// descriptor is a helper function to return
+// the descriptor for the provided content.
+func descriptor(mediaType string, data []byte) ociDescriptor {
+	return ociDescriptor{
+		MediaType: mediaType,
+		Digest:    string(digestOf(data)),
+		Size:      int64(len(data)),
+	}
+}
+
+// digestOf is a helper function to return the
+// SHA256 digest for the provided content.
+func digestOf(data []byte) image.Digest {
+	return image.Digest(fmt.Sprintf("sha256:%x", sha256.Sum256(data)))
+}
@@ -0,0 +1,53 @@
+// Copyright (c) 2022 Target Brands, Inc. All rights reserved.
+//
+// Use of this source code is governed by the LICENSE file in this repository.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestMakisu_Client_PushArtifact(t *testing.T) {
+	// setup types
+	s, store := testPushRegistry(t)
+
+	c, err := newClient("", fmt.Sprintf("%s/octocat/hello-world:latest", strings.TrimPrefix(s.URL, "http://")))
+	if err != nil {
+		t.Errorf("newClient returned err: %v", err)
+	}
+
+	err = c.PushArtifact("sha256-abc.att", []*artifactLayer{
+		{Data: []byte(`{"foo":"bar"}`), MediaType: inTotoMediaType},
+	})
+	if err != nil {
+		t.Errorf("PushArtifact returned err: %v", err)
+	}
+
+	manifest, ok := store.manifests["octocat/hello-world:sha256-abc.att"]
+	if !ok {
+		t.Errorf("PushArtifact should have pushed manifest sha256-abc.att")
+	}
+
+	if store.types["octocat/hello-world:sha256-abc.att"] != ociManifestMediaType {
+		t.Errorf("PushArtifact media type is %s", store.types["octocat/hello-world:sha256-abc.att"])
+	}
+
+	if !strings.Contains(string(manifest), string(digestOf([]byte(`{"foo":"bar"}`)))) {
+		t.Errorf("PushArtifact manifest is %s, want layer", manifest)
+	}
+
+	if len(store.blobs) != 2 {
+		t.Errorf("PushArtifact pushed %d blobs, want %d", len(store.blobs), 2)
+	}
+}
+
+func TestMakisu_artifactTag(t *testing.T) {
+	got := artifactTag("sha256:abc", "sig")
+
+	if got != "sha256-abc.sig" {
+		t.Errorf("artifactTag is %s, want %s", got, "sha256-abc.sig")
+	}
+}
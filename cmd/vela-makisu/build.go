@@ -5,9 +5,17 @@
 package main
 
 import (
+	"context"
+	"crypto"
 	"encoding/json"
 	"fmt"
+	"io"
+	"os"
 	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -22,8 +30,20 @@ type (
 	// Makisu documents their command usage:
 	// https://github.com/uber/makisu/blob/master/docs/COMMAND.md
 	Build struct {
+		// enables restricting the images the Dockerfile derives from i.e. "docker.company.com/*"
+		AllowedBaseImages []string
+		// enables adding OCI image labels derived from the Vela build metadata
+		AutoLabels bool
+		// enables deriving the tags for the image from the Vela build metadata
+		AutoTag bool
 		// enables setting build time arguments for the Dockerfile
 		BuildArgs []string
+		// used for translating the raw secrets configuration
+		BuildSecrets []*BuildSecret
+		// enables staging secrets in /run/secrets for the build without persisting them in the image
+		BuildSecretsRaw string
+		// enables setting the backend used to build the image - options: (makisu|kaniko)
+		Builder string
 		// enables setting compression on the tar file built - options: (no|speed|size|default)
 		Commit string
 		// Image compression level, could be 'no', 'speed', 'size', 'default' (default "default")
@@ -40,20 +60,42 @@ type (
 		Destination string
 		// enables setting a the absolute path to dockerfile
 		File string
+		// enables setting the time to wait for the build to exit after it is stopped (default 10s)
+		GracePeriod time.Duration
 		// enables setting the global flags
 		GlobalFlags []string
 		// Used for translating the raw http cache configuration
 		HTTPCache *HTTPCache
 		// enables setting custom http options caching
 		HTTPCacheRaw string
+		// used for translating the raw images configuration
+		Images []*Image
+		// enables building multiple images with their own context, file, tag, target, build args and pushes
+		ImagesRaw string
+		// enables setting labels for the image i.e. "<key>=<value>"
+		Labels []string
 		// enables loading a docker image into the docker daemon post build
 		Load bool
 		// enables setting a time to live for the local docker cache (default 168h0m0s)
 		LocalCacheTTL time.Duration
 		// enables setting makisu to modify files outside its internal storage directories
 		ModifyFS bool
+		// enables converting the image written to the Destination - options: (docker-tar|oci-layout|oci-tar)
+		OutputFormat string
+		// enables rendering the makisu JSON logs as readable progress
+		ParseLogs bool
+		// used for translating the policy configurations
+		Policies []*Policy
+		// enables loading a policy for the registries and tags from a file
+		PolicyFile string
+		// enables restricting the registries and tags the image is pushed to
+		PolicyRaw string
 		// enables setting copying storage from root in the storage during and after build
 		PreserveRoot bool
+		// enables writing an in-toto statement with the SLSA provenance for the build
+		ProvenancePath string
+		// enables pushing the provenance alongside the image with the tag "sha256-<digest>.att"
+		PushProvenance bool
 		// enables setting registries to push the image to
 		Pushes []string
 		// used for translating the redis cache configuration
@@ -64,12 +106,45 @@ type (
 		RegistryConfig string
 		// enables setting pushing image to alternative targets i.e. \"<registry>/<repo>:<tag>\"
 		Replicas []string
+		// enables writing a JSON report with timings, cache hits and layer sizes
+		ReportPath string
+		// enables writing a JSON file with the digest of the pushed image
+		ResultsPath string
+		// used for translating the retry configuration
+		Retry *Retry
+		// enables retrying the build when it fails with a transient error
+		RetryRaw string
+		// enables writing a software bill of materials next to the Destination - options: (spdx|cyclonedx)
+		SBOM string
+		// used for translating the raw scan configuration
+		Scan *Scan
+		// enables scanning the image written to the Destination for vulnerabilities before pushing it
+		ScanRaw string
+		// enables signing the pushed image with the key from SignKey
+		Sign bool
+		// enables setting the path to the unencrypted PEM private key for signing the image
+		SignKey string
 		// enables setting a directory for makisu to use for temp files and cached layers
 		Storage string
 		// enables setting the tag for an image
 		Tag string
 		// enables setting the target build stage to build
 		Target string
+		// enables setting the maximum duration of the build
+		Timeout time.Duration
+
+		// context for running the build commands
+		ctx context.Context
+		// base images of the Dockerfile resolved before the build for the provenance
+		materials []*ProvenanceMaterial
+		// output captured for classifying a failed build
+		output *outputTail
+		// stage and step currently running from the makisu logs
+		progress *progress
+		// report capturing the performance data for the build
+		report *Report
+		// key for signing the pushed image
+		signer crypto.Signer
 	}
 
 	// Docker represnets the "docker" prefixed flags within the
@@ -106,12 +181,37 @@ type (
 
 // buildFlags represents for config settings on the cli.
 var buildFlags = []cli.Flag{
+	&cli.StringSliceFlag{
+		EnvVars:  []string{"PARAMETER_ALLOWED_BASE_IMAGES"},
+		FilePath: string("/vela/parameters/makisu/build/allowed_base_images,/vela/secrets/makisu/build/allowed_base_images"),
+		Name:     "build.allowed-base-images",
+		Usage:    "enables restricting the images the Dockerfile derives from with glob or \"regex:\" patterns",
+	},
+	&cli.BoolFlag{
+		EnvVars:  []string{"PARAMETER_AUTO_LABELS"},
+		FilePath: string("/vela/parameters/makisu/build/auto_labels,/vela/secrets/makisu/build/auto_labels"),
+		Name:     "build.auto-labels",
+		Usage:    "enables adding OCI image labels for the revision, source, created time and build link from the Vela build",
+	},
+	&cli.BoolFlag{
+		EnvVars:  []string{"PARAMETER_AUTO_TAG"},
+		FilePath: string("/vela/parameters/makisu/build/auto_tag,/vela/secrets/makisu/build/auto_tag"),
+		Name:     "build.auto-tag",
+		Usage:    "enables deriving the tags for the image from the Vela build tag, commit and branch",
+	},
 	&cli.StringSliceFlag{
 		EnvVars:  []string{"PARAMETER_BUILD_ARGS"},
 		FilePath: string("/vela/parameters/makisu/build/build_args,/vela/secrets/makisu/build/build_args"),
 		Name:     "build.build-args",
 		Usage:    "enables setting build time arguments for the dockerfile",
 	},
+	&cli.StringFlag{
+		EnvVars:  []string{"PARAMETER_BUILDER"},
+		FilePath: string("/vela/parameters/makisu/build/builder,/vela/secrets/makisu/build/builder"),
+		Name:     "build.builder",
+		Usage:    "enables setting the backend used to build the image - options: (makisu|kaniko)",
+		Value:    makisuBuilder,
+	},
 	&cli.StringFlag{
 		EnvVars:  []string{"PARAMETER_COMMIT"},
 		FilePath: string("/vela/parameters/makisu/build/commit,/vela/secrets/makisu/build/commit"),
@@ -154,12 +254,31 @@ var buildFlags = []cli.Flag{
 		Name:     "build.file",
 		Usage:    "enables setting a the absolute path to dockerfile",
 	},
+	&cli.DurationFlag{
+		EnvVars:  []string{"PARAMETER_GRACE_PERIOD"},
+		FilePath: string("/vela/parameters/makisu/build/grace_period,/vela/secrets/makisu/build/grace_period"),
+		Name:     "build.grace-period",
+		Usage:    "enables setting the time to wait for the build to exit after it is stopped",
+		Value:    10 * time.Second,
+	},
 	&cli.StringFlag{
 		EnvVars:  []string{"PARAMETER_HTTP_CACHE", "HTTP_CACHE"},
 		FilePath: string("/vela/parameters/makisu/build/http_cache_options,/vela/secrets/makisu/build/http_cache_options"),
 		Name:     "build.http-cache-options",
 		Usage:    "enables setting custom http options caching",
 	},
+	&cli.StringFlag{
+		EnvVars:  []string{"PARAMETER_IMAGES"},
+		FilePath: string("/vela/parameters/makisu/build/images,/vela/secrets/makisu/build/images"),
+		Name:     "build.images",
+		Usage:    "enables building multiple images with their own context, file, tag, target, build args and pushes",
+	},
+	&cli.StringSliceFlag{
+		EnvVars:  []string{"PARAMETER_LABELS"},
+		FilePath: string("/vela/parameters/makisu/build/labels,/vela/secrets/makisu/build/labels"),
+		Name:     "build.labels",
+		Usage:    "enables setting labels for the image i.e. \"<key>=<value>\"",
+	},
 	&cli.BoolFlag{
 		EnvVars:  []string{"PARAMETER_LOAD"},
 		FilePath: string("/vela/parameters/makisu/build/load,/vela/secrets/makisu/build/load"),
@@ -179,6 +298,31 @@ var buildFlags = []cli.Flag{
 		Usage:    "enables setting makisu to modify files outside its internal storage directories",
 		Value:    true,
 	},
+	&cli.StringFlag{
+		EnvVars:  []string{"PARAMETER_OUTPUT_FORMAT"},
+		FilePath: string("/vela/parameters/makisu/build/output_format,/vela/secrets/makisu/build/output_format"),
+		Name:     "build.output-format",
+		Usage:    "enables converting the image written to the destination - options: (docker-tar|oci-layout|oci-tar)",
+		Value:    dockerTarFormat,
+	},
+	&cli.BoolFlag{
+		EnvVars:  []string{"PARAMETER_PARSE_LOGS"},
+		FilePath: string("/vela/parameters/makisu/build/parse_logs,/vela/secrets/makisu/build/parse_logs"),
+		Name:     "build.parse-logs",
+		Usage:    "enables rendering the makisu JSON logs as readable progress with the final error at the end",
+	},
+	&cli.StringFlag{
+		EnvVars:  []string{"PARAMETER_POLICY"},
+		FilePath: string("/vela/parameters/makisu/build/policy,/vela/secrets/makisu/build/policy"),
+		Name:     "build.policy",
+		Usage:    "enables restricting the registries and tags the image is pushed to",
+	},
+	&cli.StringFlag{
+		EnvVars:  []string{"PARAMETER_POLICY_FILE"},
+		FilePath: string("/vela/parameters/makisu/build/policy_file,/vela/secrets/makisu/build/policy_file"),
+		Name:     "build.policy-file",
+		Usage:    "enables loading a policy for the registries and tags from a file",
+	},
 	&cli.BoolFlag{
 		EnvVars:  []string{"PARAMETER_PRESERVE_ROOT"},
 		FilePath: string("/vela/parameters/makisu/build/preserve_root,/vela/secrets/makisu/build/preserve_root"),
@@ -186,6 +330,18 @@ var buildFlags = []cli.Flag{
 		Usage:    "enables setting copying storage from root in the storage during and after build",
 		Value:    true,
 	},
+	&cli.StringFlag{
+		EnvVars:  []string{"PARAMETER_PROVENANCE_PATH"},
+		FilePath: string("/vela/parameters/makisu/build/provenance_path,/vela/secrets/makisu/build/provenance_path"),
+		Name:     "build.provenance-path",
+		Usage:    "enables writing an in-toto statement with the SLSA provenance for the build",
+	},
+	&cli.BoolFlag{
+		EnvVars:  []string{"PARAMETER_PUSH_PROVENANCE"},
+		FilePath: string("/vela/parameters/makisu/build/push_provenance,/vela/secrets/makisu/build/push_provenance"),
+		Name:     "build.push-provenance",
+		Usage:    "enables pushing the provenance alongside the image",
+	},
 	&cli.StringSliceFlag{
 		EnvVars:  []string{"PARAMETER_PUSHES"},
 		FilePath: string("/vela/parameters/makisu/build/pushes,/vela/secrets/makisu/build/pushes"),
@@ -210,6 +366,55 @@ var buildFlags = []cli.Flag{
 		Name:     "build.replicas",
 		Usage:    "enables setting pushing image to alternative targets i.e. \"<registry>/<repo>:<tag>\"",
 	},
+	&cli.StringFlag{
+		EnvVars:  []string{"PARAMETER_REPORT_PATH"},
+		FilePath: string("/vela/parameters/makisu/build/report_path,/vela/secrets/makisu/build/report_path"),
+		Name:     "build.report-path",
+		Usage:    "enables writing a JSON report with the timings, cache hit ratio and layer sizes of the build",
+	},
+	&cli.StringFlag{
+		EnvVars:  []string{"PARAMETER_RESULTS_PATH"},
+		FilePath: string("/vela/parameters/makisu/build/results_path,/vela/secrets/makisu/build/results_path"),
+		Name:     "build.results-path",
+		Usage:    "enables writing a JSON file with the tag, replicas, digest and size of the pushed image",
+	},
+	&cli.StringFlag{
+		EnvVars:  []string{"PARAMETER_RETRY"},
+		FilePath: string("/vela/parameters/makisu/build/retry_options,/vela/secrets/makisu/build/retry_options"),
+		Name:     "build.retry-options",
+		Usage:    "enables retrying the build with attempts, backoff and jitter when it fails with a transient error",
+	},
+	&cli.StringFlag{
+		EnvVars:  []string{"PARAMETER_SBOM"},
+		FilePath: string("/vela/parameters/makisu/build/sbom,/vela/secrets/makisu/build/sbom"),
+		Name:     "build.sbom",
+		Usage:    "enables writing a software bill of materials next to the destination - options: (spdx|cyclonedx)",
+	},
+	&cli.StringFlag{
+		EnvVars:  []string{"PARAMETER_SCAN"},
+		FilePath: string("/vela/parameters/makisu/build/scan,/vela/secrets/makisu/build/scan"),
+		Name:     "build.scan",
+		Usage:    "enables scanning the image written to the destination for vulnerabilities before pushing it",
+	},
+	&cli.StringFlag{
+		EnvVars:  []string{"PARAMETER_SECRETS"},
+		FilePath: string("/vela/parameters/makisu/build/secrets,/vela/secrets/makisu/build/secrets"),
+		Name:     "build.secrets",
+		Usage:    "enables staging secrets in /run/secrets for the build without persisting them in the image",
+	},
+	&cli.BoolFlag{
+		EnvVars:  []string{"PARAMETER_SIGN"},
+		FilePath: string("/vela/parameters/makisu/build/sign,/vela/secrets/makisu/build/sign"),
+		Name:     "build.sign",
+		Usage:    "enables signing the pushed image with the key from sign_key",
+	},
+	&cli.StringFlag{
+		EnvVars:  []string{"PARAMETER_SIGN_KEY"},
+		FilePath: string("/vela/parameters/makisu/build/sign_key,/vela/secrets/makisu/build/sign_key"),
+		Name:     "build.sign-key",
+		Usage:    "enables setting the path to the unencrypted PEM private key for signing the image",
+		Value:    defaultSignKey,
+	},
 	&cli.StringFlag{
 		EnvVars:  []string{"PARAMETER_STORAGE"},
 		FilePath: string("/vela/parameters/makisu/build/storage,/vela/secrets/makisu/build/storage"),
@@ -228,159 +433,527 @@ var buildFlags = []cli.Flag{
 		Name:     "build.target",
 		Usage:    "enables setting the target build stage to build",
 	},
+	&cli.DurationFlag{
+		EnvVars:  []string{"PARAMETER_TIMEOUT"},
+		FilePath: string("/vela/parameters/makisu/build/timeout,/vela/secrets/makisu/build/timeout"),
+		Name:     "build.timeout",
+		Usage:    "enables setting the maximum duration of the build",
+	},
 }
 
 // Command formats and outputs the Build command from
 // the provided configuration to build a Docker image.
-func (b *Build) Command() (*exec.Cmd, error) {
-	logrus.Trace("creating makisu build command from plugin configuration")
+//
+// The returned function removes any files created for the command.
+func (b *Build) Command() (*exec.Cmd, func(), error) {
+	// capture the backend for building the image
+	builder, err := newBuilder(b.Builder)
+	if err != nil {
+		return nil, func() {}, err
+	}
 
-	// variable to store flags for command
-	var flags []string
+	return b.command(builder)
+}
+
+// command is a helper function to create the command run
+// by the provided backend for building the image.
+//
+// The same command is run for the build and printed in dry run mode.
+func (b *Build) command(builder Builder) (*exec.Cmd, func(), error) {
+	build := *b
+
+	// check if the image is scanned before it is pushed
+	if b.Scan != nil {
+		// build the image to the destination without pushing it
+		build.Pushes, build.Replicas = nil, nil
+	}
 
-	// add any global flags that may have been set
-	flags = append(flags, b.GlobalFlags...)
+	return builder.Command(&build)
+}
 
-	// check if BuildArgs is provided
-	if len(b.BuildArgs) > 0 {
-		for _, arg := range b.BuildArgs {
-			// add flag for BuildArgs from provided build command
-			flags = append(flags, "--build-arg", arg)
+// push is a helper function to run the command for pushing
+// the image in the tarball at the provided path.
+func (b *Build) push(path string) error {
+	// capture the backend for pushing the image
+	builder, err := newBuilder(b.Builder)
+	if err != nil {
+		return err
+	}
+
+	// create the push command for the tarball
+	cmd, err := builder.Push(b, path)
+	if err != nil {
+		return err
+	}
+
+	// run the push command for the tarball
+	return execCmdContext(b.context(), cmd, b.GracePeriod)
+}
+
+// execContext is a helper function to create the context for the step
+// which is cancelled by termination signals or when the Timeout expires.
+func (b *Build) execContext() (context.Context, context.CancelFunc) {
+	// forward termination signals to the build
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+
+	// check if Timeout is not provided
+	if b.Timeout <= 0 {
+		return ctx, stop
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, b.Timeout)
+
+	return ctx, func() {
+		cancel()
+		stop()
+	}
+}
+
+// Exec formats and runs the commands for building a Docker image.
+func (b *Build) Exec() error {
+	logrus.Trace("running build with provided configuration")
+
+	// capture the backend for building the image
+	builder, err := newBuilder(b.Builder)
+	if err != nil {
+		return err
+	}
+
+	// check if the context is shared with other images in the step
+	ctx := b.ctx
+	if ctx == nil {
+		var cancel context.CancelFunc
+
+		ctx, cancel = b.execContext()
+		defer cancel()
+	}
+
+	// stage the secrets for the build
+	cleanup, err := b.StageSecrets()
+	defer cleanup()
+
+	if err != nil {
+		return err
+	}
+
+	b.ctx = ctx
+	b.progress = new(progress)
+
+	// check if the image is scanned before it is pushed
+	if b.Scan != nil {
+		logrus.Info("building image to the destination without pushing it until it is scanned")
+	}
+
+	// check if provenance should be written
+	if len(b.ProvenancePath) > 0 || b.PushProvenance {
+		// resolve the digests of the base images before they are built from
+		b.materials = b.resolveMaterials()
+	}
+
+	started := time.Now()
+
+	// check if the build should be retried
+	if b.Retry.attempts() > 1 {
+		b.output = new(outputTail)
+	}
+
+	for attempt := 1; ; attempt++ {
+		// run the build with the backend
+		err = b.run(builder, attempt)
+		if err == nil {
+			break
+		}
+
+		// check if the build was cancelled
+		if ctx.Err() != nil {
+			// capture the stage running when the build was cancelled
+			running := b.progress.String()
+			if len(running) == 0 {
+				running = "unknown"
+			}
+
+			logrus.Errorf("build was cancelled (%v) while running %s", ctx.Err(), running)
+
+			return err
 		}
+
+		// check if the build has attempts remaining
+		if attempt >= b.Retry.attempts() {
+			return err
+		}
+
+		// check if the build failed with a transient error
+		if !retryable(err, b.output.String()) {
+			logrus.Error("build failed with a non-transient error and will not be retried")
+
+			return err
+		}
+
+		delay := b.Retry.Delay(attempt)
+
+		// the storage directory is reused to avoid rebuilding cached layers
+		logrus.Warnf("build attempt %d/%d failed with a transient error, retrying in %s",
+			attempt, b.Retry.attempts(), delay)
+
+		sleep(delay)
+
+		b.output.Reset()
 	}
 
-	// check if Commit is provided
-	if len(b.Commit) > 0 {
-		// add flag for Commit from provided build command
-		flags = append(flags, "--commit", b.Commit)
+	// check if the image is scanned before it is pushed
+	if b.Scan != nil {
+		err = b.Gate()
+		if err != nil {
+			return err
+		}
 	}
 
-	// check if Compression is provided
-	if len(b.Compression) > 0 {
-		// add flag for Compression from provided build command
-		flags = append(flags, "--compression", b.Compression)
+	finished := time.Now()
+
+	// check if a software bill of materials should be written
+	if len(b.SBOM) > 0 {
+		err = b.WriteSBOM()
+		if err != nil {
+			return err
+		}
 	}
 
-	// check if DenyList is provided
-	if len(b.DenyList) > 0 {
-		for _, d := range b.DenyList {
-			// add flag for DenyList from provided build command
-			flags = append(flags, "--blacklist", d)
+	// check if the image should be converted to another format
+	if len(b.OutputFormat) > 0 && b.OutputFormat != dockerTarFormat {
+		err = b.WriteOutput()
+		if err != nil {
+			return err
 		}
 	}
 
-	// add flags for Docker configuration
-	flags = append(flags, b.Docker.Flags()...)
+	// check if results, signatures or provenance should be written
+	if len(b.ResultsPath) == 0 && len(b.ProvenancePath) == 0 && !b.PushProvenance && !b.Sign {
+		return nil
+	}
 
-	// check if Destination is provided
-	if len(b.Destination) > 0 {
-		// add flag for Destination from provided build command
-		flags = append(flags, "--dest", b.Destination)
+	// capture the results for the pushed image
+	results, err := b.Results()
+	if err != nil {
+		return err
 	}
 
-	// check if File is provided
-	if len(b.File) > 0 {
-		// add flag for File from provided build command
-		flags = append(flags, "--file", b.File)
+	// check if results should be written
+	if len(b.ResultsPath) > 0 {
+		err = results.Write(b.ResultsPath)
+		if err != nil {
+			return err
+		}
 	}
 
-	// add flags for HTTPCache configuration
-	flags = append(flags, b.HTTPCache.Flags()...)
+	// check if the image should be signed
+	if b.Sign {
+		err = b.SignImages(results)
+		if err != nil {
+			return err
+		}
+	}
 
-	// check if Load is provided
-	if b.Load {
-		// add flag for Load from provided build command
-		flags = append(flags, "--load")
+	// check if provenance should be written
+	if len(b.ProvenancePath) == 0 && !b.PushProvenance {
+		return nil
 	}
 
-	// check if LocalCacheTTL is provided
-	if b.LocalCacheTTL > 0 {
-		// add flag for LocalCacheTTL from provided build command
-		flags = append(flags, "--local-cache-ttl", b.LocalCacheTTL.String())
+	// capture the provenance for the build
+	provenance, err := b.Provenance(results, builderVersion(builder), started, finished)
+	if err != nil {
+		return err
 	}
 
-	// check if ModifyFS is provided
-	if b.ModifyFS {
-		// add flag for ModifyFS from provided build command
-		flags = append(flags, "--modifyfs")
+	// check if the provenance should be written to the workspace
+	if len(b.ProvenancePath) > 0 {
+		err = provenance.Write(b.ProvenancePath)
+		if err != nil {
+			return err
+		}
 	}
 
-	// check if PreserveRoot is provided
-	if b.PreserveRoot {
-		// add flag for PreserveRoot from provided build command
-		flags = append(flags, "--preserve-root")
+	// check if the provenance should be pushed alongside the image
+	if b.PushProvenance {
+		return provenance.Push(b.RegistryConfig, results)
 	}
 
-	// check if Pushes is provided
-	if len(b.Pushes) > 0 {
-		for _, p := range b.Pushes {
-			// add flag for Pushes from provided build command
-			flags = append(flags, "--push", p)
+	return nil
+}
+
+// run is a helper function to run a single attempt
+// of the build with the provided backend.
+func (b *Build) run(builder Builder, attempt int) error {
+	// check if a report should be written
+	if len(b.ReportPath) > 0 {
+		b.report = &Report{
+			Attempt: attempt,
+			Tag:     b.Tag,
+		}
+	}
+
+	started := time.Now()
+
+	// run the build with the backend
+	err := builder.Exec(b)
+
+	// check if a report should be written
+	if b.report != nil {
+		b.report.Finish(started, time.Now(), err)
+
+		// write the report even when the build failed
+		rErr := b.report.Write(b.ReportPath)
+		if rErr != nil {
+			logrus.Errorf("unable to write report: %v", rErr)
 		}
 	}
 
-	redisFlags, err := b.RedisCache.Flags()
+	return err
+}
+
+// capture is a helper function to record the output of
+// the provided command for classifying the build.
+func (b *Build) capture(cmd *exec.Cmd) {
+	// check if the output should be captured
+	if b.output == nil {
+		return
+	}
+
+	// check if command stdout is provided
+	if cmd.Stdout == nil {
+		cmd.Stdout = os.Stdout
+	}
+
+	// check if command stderr is provided
+	if cmd.Stderr == nil {
+		cmd.Stderr = os.Stderr
+	}
+
+	// a shared writer must only be written to by one goroutine at a time
+	if cmd.Stdout == cmd.Stderr {
+		w := io.MultiWriter(cmd.Stdout, b.output)
+
+		cmd.Stdout = w
+		cmd.Stderr = w
+
+		return
+	}
+
+	cmd.Stdout = io.MultiWriter(cmd.Stdout, b.output)
+	cmd.Stderr = io.MultiWriter(cmd.Stderr, b.output)
+}
+
+// context is a helper function to return the
+// context for running the build commands.
+func (b *Build) context() context.Context {
+	// check if the context is provided
+	if b.ctx == nil {
+		return context.Background()
+	}
+
+	return b.ctx
+}
+
+// Dockerfile outputs the path to the Dockerfile for the build.
+func (b *Build) Dockerfile() string {
+	// check if File is provided
+	if len(b.File) > 0 {
+		// check if File is absolute
+		if filepath.IsAbs(b.File) {
+			return b.File
+		}
+
+		// a relative File is resolved against the Context like makisu
+		return filepath.Join(b.Context, b.File)
+	}
+
+	return filepath.Join(b.Context, "Dockerfile")
+}
+
+// ImageLabels outputs the labels to add to the image with
+// the provided labels taking precedence over derived labels.
+func (b *Build) ImageLabels() (map[string]string, error) {
+	// variable to store labels for the image
+	labels := make(map[string]string)
+
+	// check if labels should be derived from the build metadata
+	if b.AutoLabels {
+		labels = autoLabels()
+	}
+
+	// parse the provided labels
+	provided, err := parseLabels(b.Labels)
 	if err != nil {
 		return nil, err
 	}
 
-	// add flags for RedisCache configuration
-	flags = append(flags, redisFlags...)
+	for key, value := range provided {
+		labels[key] = value
+	}
+
+	return labels, nil
+}
 
-	// check if RegistryConfig is provided
-	if len(b.RegistryConfig) > 0 {
-		// add flag for RegistryConfig from provided build command
-		flags = append(flags, "--registry-config", b.RegistryConfig)
+// References outputs the full references
+// the image is pushed to by the build.
+func (b *Build) References() []string {
+	// variable to store references for the image
+	var references []string
+
+	for _, p := range b.Pushes {
+		references = append(references, reference(p, b.Tag))
 	}
 
-	// check if Replicas is provided
-	if len(b.Replicas) > 0 {
-		for _, r := range b.Replicas {
-			// add flag for Replicas from provided build command
-			flags = append(flags, "--replica", r)
-		}
+	// check if the image is pushed to any registries
+	if len(references) == 0 {
+		return nil
 	}
 
-	// check if Tag is provided
-	if len(b.Storage) > 0 {
-		// add flag for Tag from provided build command
-		flags = append(flags, "--storage", b.Storage)
+	return append(references, b.Replicas...)
+}
+
+// reference is a helper function to return the
+// full reference for a tag in the provided registry.
+func reference(registry, tag string) string {
+	// check if the tag already contains the registry
+	if strings.HasPrefix(tag, registry+"/") {
+		return tag
 	}
 
-	// check if Tag is provided
-	if len(b.Tag) > 0 {
-		// add flag for Tag from provided build command
-		flags = append(flags, "--tag", b.Tag)
+	return fmt.Sprintf("%s/%s", registry, tag)
+}
+
+// Results captures the digest and size of the
+// image pushed to each registry by the build.
+func (b *Build) Results() (*Results, error) {
+	logrus.Trace("capturing results for the build")
+
+	results := &Results{
+		Images:   []*ImageResult{},
+		Replicas: b.Replicas,
+		Tag:      b.Tag,
 	}
 
-	// check if Target is provided
-	if len(b.Target) > 0 {
-		// add flag for Target from provided build command
-		flags = append(flags, "--target", b.Target)
+	// check if the image was pushed to any registries
+	if len(b.Pushes) == 0 {
+		logrus.Warn("no pushes provided - unable to capture digest for the image")
+
+		return results, nil
 	}
 
-	// add the required directory param
-	flags = append(flags, b.Context)
+	for _, reference := range b.References() {
+		// create the client for the registry
+		client, err := newClient(b.RegistryConfig, reference)
+		if err != nil {
+			return nil, err
+		}
 
-	// nolint: gosec // this functionality is not exploitable the way
-	// the plugin accepts configuration
-	return exec.Command(_makisu, append([]string{buildAction}, flags...)...), nil
+		// pull the manifest for the pushed image
+		manifest, descriptor, err := client.Manifest()
+		if err != nil {
+			return nil, err
+		}
+
+		results.Images = append(results.Images, &ImageResult{
+			Digest: string(descriptor.Digest),
+			Pinned: fmt.Sprintf(
+				"%s/%s@%s",
+				client.Name.GetRegistry(),
+				client.Name.GetRepository(),
+				descriptor.Digest,
+			),
+			Reference: reference,
+			Size:      manifestSize(manifest),
+		})
+	}
+
+	// capture the digest and size for the tag
+	results.Digest = results.Images[0].Digest
+	results.Size = results.Images[0].Size
+
+	logrus.Infof("pushed image %s with digest %s", results.Images[0].Reference, results.Digest)
+
+	return results, nil
 }
 
-// Exec formats and runs the commands for building a Docker image.
-func (b *Build) Exec() error {
-	logrus.Trace("running build with provided configuration")
+// Secrets outputs the sensitive values for configuring the Build.
+func (b *Build) Secrets() []string {
+	// variable to store sensitive values
+	var values []string
 
-	// create the build command for the file
-	cmd, err := b.Command()
-	if err != nil {
-		return err
+	// check if HTTPCache is provided
+	if b.HTTPCache != nil {
+		values = append(values, b.HTTPCache.Secrets()...)
 	}
 
-	// run the build command for the file
-	err = execCmd(cmd)
-	if err != nil {
-		return err
+	// check if RedisCache is provided
+	if b.RedisCache != nil {
+		values = append(values, b.RedisCache.Secrets()...)
+	}
+
+	for _, secret := range b.BuildSecrets {
+		// check if the value for the secret is loaded
+		if len(secret.value) > 0 {
+			values = append(values, secret.value)
+		}
+	}
+
+	return values
+}
+
+// Tags derives the tags for the image from the Vela build metadata.
+//
+// The primary tag is set for the Tag unless the Tag already contains
+// a tag and the remaining tags are added to the Replicas for each push.
+//
+// The remaining tags require pushes since the image is only tagged
+// with the primary tag when it is not pushed.
+func (b *Build) Tags() error {
+	logrus.Trace("deriving tags for the build")
+
+	// derive the tags from the build metadata
+	tags := autoTags()
+	if len(tags) == 0 {
+		return fmt.Errorf("no tags derived from VELA_BUILD_TAG, VELA_BUILD_COMMIT or VELA_BUILD_BRANCH")
+	}
+
+	// split the tag into the repository and tag
+	repo, tag := splitTag(b.Tag)
+
+	// set the primary tag when no tag is provided
+	if len(tag) == 0 {
+		tag, tags = tags[0], tags[1:]
+	}
+
+	b.Tag = fmt.Sprintf("%s:%s", repo, tag)
+
+	logrus.Infof("using tag %s for the image", b.Tag)
+
+	// variable to store the tags added as replicas
+	var replicas []string
+
+	for _, t := range tags {
+		// skip the primary tag
+		if t != tag {
+			replicas = append(replicas, t)
+		}
+	}
+
+	// verify the derived tags are pushed
+	//
+	// makisu only tags the image with the primary tag when it is not pushed
+	if len(replicas) > 0 && len(b.Pushes) == 0 {
+		return fmt.Errorf("auto_tag derived the tags %s for the image which require pushes",
+			strings.Join(replicas, ", "))
+	}
+
+	for _, t := range replicas {
+		for _, p := range b.Pushes {
+			replica := reference(p, fmt.Sprintf("%s:%s", repo, t))
+
+			logrus.Infof("adding replica %s for the image", replica)
+
+			b.Replicas = append(b.Replicas, replica)
+		}
 	}
 
 	return nil
@@ -395,6 +968,7 @@ func (b *Build) Unmarshal() error {
 	b.Docker = &Docker{}
 	b.HTTPCache = &HTTPCache{}
 	b.RedisCache = &RedisCache{}
+	b.Retry = &Retry{}
 
 	// check if any docker options were passed
 	if len(b.DockerRaw) > 0 {
@@ -432,6 +1006,70 @@ func (b *Build) Unmarshal() error {
 		}
 	}
 
+	// check if any images were passed
+	if len(b.ImagesRaw) > 0 {
+		// cast raw images into bytes
+		images := []byte(b.ImagesRaw)
+
+		// serialize raw images into expected Image type
+		err := json.Unmarshal(images, &b.Images)
+		if err != nil {
+			return err
+		}
+	}
+
+	// check if any secrets were passed
+	if len(b.BuildSecretsRaw) > 0 {
+		// cast raw secrets into bytes
+		buildSecrets := []byte(b.BuildSecretsRaw)
+
+		// serialize raw secrets into expected BuildSecret type
+		err := json.Unmarshal(buildSecrets, &b.BuildSecrets)
+		if err != nil {
+			return err
+		}
+
+		// ignore the staged secrets when creating the image layers
+		b.DenyList = append(b.DenyList, secretsDir)
+	}
+
+	// check if any scan options were passed
+	if len(b.ScanRaw) > 0 {
+		// block the push for critical vulnerabilities and images which can not be scanned by default
+		b.Scan = &Scan{FailOnUnknown: true, Severity: criticalSeverity}
+
+		// serialize raw scan options into expected Scan type
+		err := json.Unmarshal([]byte(b.ScanRaw), b.Scan)
+		if err != nil {
+			return err
+		}
+	}
+
+	// check if any retry options were passed
+	if len(b.RetryRaw) > 0 {
+		// cast raw retry options into bytes
+		retryOpts := []byte(b.RetryRaw)
+
+		// serialize raw retry options into expected Retry type
+		err := json.Unmarshal(retryOpts, &b.Retry)
+		if err != nil {
+			return err
+		}
+	}
+
+	// load the policies restricting the registries and tags
+	policies, err := loadPolicies(b.PolicyRaw, b.PolicyFile)
+	if err != nil {
+		return err
+	}
+
+	b.Policies = policies
+
+	// check if tags should be derived from the build metadata
+	if b.AutoTag && len(b.Tag) > 0 {
+		return b.Tags()
+	}
+
 	return nil
 }
 
@@ -439,16 +1077,192 @@ func (b *Build) Unmarshal() error {
 func (b *Build) Validate() error {
 	logrus.Trace("validating build plugin configuration")
 
+	// verify builder is supported
+	_, err := newBuilder(b.Builder)
+	if err != nil {
+		return err
+	}
+
 	// verify tag are provided
 	if len(b.Context) == 0 {
 		return fmt.Errorf("no build context provided")
 	}
 
 	// verify tag are provided
-	if len(b.Tag) == 0 {
+	if len(b.Tag) == 0 && len(b.Images) == 0 {
 		return fmt.Errorf("no build tag provided")
 	}
 
+	for _, image := range b.Images {
+		// verify image is valid
+		err = image.Validate()
+		if err != nil {
+			return err
+		}
+	}
+
+	// verify the report can be derived from the logs
+	if len(b.ReportPath) > 0 && b.Builder == kanikoBuilder {
+		return fmt.Errorf("report_path requires the makisu builder")
+	}
+
+	// check if a software bill of materials should be written
+	if len(b.SBOM) > 0 {
+		// verify the format is supported
+		if b.SBOM != spdxFormat && b.SBOM != cycloneDXFormat {
+			return fmt.Errorf("invalid sbom format provided: %s (options: %s|%s)", b.SBOM, spdxFormat, cycloneDXFormat)
+		}
+
+		// verify the image is written to a tarball
+		if len(b.Destination) == 0 {
+			return fmt.Errorf("sbom requires a destination for the image")
+		}
+	}
+
+	// check if the image should be converted to another format
+	if len(b.OutputFormat) > 0 && b.OutputFormat != dockerTarFormat {
+		// verify the format is supported
+		if b.OutputFormat != ociLayoutFormat && b.OutputFormat != ociTarFormat {
+			return fmt.Errorf("invalid output format provided: %s (options: %s|%s|%s)",
+				b.OutputFormat, dockerTarFormat, ociLayoutFormat, ociTarFormat)
+		}
+
+		// verify the image is written to a tarball
+		if len(b.Destination) == 0 {
+			return fmt.Errorf("output_format requires a destination for the image")
+		}
+
+		// verify the layout is not written over the tarball
+		if b.OutputPath() == b.Destination && b.OutputFormat == ociLayoutFormat {
+			return fmt.Errorf("output_format %s requires a destination with an extension i.e. image.tar", ociLayoutFormat)
+		}
+	}
+
+	// check if the image should be scanned
+	if b.Scan != nil {
+		// verify scan options are valid
+		err = b.Scan.Validate()
+		if err != nil {
+			return err
+		}
+
+		// verify the image is written to a tarball
+		if len(b.Destination) == 0 {
+			return fmt.Errorf("scan requires a destination for the image")
+		}
+
+		// verify the scanned image can be pushed from the tarball
+		if len(b.Pushes) > 0 && b.Builder == kanikoBuilder {
+			return fmt.Errorf("scan with pushes requires the makisu builder")
+		}
+	}
+
+	// verify the image is pushed for the provenance
+	if b.PushProvenance && len(b.Pushes) == 0 && len(b.Images) == 0 {
+		return fmt.Errorf("push_provenance requires pushes for the image")
+	}
+
+	// check if the image should be signed
+	if b.Sign {
+		// verify the image is pushed for the signature
+		if len(b.Pushes) == 0 && len(b.Images) == 0 {
+			return fmt.Errorf("sign requires pushes for the image")
+		}
+
+		// load the key to fail before building the image
+		b.signer, err = loadSigner(b.SignKey)
+		if err != nil {
+			return err
+		}
+	}
+
+	// variable to store the ids of the secrets
+	ids := make(map[string]bool)
+
+	for _, secret := range b.BuildSecrets {
+		// verify secret is valid
+		err = secret.Validate()
+		if err != nil {
+			return err
+		}
+
+		// verify the secret is staged once
+		if ids[secret.ID] {
+			return fmt.Errorf("duplicate secret id provided: %s", secret.ID)
+		}
+
+		ids[secret.ID] = true
+
+		// capture the value for the secret
+		err = secret.Load()
+		if err != nil {
+			return err
+		}
+	}
+
+	// variable to store the build args for the build and images
+	args := append([]string{}, b.BuildArgs...)
+
+	for _, image := range b.Images {
+		args = append(args, image.BuildArgs...)
+	}
+
+	// verify the secrets are not provided as build args
+	err = b.verifyBuildArgs(args)
+	if err != nil {
+		return err
+	}
+
+	// verify labels are valid
+	_, err = parseLabels(b.Labels)
+	if err != nil {
+		return err
+	}
+
+	// check if retry options are provided
+	if b.Retry != nil {
+		// verify retry options are valid
+		err = b.Retry.Validate()
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, policy := range b.Policies {
+		// verify policy is valid
+		err = policy.Validate()
+		if err != nil {
+			return err
+		}
+	}
+
+	// variable to store the builds to verify
+	builds := []*Build{b}
+
+	// check if multiple images are provided
+	if len(b.Images) > 0 {
+		builds, err = b.Builds()
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, build := range builds {
+		// verify the Dockerfile for the build
+		err = build.Preflight()
+		if err != nil {
+			return err
+		}
+
+		for _, policy := range b.Policies {
+			// verify the build is allowed by the policy
+			err = policy.Verify(build)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
 	// verify tag are provided
 	if len(b.Pushes) == 0 {
 		logrus.Warn("dry run mode is enabled")
@@ -507,6 +1321,23 @@ func (h *HTTPCache) Flags() []string {
 	return flags
 }
 
+// Secrets outputs the sensitive values for configuring a http cache.
+func (h *HTTPCache) Secrets() []string {
+	// variable to store sensitive values
+	var values []string
+
+	for _, header := range h.Headers {
+		parts := strings.SplitN(header, ":", 2)
+
+		// check if the header contains a sensitive value
+		if len(parts) == 2 && sensitiveHeader.MatchString(parts[0]) {
+			values = append(values, strings.TrimSpace(parts[1]))
+		}
+	}
+
+	return values
+}
+
 // Flags formats and outputs the flags for
 // configuring a redis cache.
 func (r *RedisCache) Flags() ([]string, error) {
@@ -541,3 +1372,8 @@ func (r *RedisCache) Flags() ([]string, error) {
 
 	return flags, nil
 }
+
+// Secrets outputs the sensitive values for configuring a redis cache.
+func (r *RedisCache) Secrets() []string {
+	return []string{r.Password}
+}
@@ -5,10 +5,17 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
 	"os/exec"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/spf13/afero"
 )
 
 func TestMakisu_Build_Command(t *testing.T) {
@@ -79,7 +86,7 @@ func TestMakisu_Build_Command(t *testing.T) {
 		".",
 	)
 
-	got, _ := b.Command()
+	got, _, _ := b.Command()
 	if !reflect.DeepEqual(got, want) {
 		t.Errorf("Flag is %v, want %v", got, want)
 	}
@@ -99,6 +106,390 @@ func TestMakisu_Build_Exec_Error(t *testing.T) {
 	}
 }
 
+func TestMakisu_Build_Exec_Report(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	// setup types
+	b := &Build{
+		Docker:     &Docker{},
+		HTTPCache:  &HTTPCache{},
+		ParseLogs:  true,
+		RedisCache: &RedisCache{},
+		ReportPath: "reports/makisu.json",
+		Tag:        "octocat/hello-world:latest",
+	}
+
+	err := b.Exec()
+	if err == nil {
+		t.Errorf("Exec should have returned err")
+	}
+
+	data, err := afero.ReadFile(appFS, "reports/makisu.json")
+	if err != nil {
+		t.Errorf("ReadFile returned err: %v", err)
+	}
+
+	got := new(Report)
+
+	err = json.Unmarshal(data, got)
+	if err != nil {
+		t.Errorf("Unmarshal returned err: %v", err)
+	}
+
+	if got.ExitCode != 1 || len(got.Error) == 0 || got.Tag != b.Tag {
+		t.Errorf("Exec report is %+v, want failed report for %s", got, b.Tag)
+	}
+}
+
+// testBuilder represents a builder failing
+// with the provided errors for testing.
+type testBuilder struct {
+	errs   []error
+	execs  int
+	pushes []string
+}
+
+func (t *testBuilder) Command(*Build) (*exec.Cmd, func(), error) {
+	return exec.Command("echo"), func() {}, nil
+}
+
+func (t *testBuilder) Exec(*Build) error {
+	t.execs++
+
+	// check if an error remains for the attempt
+	if len(t.errs) < t.execs {
+		return nil
+	}
+
+	return t.errs[t.execs-1]
+}
+
+func (t *testBuilder) Push(b *Build, path string) (*exec.Cmd, error) {
+	t.pushes = append(t.pushes, path)
+
+	return exec.Command("echo", b.Tag), nil
+}
+
+func (t *testBuilder) Version() *exec.Cmd {
+	return exec.Command("echo")
+}
+
+func TestMakisu_Build_Exec_Retry(t *testing.T) {
+	// setup types
+	sleep = func(time.Duration) {}
+
+	defer func() {
+		sleep = time.Sleep
+	}()
+
+	// setup tests
+	tests := []struct {
+		errs    []error
+		retry   *Retry
+		execs   int
+		failure bool
+	}{
+		{ // retried transient errors
+			errs:    []error{errors.New("connection reset by peer"), errors.New("503 Service Unavailable")},
+			retry:   &Retry{Attempts: 3, Backoff: "10s"},
+			execs:   3,
+			failure: false,
+		},
+		{ // no attempts remaining
+			errs:    []error{errors.New("connection reset by peer"), errors.New("connection reset by peer")},
+			retry:   &Retry{Attempts: 2},
+			execs:   2,
+			failure: true,
+		},
+		{ // not retried fatal errors
+			errs:    []error{errors.New("unsupported directive")},
+			retry:   &Retry{Attempts: 3},
+			execs:   1,
+			failure: true,
+		},
+		{ // not retried without retry options
+			errs:    []error{errors.New("connection reset by peer")},
+			retry:   nil,
+			execs:   1,
+			failure: true,
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		builder := &testBuilder{errs: test.errs}
+		builders["test"] = builder
+
+		b := &Build{
+			Builder: "test",
+			Retry:   test.retry,
+		}
+
+		err := b.Exec()
+
+		if test.failure && err == nil {
+			t.Errorf("Exec should have returned err")
+		}
+
+		if !test.failure && err != nil {
+			t.Errorf("Exec returned err: %v", err)
+		}
+
+		if builder.execs != test.execs {
+			t.Errorf("Exec ran %d attempts, want %d", builder.execs, test.execs)
+		}
+	}
+
+	delete(builders, "test")
+}
+
+// testTimeoutBuilder represents a builder
+// waiting for the build to be cancelled.
+type testTimeoutBuilder struct {
+	testBuilder
+}
+
+func (t *testTimeoutBuilder) Exec(b *Build) error {
+	t.execs++
+
+	b.progress.Record(&logEntry{Msg: "* Stage 1/1 : FROM alpine"})
+	b.progress.Record(&logEntry{Msg: "* Step 2/3 (commit) : RUN sleep 60"})
+
+	<-b.context().Done()
+
+	return b.context().Err()
+}
+
+func TestMakisu_Build_Exec_Timeout(t *testing.T) {
+	// setup types
+	builder := new(testTimeoutBuilder)
+	builders["test"] = builder
+
+	defer delete(builders, "test")
+
+	b := &Build{
+		Builder: "test",
+		Retry:   &Retry{Attempts: 3},
+		Timeout: 100 * time.Millisecond,
+	}
+
+	err := b.Exec()
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Exec returned err %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	if builder.execs != 1 {
+		t.Errorf("Exec ran %d attempts, want 1", builder.execs)
+	}
+
+	want := "stage 1/1 (FROM alpine), step 2/3 (RUN sleep 60)"
+
+	if b.progress.String() != want {
+		t.Errorf("Exec progress is %s, want %s", b.progress.String(), want)
+	}
+}
+
+func TestMakisu_Build_Dockerfile(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		build *Build
+		want  string
+	}{
+		{build: &Build{Context: "."}, want: "Dockerfile"},
+		{build: &Build{Context: "app"}, want: "app/Dockerfile"},
+		{build: &Build{Context: "app", File: "/workspace/Dockerfile.prod"}, want: "/workspace/Dockerfile.prod"},
+		{build: &Build{Context: "app", File: "Dockerfile.prod"}, want: "app/Dockerfile.prod"},
+		{build: &Build{Context: ".", File: "web/Dockerfile.prod"}, want: "web/Dockerfile.prod"},
+	}
+
+	// run tests
+	for _, test := range tests {
+		got := test.build.Dockerfile()
+
+		if got != test.want {
+			t.Errorf("Dockerfile is %s, want %s", got, test.want)
+		}
+	}
+}
+
+func TestMakisu_Build_ImageLabels(t *testing.T) {
+	// setup types
+	t.Setenv("VELA_BUILD_COMMIT", "b0bb040e6a6d71ddf98684349c42d36fa6c539ad")
+
+	b := &Build{
+		AutoLabels: true,
+		Labels:     []string{"org.opencontainers.image.revision=foo", "team=octocat"},
+	}
+
+	got, err := b.ImageLabels()
+	if err != nil {
+		t.Errorf("ImageLabels returned err: %v", err)
+	}
+
+	if got["org.opencontainers.image.revision"] != "foo" || got["team"] != "octocat" {
+		t.Errorf("ImageLabels is %v, want provided labels to take precedence", got)
+	}
+
+	if len(got["org.opencontainers.image.created"]) == 0 {
+		t.Errorf("ImageLabels is %v, want derived labels", got)
+	}
+}
+
+func TestMakisu_Build_References(t *testing.T) {
+	// setup types
+	b := &Build{
+		Pushes:   []string{"index.docker.io", "registry.company.com"},
+		Replicas: []string{"index.docker.io/octocat/hello-world:1"},
+		Tag:      "octocat/hello-world:latest",
+	}
+
+	want := []string{
+		"index.docker.io/octocat/hello-world:latest",
+		"registry.company.com/octocat/hello-world:latest",
+		"index.docker.io/octocat/hello-world:1",
+	}
+
+	got := b.References()
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("References is %v, want %v", got, want)
+	}
+}
+
+func TestMakisu_Build_Results(t *testing.T) {
+	// setup types
+	s := testRegistry(t)
+
+	host := strings.TrimPrefix(s.URL, "http://")
+
+	b := &Build{
+		Pushes:   []string{host},
+		Replicas: []string{host + "/octocat/hello-world:1"},
+		Tag:      "octocat/hello-world:latest",
+	}
+
+	got, err := b.Results()
+	if err != nil {
+		t.Errorf("Results returned err: %v", err)
+	}
+
+	if got.Digest != testManifestDigest || got.Size != 1100 || len(got.Images) != 2 {
+		t.Errorf("Results is %+v, want digest %s", got, testManifestDigest)
+	}
+
+	if got.Images[0].Pinned != host+"/octocat/hello-world@"+testManifestDigest {
+		t.Errorf("Results pinned is %s, want pinned reference", got.Images[0].Pinned)
+	}
+}
+
+func TestMakisu_Build_Results_NoPushes(t *testing.T) {
+	// setup types
+	b := &Build{
+		Tag: "octocat/hello-world:latest",
+	}
+
+	got, err := b.Results()
+	if err != nil {
+		t.Errorf("Results returned err: %v", err)
+	}
+
+	if len(got.Digest) > 0 || len(got.Images) > 0 {
+		t.Errorf("Results is %+v, want no digest", got)
+	}
+}
+
+func TestMakisu_Build_Tags(t *testing.T) {
+	// setup types
+	t.Setenv("VELA_BUILD_TAG", "v1.2.3")
+	t.Setenv("VELA_BUILD_COMMIT", "b0bb040e6a6d71ddf98684349c42d36fa6c539ad")
+
+	b := &Build{
+		AutoTag: true,
+		Pushes:  []string{"index.docker.io"},
+		Tag:     "octocat/hello-world",
+	}
+
+	want := &Build{
+		AutoTag: true,
+		Pushes:  []string{"index.docker.io"},
+		Replicas: []string{
+			"index.docker.io/octocat/hello-world:1.2",
+			"index.docker.io/octocat/hello-world:1",
+			"index.docker.io/octocat/hello-world:b0bb040",
+		},
+		Tag: "octocat/hello-world:1.2.3",
+	}
+
+	err := b.Tags()
+	if err != nil {
+		t.Errorf("Tags returned err: %v", err)
+	}
+
+	if !reflect.DeepEqual(b, want) {
+		t.Errorf("Tags is %+v, want %+v", b, want)
+	}
+}
+
+func TestMakisu_Build_Tags_NoPushes(t *testing.T) {
+	// setup types
+	t.Setenv("VELA_BUILD_TAG", "v1.2.3")
+	t.Setenv("VELA_BUILD_COMMIT", "b0bb040e6a6d71ddf98684349c42d36fa6c539ad")
+
+	b := &Build{
+		AutoTag: true,
+		Tag:     "octocat/hello-world",
+	}
+
+	err := b.Tags()
+	if err == nil {
+		t.Errorf("Tags should have returned err")
+	}
+
+	if len(b.Replicas) > 0 {
+		t.Errorf("Tags replicas are %v, want none", b.Replicas)
+	}
+}
+
+func TestMakisu_Build_Tags_NoPushes_PrimaryOnly(t *testing.T) {
+	// setup types
+	t.Setenv("VELA_BUILD_TAG", "")
+	t.Setenv("VELA_BUILD_COMMIT", "b0bb040e6a6d71ddf98684349c42d36fa6c539ad")
+	t.Setenv("VELA_BUILD_BRANCH", "")
+
+	b := &Build{
+		AutoTag: true,
+		Tag:     "octocat/hello-world",
+	}
+
+	// the image is tagged without pushes when only the primary tag is derived
+	err := b.Tags()
+	if err != nil {
+		t.Errorf("Tags returned err: %v", err)
+	}
+
+	if b.Tag != "octocat/hello-world:b0bb040" {
+		t.Errorf("Tags is %s, want octocat/hello-world:b0bb040", b.Tag)
+	}
+}
+
+func TestMakisu_Build_Tags_NoMetadata(t *testing.T) {
+	// setup types
+	t.Setenv("VELA_BUILD_TAG", "")
+	t.Setenv("VELA_BUILD_COMMIT", "")
+	t.Setenv("VELA_BUILD_BRANCH", "")
+
+	b := &Build{
+		AutoTag: true,
+		Tag:     "octocat/hello-world",
+	}
+
+	err := b.Tags()
+	if err == nil {
+		t.Errorf("Tags should have returned err")
+	}
+}
+
 func TestMakisu_Build_Unmarshal(t *testing.T) {
 	// setup types
 	b := &Build{
@@ -148,6 +539,32 @@ func TestMakisu_Build_Unmarshal(t *testing.T) {
 	}
 }
 
+func TestMakisu_Build_Unmarshal_Secrets(t *testing.T) {
+	// setup types
+	b := &Build{
+		BuildSecretsRaw: `[{"id": "npm_token", "env": "NPM_TOKEN"}]`,
+		DenyList:        []string{"/tmp"},
+	}
+
+	want := &Build{
+		BuildSecrets: []*BuildSecret{{ID: "npm_token", Env: "NPM_TOKEN"}},
+		DenyList:     []string{"/tmp", "/run/secrets"},
+	}
+
+	err := b.Unmarshal()
+	if err != nil {
+		t.Errorf("Unmarshal returned err: %v", err)
+	}
+
+	if !reflect.DeepEqual(b.BuildSecrets, want.BuildSecrets) {
+		t.Errorf("Unmarshal is %v, want %v", b.BuildSecrets, want.BuildSecrets)
+	}
+
+	if !reflect.DeepEqual(b.DenyList, want.DenyList) {
+		t.Errorf("Unmarshal is %v, want %v", b.DenyList, want.DenyList)
+	}
+}
+
 func TestMakisu_Build_Unmarshal_FailDockerUnmarshal(t *testing.T) {
 	// setup types
 	b := &Build{
@@ -203,6 +620,11 @@ func TestMakisu_Build_Unmarshal_FailRedisCacheUnmarshal(t *testing.T) {
 }
 
 func TestMakisu_Build_Validate(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	_ = afero.WriteFile(appFS, "Dockerfile", []byte(testDockerfile), 0644)
+
 	// setup types
 	b := &Build{
 		Context: ".",
@@ -215,6 +637,386 @@ func TestMakisu_Build_Validate(t *testing.T) {
 	}
 }
 
+func TestMakisu_Build_Validate_BadBuilder(t *testing.T) {
+	// setup types
+	b := &Build{
+		Builder: "foo",
+		Context: ".",
+		Tag:     "latest",
+	}
+
+	err := b.Validate()
+	if err == nil {
+		t.Errorf("Validate should have returned err")
+	}
+}
+
+func TestMakisu_Build_Validate_BadLabels(t *testing.T) {
+	// setup types
+	b := &Build{
+		Context: ".",
+		Labels:  []string{"foo"},
+		Tag:     "latest",
+	}
+
+	err := b.Validate()
+	if err == nil {
+		t.Errorf("Validate should have returned err")
+	}
+}
+
+func TestMakisu_Build_Validate_KanikoReport(t *testing.T) {
+	// setup types
+	b := &Build{
+		Builder:    kanikoBuilder,
+		Context:    ".",
+		ReportPath: "reports/makisu.json",
+		Tag:        "latest",
+	}
+
+	err := b.Validate()
+	if err == nil {
+		t.Errorf("Validate should have returned err")
+	}
+}
+
+func TestMakisu_Build_Validate_Images(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	_ = afero.WriteFile(appFS, "Dockerfile", []byte(testDockerfile), 0644)
+
+	// setup tests
+	tests := []struct {
+		build   *Build
+		failure bool
+	}{
+		{ // images without a build tag
+			build: &Build{
+				Context: ".",
+				Images:  []*Image{{Tag: "octocat/api:latest"}},
+			},
+			failure: false,
+		},
+		{ // image without a tag
+			build: &Build{
+				Context: ".",
+				Images:  []*Image{{Context: "api"}},
+			},
+			failure: true,
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		err := test.build.Validate()
+
+		if test.failure && err == nil {
+			t.Errorf("Validate should have returned err")
+		}
+
+		if !test.failure && err != nil {
+			t.Errorf("Validate returned err: %v", err)
+		}
+	}
+}
+
+func TestMakisu_Build_Validate_Policy(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	_ = afero.WriteFile(appFS, "Dockerfile", []byte(testDockerfile), 0644)
+
+	// setup tests
+	tests := []struct {
+		build   *Build
+		failure bool
+	}{
+		{ // allowed
+			build: &Build{
+				Context:  ".",
+				Policies: []*Policy{{AllowedRegistries: []string{"docker.company.com"}}},
+				Pushes:   []string{"docker.company.com"},
+				Tag:      "octocat/hello-world:1.0.0",
+			},
+			failure: false,
+		},
+		{ // registry not allowed for images
+			build: &Build{
+				Context:  ".",
+				Images:   []*Image{{Tag: "octocat/hello-world:1.0.0", Pushes: []string{"index.docker.io"}}},
+				Policies: []*Policy{{AllowedRegistries: []string{"docker.company.com"}}},
+				Pushes:   []string{"docker.company.com"},
+			},
+			failure: true,
+		},
+		{ // invalid policy
+			build: &Build{
+				Context:  ".",
+				Policies: []*Policy{{RequiredTags: []string{"regex:("}}},
+				Tag:      "octocat/hello-world:1.0.0",
+			},
+			failure: true,
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		err := test.build.Validate()
+
+		if test.failure && err == nil {
+			t.Errorf("Validate should have returned err")
+		}
+
+		if !test.failure && err != nil {
+			t.Errorf("Validate returned err: %v", err)
+		}
+	}
+}
+
+func TestMakisu_Build_Validate_Secrets(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	_ = afero.WriteFile(appFS, "Dockerfile", []byte(testDockerfile), 0644)
+
+	// setup environment
+	os.Setenv("NPM_TOKEN", "superSecretPassword")
+	defer os.Unsetenv("NPM_TOKEN")
+
+	// setup tests
+	tests := []struct {
+		build   *Build
+		failure bool
+	}{
+		{ // secret staged
+			build: &Build{
+				BuildSecrets: []*BuildSecret{{ID: "npm_token", Env: "NPM_TOKEN"}},
+				Context:      ".",
+				Tag:          "octocat/hello-world:1.0.0",
+			},
+			failure: false,
+		},
+		{ // duplicate secret
+			build: &Build{
+				BuildSecrets: []*BuildSecret{
+					{ID: "npm_token", Env: "NPM_TOKEN"},
+					{ID: "npm_token", Env: "NPM_TOKEN"},
+				},
+				Context: ".",
+				Tag:     "octocat/hello-world:1.0.0",
+			},
+			failure: true,
+		},
+		{ // secret provided as build arg for image
+			build: &Build{
+				BuildSecrets: []*BuildSecret{{ID: "npm_token", Env: "NPM_TOKEN"}},
+				Context:      ".",
+				Images:       []*Image{{Tag: "octocat/hello-world:1.0.0", BuildArgs: []string{"NPM_TOKEN"}}},
+			},
+			failure: true,
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		err := test.build.Validate()
+
+		if test.failure && err == nil {
+			t.Errorf("Validate should have returned err")
+		}
+
+		if !test.failure && err != nil {
+			t.Errorf("Validate returned err: %v", err)
+		}
+	}
+}
+
+func TestMakisu_Build_Validate_SBOM(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	_ = afero.WriteFile(appFS, "Dockerfile", []byte(testDockerfile), 0644)
+
+	// setup tests
+	tests := []struct {
+		build   *Build
+		failure bool
+	}{
+		{ // sbom with destination
+			build:   &Build{Context: ".", Destination: "image.tar", SBOM: "cyclonedx", Tag: "latest"},
+			failure: false,
+		},
+		{ // sbom without destination
+			build:   &Build{Context: ".", SBOM: "spdx", Tag: "latest"},
+			failure: true,
+		},
+		{ // invalid sbom format
+			build:   &Build{Context: ".", Destination: "image.tar", SBOM: "foo", Tag: "latest"},
+			failure: true,
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		err := test.build.Validate()
+
+		if test.failure && err == nil {
+			t.Errorf("Validate should have returned err")
+		}
+
+		if !test.failure && err != nil {
+			t.Errorf("Validate returned err: %v", err)
+		}
+	}
+}
+
+func TestMakisu_Build_Validate_Scan(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	_ = afero.WriteFile(appFS, "Dockerfile", []byte(testDockerfile), 0644)
+
+	_ = appFS.MkdirAll("/vela/vulndb", 0755)
+
+	// setup tests
+	tests := []struct {
+		build   *Build
+		failure bool
+	}{
+		{ // scan with destination
+			build: &Build{
+				Context:     ".",
+				Destination: "image.tar",
+				Scan:        &Scan{Database: "/vela/vulndb", Severity: criticalSeverity},
+				Tag:         "latest",
+			},
+			failure: false,
+		},
+		{ // scan without destination
+			build: &Build{
+				Context: ".",
+				Scan:    &Scan{Database: "/vela/vulndb", Severity: criticalSeverity},
+				Tag:     "latest",
+			},
+			failure: true,
+		},
+		{ // scan with pushes for kaniko
+			build: &Build{
+				Builder:     kanikoBuilder,
+				Context:     ".",
+				Destination: "image.tar",
+				Pushes:      []string{"index.docker.io"},
+				Scan:        &Scan{Database: "/vela/vulndb", Severity: criticalSeverity},
+				Tag:         "latest",
+			},
+			failure: true,
+		},
+		{ // scan without database
+			build: &Build{
+				Context:     ".",
+				Destination: "image.tar",
+				Scan:        &Scan{Severity: criticalSeverity},
+				Tag:         "latest",
+			},
+			failure: true,
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		err := test.build.Validate()
+
+		if test.failure && err == nil {
+			t.Errorf("Validate should have returned err")
+		}
+
+		if !test.failure && err != nil {
+			t.Errorf("Validate returned err: %v", err)
+		}
+	}
+}
+
+func TestMakisu_Build_Validate_PushProvenance(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	_ = afero.WriteFile(appFS, "Dockerfile", []byte(testDockerfile), 0644)
+
+	// setup tests
+	tests := []struct {
+		build   *Build
+		failure bool
+	}{
+		{ // push provenance with pushes
+			build:   &Build{Context: ".", Pushes: []string{"index.docker.io"}, PushProvenance: true, Tag: "latest"},
+			failure: false,
+		},
+		{ // push provenance without pushes
+			build:   &Build{Context: ".", PushProvenance: true, Tag: "latest"},
+			failure: true,
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		err := test.build.Validate()
+
+		if test.failure && err == nil {
+			t.Errorf("Validate should have returned err")
+		}
+
+		if !test.failure && err != nil {
+			t.Errorf("Validate returned err: %v", err)
+		}
+	}
+}
+
+func TestMakisu_Build_Validate_Sign(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	_ = afero.WriteFile(appFS, "Dockerfile", []byte(testDockerfile), 0644)
+
+	testSigningKey(t, defaultSignKey)
+
+	// setup tests
+	tests := []struct {
+		build   *Build
+		failure bool
+	}{
+		{ // sign with pushes
+			build:   &Build{Context: ".", Pushes: []string{"index.docker.io"}, Sign: true, SignKey: defaultSignKey, Tag: "latest"},
+			failure: false,
+		},
+		{ // sign without pushes
+			build:   &Build{Context: ".", Sign: true, SignKey: defaultSignKey, Tag: "latest"},
+			failure: true,
+		},
+		{ // sign without key
+			build:   &Build{Context: ".", Pushes: []string{"index.docker.io"}, Sign: true, SignKey: "missing.key", Tag: "latest"},
+			failure: true,
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		err := test.build.Validate()
+
+		if test.failure && err == nil {
+			t.Errorf("Validate should have returned err")
+		}
+
+		if !test.failure && err != nil {
+			t.Errorf("Validate returned err: %v", err)
+		}
+
+		if !test.failure && test.build.signer == nil {
+			t.Errorf("Validate should have loaded the signing key")
+		}
+	}
+}
+
 func TestMakisu_Build_Validate_NoContext(t *testing.T) {
 	// setup types
 	b := &Build{
@@ -299,3 +1101,70 @@ func TestMakisu_RedisCache_Flag(t *testing.T) {
 		t.Errorf("Flag is %v, want %v", got, want)
 	}
 }
+
+func TestMakisu_Build_Secrets(t *testing.T) {
+	// setup types
+	b := &Build{
+		HTTPCache: &HTTPCache{
+			Headers: []string{"Content-type: Application/json", "Authorization: Bearer superSecret"},
+		},
+		RedisCache: &RedisCache{
+			Password: "superSecret123",
+		},
+	}
+
+	want := []string{"Bearer superSecret", "superSecret123"}
+
+	got := b.Secrets()
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Secrets is %v, want %v", got, want)
+	}
+}
+
+func TestMakisu_Build_Validate_OutputFormat(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	_ = afero.WriteFile(appFS, "Dockerfile", []byte(testDockerfile), 0644)
+
+	// setup tests
+	tests := []struct {
+		build   *Build
+		failure bool
+	}{
+		{ // docker tarball without destination
+			build:   &Build{Context: ".", OutputFormat: dockerTarFormat, Tag: "latest"},
+			failure: false,
+		},
+		{ // oci layout with destination
+			build:   &Build{Context: ".", Destination: "image.tar", OutputFormat: ociLayoutFormat, Tag: "latest"},
+			failure: false,
+		},
+		{ // oci tarball without destination
+			build:   &Build{Context: ".", OutputFormat: ociTarFormat, Tag: "latest"},
+			failure: true,
+		},
+		{ // oci layout with destination without extension
+			build:   &Build{Context: ".", Destination: "image", OutputFormat: ociLayoutFormat, Tag: "latest"},
+			failure: true,
+		},
+		{ // invalid format
+			build:   &Build{Context: ".", Destination: "image.tar", OutputFormat: "foo", Tag: "latest"},
+			failure: true,
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		err := test.build.Validate()
+
+		if test.failure && err == nil {
+			t.Errorf("Validate should have returned err")
+		}
+
+		if !test.failure && err != nil {
+			t.Errorf("Validate returned err: %v", err)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright (c) 2022 Target Brands, Inc. All rights reserved.
+//
+// Use of this source code is governed by the LICENSE file in this repository.
+
+package main
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+const (
+	// kanikoBuilder represents the name for the kaniko builder backend.
+	kanikoBuilder = "kaniko"
+
+	// makisuBuilder represents the name for the makisu builder backend.
+	makisuBuilder = "makisu"
+)
+
+// Builder represents the interface for a backend
+// capable of building and publishing images.
+type Builder interface {
+	// Command formats and outputs the command for building an
+	// image from the provided configuration with a function
+	// removing any files created for the command.
+	Command(*Build) (*exec.Cmd, func(), error)
+	// Exec formats and runs the commands for
+	// building an image from the provided configuration.
+	Exec(*Build) error
+	// Push formats and outputs the command for pushing the
+	// image in the tarball at the provided path to the
+	// registries from the provided configuration.
+	Push(*Build, string) (*exec.Cmd, error)
+	// Version outputs the command for displaying
+	// the version information of the backend.
+	Version() *exec.Cmd
+}
+
+// builders represents the supported backends for building images.
+var builders = map[string]Builder{
+	kanikoBuilder: new(Kaniko),
+	makisuBuilder: new(Makisu),
+}
+
+// newBuilder is a helper function to return
+// the backend for the provided builder name.
+func newBuilder(name string) (Builder, error) {
+	// default to makisu when no builder is provided
+	if len(name) == 0 {
+		name = makisuBuilder
+	}
+
+	b, ok := builders[name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported builder provided: %s", name)
+	}
+
+	return b, nil
+}
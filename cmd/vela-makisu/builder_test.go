@@ -0,0 +1,41 @@
+// Copyright (c) 2022 Target Brands, Inc. All rights reserved.
+//
+// Use of this source code is governed by the LICENSE file in this repository.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMakisu_newBuilder(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		name string
+		want Builder
+	}{
+		{name: "", want: builders[makisuBuilder]},
+		{name: makisuBuilder, want: builders[makisuBuilder]},
+		{name: kanikoBuilder, want: builders[kanikoBuilder]},
+	}
+
+	// run tests
+	for _, test := range tests {
+		got, err := newBuilder(test.name)
+		if err != nil {
+			t.Errorf("newBuilder returned err: %v", err)
+		}
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("newBuilder is %v, want %v", got, test.want)
+		}
+	}
+}
+
+func TestMakisu_newBuilder_Unsupported(t *testing.T) {
+	_, err := newBuilder("foo")
+	if err == nil {
+		t.Errorf("newBuilder should have returned err")
+	}
+}
@@ -0,0 +1,411 @@
+// Copyright (c) 2022 Target Brands, Inc. All rights reserved.
+//
+// Use of this source code is governed by the LICENSE file in this repository.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/uber/makisu/lib/docker/image"
+	"github.com/uber/makisu/lib/registry"
+	"github.com/uber/makisu/lib/utils/httputil"
+)
+
+const (
+	// blobURL represents the url for interacting with image blobs.
+	blobURL = "http://%s/v2/%s/blobs/%s"
+
+	// manifestURL represents the url for interacting with image manifests.
+	manifestURL = "http://%s/v2/%s/manifests/%s"
+
+	// uploadURL represents the url for starting the upload of an image blob.
+	uploadURL = "http://%s/v2/%s/blobs/uploads/"
+
+	// clientTimeout represents the default timeout for registry requests.
+	clientTimeout = 5 * time.Minute
+)
+
+// loadedConfigs represents the registry configurations
+// already loaded into makisu and the result of loading them.
+var (
+	loadedConfigs   = make(map[string]error)
+	loadedConfigsMu sync.Mutex
+)
+
+// Client represents a client for communicating with a Docker Registry.
+type Client struct {
+	// configuration for communicating with the Docker Registry
+	Config registry.Config
+	// name of the image in the Docker Registry
+	Name image.Name
+}
+
+// newClient creates a client for the provided image reference
+// with the configuration from the provided registry configuration.
+func newClient(registryConfig, reference string) (*Client, error) {
+	logrus.Tracef("creating registry client for %s", reference)
+
+	// check if registry configuration is provided
+	if len(registryConfig) > 0 {
+		// load the registry configuration i.e. a path or JSON
+		err := loadRegistryConfig(registryConfig)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// parse the reference into the image name
+	name, err := image.ParseNameForPull(reference)
+	if err != nil {
+		return nil, err
+	}
+
+	// verify the reference is a valid image name
+	if !name.IsValid() {
+		return nil, fmt.Errorf("invalid image reference provided: %s", reference)
+	}
+
+	// variable to store configuration for the registry
+	config := registry.Config{}
+
+	// docker hub requires anonymous credentials
+	if name.GetRegistry() == image.DockerHubRegistry {
+		config = registry.DefaultDockerHubConfiguration
+	}
+
+	// find the configuration for the repository mirroring makisu
+	for repo, c := range registry.ConfigurationMap[name.GetRegistry()] {
+		re, err := regexp.Compile(repo)
+		if err != nil {
+			return nil, fmt.Errorf("invalid repo %s provided for registry %s: %w", repo, name.GetRegistry(), err)
+		}
+
+		if re.MatchString(name.GetRepository()) {
+			config = c
+
+			break
+		}
+	}
+
+	// copy the TLS configuration to avoid modifying the shared configuration
+	if config.Security.TLS != nil {
+		tls := *config.Security.TLS
+		config.Security.TLS = &tls
+	}
+
+	// apply the default security configuration
+	config.Security = config.Security.ApplyDefaults()
+
+	// fallback to the system certificates when the makisu certificates are missing
+	if _, err := os.Stat(config.Security.TLS.CA.Cert.Path); err != nil {
+		config.Security.TLS.CA.Cert.Path = ""
+	}
+
+	// apply the default timeout for requests
+	if config.Timeout == 0 {
+		config.Timeout = clientTimeout
+	}
+
+	return &Client{
+		Config: config,
+		Name:   name,
+	}, nil
+}
+
+// loadRegistryConfig loads the provided registry configuration
+// into makisu once and reuses the result for subsequent calls.
+func loadRegistryConfig(registryConfig string) error {
+	loadedConfigsMu.Lock()
+	defer loadedConfigsMu.Unlock()
+
+	// check if the registry configuration was already loaded
+	err, ok := loadedConfigs[registryConfig]
+	if ok {
+		return err
+	}
+
+	logrus.Tracef("loading registry configuration %s", registryConfig)
+
+	err = registry.UpdateGlobalConfig(registryConfig)
+
+	loadedConfigs[registryConfig] = err
+
+	return err
+}
+
+// Manifest pulls the manifest for the image from the Docker Registry.
+func (c *Client) Manifest() (*image.DistributionManifest, *image.Descriptor, error) {
+	logrus.Tracef("pulling manifest for %s", c.Name)
+
+	// capture the security options for the request
+	opt, err := c.Config.Security.GetHTTPOption(c.Name.GetRegistry(), c.Name.GetRepository())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// send the request for the manifest
+	resp, err := httputil.Get(
+		fmt.Sprintf(manifestURL, c.Name.GetRegistry(), c.Name.GetRepository(), c.Name.GetTag()),
+		opt,
+		httputil.SendTimeout(c.Config.Timeout),
+		httputil.SendHeaders(map[string]string{"Accept": image.MediaTypeManifest}),
+	)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to pull manifest for %s: %w", c.Name, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// parse the manifest and compute the digest
+	manifest, descriptor, err := image.UnmarshalDistributionManifest(resp.Header.Get("Content-Type"), body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// verify the digest matches the digest from the registry
+	digest := resp.Header.Get("Docker-Content-Digest")
+	if len(digest) > 0 && digest != string(descriptor.Digest) {
+		return nil, nil, fmt.Errorf("digest mismatch for %s: got %s, want %s", c.Name, descriptor.Digest, digest)
+	}
+
+	return &manifest, &descriptor, nil
+}
+
+// manifestMediaTypes represents the media types accepted when pulling a manifest.
+var manifestMediaTypes = []string{
+	image.MediaTypeManifest,
+	manifestListMediaType,
+	ociManifestMediaType,
+	ociIndexMediaType,
+}
+
+// RawManifest pulls the manifest for the provided reference i.e. a tag
+// or a digest from the Docker Registry without modifying the content.
+func (c *Client) RawManifest(reference string) ([]byte, string, error) {
+	logrus.Tracef("pulling manifest %s for %s", reference, c.Name)
+
+	resp, err := c.send(
+		http.MethodGet,
+		fmt.Sprintf(manifestURL, c.Name.GetRegistry(), c.Name.GetRepository(), reference),
+		map[string]string{"Accept": strings.Join(manifestMediaTypes, ", ")},
+		nil,
+		http.StatusOK,
+	)
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to pull manifest %s for %s: %w", reference, c.Name, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// verify the digest when pulling the manifest by digest
+	if strings.HasPrefix(reference, "sha256:") && string(digestOf(body)) != reference {
+		return nil, "", fmt.Errorf("digest mismatch for %s: got %s, want %s", c.Name, digestOf(body), reference)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+
+	return body, mediaType, nil
+}
+
+// Blob pulls the blob from the repository of the Docker Registry.
+//
+// The caller is responsible for closing the returned reader.
+func (c *Client) Blob(digest image.Digest) (io.ReadCloser, error) {
+	logrus.Tracef("pulling blob %s for %s", digest, c.Name)
+
+	resp, err := c.send(
+		http.MethodGet,
+		fmt.Sprintf(blobURL, c.Name.GetRegistry(), c.Name.GetRepository(), digest),
+		nil,
+		nil,
+		http.StatusOK,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("unable to pull blob %s for %s: %w", digest, c.Name, err)
+	}
+
+	return resp.Body, nil
+}
+
+// BlobExists checks if the blob exists in the repository of the Docker Registry.
+func (c *Client) BlobExists(digest image.Digest) (bool, error) {
+	logrus.Tracef("checking if blob %s exists for %s", digest, c.Name)
+
+	resp, err := c.send(
+		http.MethodHead,
+		fmt.Sprintf(blobURL, c.Name.GetRegistry(), c.Name.GetRepository(), digest),
+		nil,
+		nil,
+		http.StatusOK, http.StatusNotFound,
+	)
+	if err != nil {
+		return false, fmt.Errorf("unable to check blob %s for %s: %w", digest, c.Name, err)
+	}
+	defer resp.Body.Close()
+
+	return resp.StatusCode == http.StatusOK, nil
+}
+
+// PushBlob uploads the blob to the repository of the Docker
+// Registry unless the blob already exists in the repository.
+func (c *Client) PushBlob(digest image.Digest, size int64, r io.Reader) error {
+	logrus.Tracef("pushing blob %s for %s", digest, c.Name)
+
+	exists, err := c.BlobExists(digest)
+	if err != nil {
+		return err
+	}
+
+	// skip uploading blobs which already exist
+	if exists {
+		logrus.Debugf("skipped pushing existing blob %s for %s", digest, c.Name)
+
+		return nil
+	}
+
+	// start the upload for the blob
+	resp, err := c.send(
+		http.MethodPost,
+		fmt.Sprintf(uploadURL, c.Name.GetRegistry(), c.Name.GetRepository()),
+		nil,
+		nil,
+		http.StatusAccepted,
+	)
+	if err != nil {
+		return fmt.Errorf("unable to start upload of blob %s for %s: %w", digest, c.Name, err)
+	}
+	resp.Body.Close()
+
+	location, err := c.location(resp.Header.Get("Location"))
+	if err != nil {
+		return err
+	}
+
+	// add the digest to complete the upload
+	q := location.Query()
+	q.Set("digest", string(digest))
+	location.RawQuery = q.Encode()
+
+	// hide the Close method of the reader so a failed attempt
+	// over https does not close it before retrying over http
+	body := struct{ io.Reader }{r}
+
+	// upload the blob in a single request
+	resp, err = c.send(
+		http.MethodPut,
+		location.String(),
+		map[string]string{
+			"Content-Type":   "application/octet-stream",
+			"Content-Length": fmt.Sprintf("%d", size),
+		},
+		body,
+		http.StatusCreated, http.StatusNoContent,
+	)
+	if err != nil {
+		return fmt.Errorf("unable to push blob %s for %s: %w", digest, c.Name, err)
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+// PushManifest uploads the manifest to the Docker Registry
+// with the provided reference i.e. a tag or a digest.
+func (c *Client) PushManifest(reference, mediaType string, manifest []byte) error {
+	logrus.Tracef("pushing manifest %s for %s", reference, c.Name)
+
+	resp, err := c.send(
+		http.MethodPut,
+		fmt.Sprintf(manifestURL, c.Name.GetRegistry(), c.Name.GetRepository(), reference),
+		map[string]string{"Content-Type": mediaType},
+		bytes.NewReader(manifest),
+		http.StatusOK, http.StatusCreated,
+	)
+	if err != nil {
+		return fmt.Errorf("unable to push manifest %s for %s: %w", reference, c.Name, err)
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+// send is a helper function to send a request to the
+// Docker Registry with the configuration for the Client.
+func (c *Client) send(method, rawurl string, headers map[string]string, body io.Reader, codes ...int) (*http.Response, error) {
+	// capture the security options for the request
+	opt, err := c.Config.Security.GetHTTPOption(c.Name.GetRegistry(), c.Name.GetRepository())
+	if err != nil {
+		return nil, err
+	}
+
+	// variable to store the headers for the request
+	h := map[string]string{"Host": c.Name.GetRegistry()}
+
+	for key, value := range headers {
+		h[key] = value
+	}
+
+	options := []httputil.SendOption{
+		opt,
+		httputil.SendTimeout(c.Config.Timeout),
+		httputil.SendHeaders(h),
+		httputil.SendAcceptedCodes(codes...),
+	}
+
+	// check if a body is provided
+	if body != nil {
+		options = append(options, httputil.SendBody(body))
+	}
+
+	return httputil.Send(method, rawurl, options...)
+}
+
+// location is a helper function to resolve the upload
+// location returned by the Docker Registry.
+func (c *Client) location(rawurl string) (*url.URL, error) {
+	// verify the location is provided
+	if len(rawurl) == 0 {
+		return nil, fmt.Errorf("no upload location returned for %s", c.Name)
+	}
+
+	base, err := url.Parse(fmt.Sprintf(uploadURL, c.Name.GetRegistry(), c.Name.GetRepository()))
+	if err != nil {
+		return nil, err
+	}
+
+	// resolve locations relative to the registry
+	return base.Parse(rawurl)
+}
+
+// manifestSize is a helper function to return the total
+// size of the config and layers for the manifest.
+func manifestSize(manifest *image.DistributionManifest) int64 {
+	// capture the size of the config
+	size := manifest.Config.Size
+
+	for _, layer := range manifest.Layers {
+		size += layer.Size
+	}
+
+	return size
+}
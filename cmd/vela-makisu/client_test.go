@@ -0,0 +1,281 @@
+// Copyright (c) 2022 Target Brands, Inc. All rights reserved.
+//
+// Use of this source code is governed by the LICENSE file in this repository.
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/uber/makisu/lib/docker/image"
+)
+
+// testManifest represents a manifest served by the test registry.
+const testManifest = `{
+   "schemaVersion": 2,
+   "mediaType": "application/vnd.docker.distribution.manifest.v2+json",
+   "config": {
+      "mediaType": "application/vnd.docker.container.image.v1+json",
+      "size": 100,
+      "digest": "sha256:0000000000000000000000000000000000000000000000000000000000000000"
+   },
+   "layers": [
+      {
+         "mediaType": "application/vnd.docker.image.rootfs.diff.tar.gzip",
+         "size": 1000,
+         "digest": "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+      }
+   ]
+}`
+
+// testManifestDigest represents the digest of the manifest served by the test registry.
+var testManifestDigest = fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(testManifest)))
+
+// testRegistry is a helper function to create a
+// registry serving the test manifest for any tag.
+func testRegistry(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.URL.Path, "/manifests/") {
+			w.WriteHeader(http.StatusNotFound)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", image.MediaTypeManifest)
+		w.Header().Set("Docker-Content-Digest", testManifestDigest)
+
+		_, _ = w.Write([]byte(testManifest))
+	}))
+
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+// testStore represents the content pushed to the test registry.
+type testStore struct {
+	mu sync.Mutex
+
+	// blobs pushed to the registry by digest
+	blobs map[string][]byte
+	// manifests pushed to the registry by "<repo>:<reference>"
+	manifests map[string][]byte
+	// media types of the manifests by "<repo>:<reference>"
+	types map[string]string
+}
+
+// testPushRegistry is a helper function to create a registry
+// storing the blobs and manifests pushed to it in memory.
+func testPushRegistry(t *testing.T) (*httptest.Server, *testStore) {
+	t.Helper()
+
+	store := &testStore{
+		blobs:     make(map[string][]byte),
+		manifests: make(map[string][]byte),
+		types:     make(map[string]string),
+	}
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		store.mu.Lock()
+		defer store.mu.Unlock()
+
+		path := strings.TrimPrefix(r.URL.Path, "/v2/")
+
+		switch {
+		case strings.Contains(path, "/blobs/uploads/") && r.Method == http.MethodPost:
+			w.Header().Set("Location", "/v2/"+path+"upload")
+			w.WriteHeader(http.StatusAccepted)
+		case strings.Contains(path, "/blobs/uploads/") && r.Method == http.MethodPut:
+			data, _ := io.ReadAll(r.Body)
+
+			digest := r.URL.Query().Get("digest")
+			if digest != fmt.Sprintf("sha256:%x", sha256.Sum256(data)) {
+				w.WriteHeader(http.StatusBadRequest)
+
+				return
+			}
+
+			store.blobs[digest] = data
+
+			w.WriteHeader(http.StatusCreated)
+		case strings.Contains(path, "/blobs/"):
+			i := strings.LastIndex(path, "/blobs/")
+
+			data, ok := store.blobs[path[i+len("/blobs/"):]]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+
+				return
+			}
+
+			w.Header().Set("Content-Length", fmt.Sprintf("%d", len(data)))
+
+			if r.Method == http.MethodGet {
+				_, _ = w.Write(data)
+			}
+		case strings.Contains(path, "/manifests/"):
+			i := strings.LastIndex(path, "/manifests/")
+			key := path[:i] + ":" + path[i+len("/manifests/"):]
+
+			if r.Method == http.MethodPut {
+				data, _ := io.ReadAll(r.Body)
+				digest := fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+
+				for _, k := range []string{key, path[:i] + ":" + digest} {
+					store.manifests[k] = data
+					store.types[k] = r.Header.Get("Content-Type")
+				}
+
+				w.Header().Set("Docker-Content-Digest", digest)
+				w.WriteHeader(http.StatusCreated)
+
+				return
+			}
+
+			data, ok := store.manifests[key]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+
+				return
+			}
+
+			w.Header().Set("Content-Type", store.types[key])
+			w.Header().Set("Docker-Content-Digest", fmt.Sprintf("sha256:%x", sha256.Sum256(data)))
+
+			if r.Method == http.MethodGet {
+				_, _ = w.Write(data)
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	t.Cleanup(s.Close)
+
+	return s, store
+}
+
+func TestMakisu_newClient(t *testing.T) {
+	// setup types
+	c, err := newClient("", "octocat/hello-world:1.0.0")
+	if err != nil {
+		t.Errorf("newClient returned err: %v", err)
+	}
+
+	if c.Name.String() != "index.docker.io/octocat/hello-world:1.0.0" {
+		t.Errorf("newClient name is %s, want index.docker.io/octocat/hello-world:1.0.0", c.Name)
+	}
+
+	if c.Config.Timeout != clientTimeout {
+		t.Errorf("newClient timeout is %v, want %v", c.Config.Timeout, clientTimeout)
+	}
+}
+
+func TestMakisu_newClient_BadConfig(t *testing.T) {
+	_, err := newClient("/path/to/missing/config.json", "octocat/hello-world:1.0.0")
+	if err == nil {
+		t.Errorf("newClient should have returned err")
+	}
+}
+
+func TestMakisu_newClient_BadRepo(t *testing.T) {
+	_, err := newClient(`{"invalid.company.com": {"(": {}}}`, "invalid.company.com/octocat/hello-world:1.0.0")
+	if err == nil || !strings.Contains(err.Error(), "invalid repo") {
+		t.Errorf("newClient returned err %v, want invalid repo", err)
+	}
+}
+
+func TestMakisu_loadRegistryConfig(t *testing.T) {
+	config := "/path/to/missing/config.json"
+
+	// setup loaded configuration
+	loadedConfigs[config] = nil
+	defer delete(loadedConfigs, config)
+
+	err := loadRegistryConfig(config)
+	if err != nil {
+		t.Errorf("loadRegistryConfig returned err: %v", err)
+	}
+}
+
+func TestMakisu_Client_Manifest(t *testing.T) {
+	// setup types
+	s := testRegistry(t)
+
+	c, err := newClient("", fmt.Sprintf("%s/octocat/hello-world:latest", strings.TrimPrefix(s.URL, "http://")))
+	if err != nil {
+		t.Errorf("newClient returned err: %v", err)
+	}
+
+	manifest, descriptor, err := c.Manifest()
+	if err != nil {
+		t.Errorf("Manifest returned err: %v", err)
+	}
+
+	if string(descriptor.Digest) != testManifestDigest {
+		t.Errorf("Manifest digest is %s, want %s", descriptor.Digest, testManifestDigest)
+	}
+
+	if manifestSize(manifest) != 1100 {
+		t.Errorf("manifestSize is %d, want %d", manifestSize(manifest), 1100)
+	}
+}
+
+func TestMakisu_Client_PushBlob(t *testing.T) {
+	// setup types
+	s, store := testPushRegistry(t)
+
+	c, err := newClient("", fmt.Sprintf("%s/octocat/hello-world:latest", strings.TrimPrefix(s.URL, "http://")))
+	if err != nil {
+		t.Errorf("newClient returned err: %v", err)
+	}
+
+	data := []byte("hello world")
+	digest := digestOf(data)
+
+	// push the blob twice to verify existing blobs are skipped
+	for i := 0; i < 2; i++ {
+		err = c.PushBlob(digest, int64(len(data)), bytes.NewReader(data))
+		if err != nil {
+			t.Errorf("PushBlob returned err: %v", err)
+		}
+	}
+
+	if !bytes.Equal(store.blobs[string(digest)], data) {
+		t.Errorf("PushBlob is %s, want %s", store.blobs[string(digest)], data)
+	}
+
+	exists, err := c.BlobExists(digestOf([]byte("missing")))
+	if err != nil {
+		t.Errorf("BlobExists returned err: %v", err)
+	}
+
+	if exists {
+		t.Errorf("BlobExists should be false for a missing blob")
+	}
+}
+
+func TestMakisu_Client_PushBlob_BadDigest(t *testing.T) {
+	// setup types
+	s, _ := testPushRegistry(t)
+
+	c, err := newClient("", fmt.Sprintf("%s/octocat/hello-world:latest", strings.TrimPrefix(s.URL, "http://")))
+	if err != nil {
+		t.Errorf("newClient returned err: %v", err)
+	}
+
+	err = c.PushBlob(digestOf([]byte("foo")), 3, strings.NewReader("bar"))
+	if err == nil {
+		t.Errorf("PushBlob should have returned err")
+	}
+}
@@ -5,10 +5,13 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
@@ -19,17 +22,92 @@ const _makisu = "/bin/makisu"
 // execCmd is a helper function to
 // run the provided command.
 func execCmd(e *exec.Cmd) error {
-	logrus.Tracef("executing cmd %s", strings.Join(e.Args, " "))
+	return execCmdContext(context.Background(), e, 0)
+}
+
+// execCmdContext is a helper function to run the provided
+// command until the provided context is done.
+//
+// When the context is done, SIGTERM is forwarded to the command
+// and the command is killed if it is still running after the
+// provided grace period.
+func execCmdContext(ctx context.Context, e *exec.Cmd, grace time.Duration) error {
+	// redact any secrets from the command arguments
+	args := strings.Join(redactArgs(e.Args), " ")
+
+	logrus.Tracef("executing cmd %s", args)
+
+	// check if command stdout is provided
+	if e.Stdout == nil {
+		// set command stdout to OS stdout
+		e.Stdout = os.Stdout
+	}
 
-	// set command stdout to OS stdout
-	e.Stdout = os.Stdout
-	// set command stderr to OS stderr
-	e.Stderr = os.Stderr
+	// check if command stderr is provided
+	if e.Stderr == nil {
+		// set command stderr to OS stderr
+		e.Stderr = os.Stderr
+	}
 
 	// output "trace" string for command
-	fmt.Println("$", strings.Join(e.Args, " "))
+	fmt.Println("$", args)
+
+	// check if the command can be cancelled
+	if ctx.Done() != nil && e.SysProcAttr == nil {
+		// run the command in a process group to stop any child processes
+		e.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	}
+
+	err := e.Start()
+	if err != nil {
+		return err
+	}
+
+	// wait for the command in the background
+	done := make(chan error, 1)
+
+	go func() {
+		done <- e.Wait()
+	}()
+
+	select {
+	case err = <-done:
+		return err
+	case <-ctx.Done():
+	}
+
+	logrus.Warnf("stopping command, waiting up to %s for it to exit", grace)
+
+	// forward the termination signal to the command
+	signalCmd(e, syscall.SIGTERM)
+
+	timer := time.NewTimer(grace)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+	case <-timer.C:
+		logrus.Warn("command did not exit within the grace period, killing it")
+
+		signalCmd(e, syscall.SIGKILL)
+
+		<-done
+	}
+
+	return fmt.Errorf("command was cancelled: %w", ctx.Err())
+}
+
+// signalCmd is a helper function to send the provided
+// signal to the command and any child processes.
+func signalCmd(e *exec.Cmd, sig syscall.Signal) {
+	// check if the command is running in a process group
+	if e.SysProcAttr != nil && e.SysProcAttr.Setpgid {
+		_ = syscall.Kill(-e.Process.Pid, sig)
+
+		return
+	}
 
-	return e.Run()
+	_ = e.Process.Signal(sig)
 }
 
 // versionCmd is a helper function to output
@@ -5,9 +5,12 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"os/exec"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestMakisu_execCmd(t *testing.T) {
@@ -20,6 +23,41 @@ func TestMakisu_execCmd(t *testing.T) {
 	}
 }
 
+func TestMakisu_execCmdContext(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		script string
+		grace  time.Duration
+	}{
+		{ // command exits after the termination signal
+			script: "trap 'exit 0' TERM; sleep 5 & wait",
+			grace:  5 * time.Second,
+		},
+		{ // command killed after the grace period
+			script: "trap '' TERM; sleep 5 & wait",
+			grace:  100 * time.Millisecond,
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+
+		started := time.Now()
+
+		err := execCmdContext(ctx, exec.Command("sh", "-c", test.script), test.grace)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("execCmdContext returned err %v, want %v", err, context.DeadlineExceeded)
+		}
+
+		if time.Since(started) > 3*time.Second {
+			t.Errorf("execCmdContext did not stop the command for %s", test.script)
+		}
+
+		cancel()
+	}
+}
+
 func TestMakisu_versionCmd(t *testing.T) {
 	// setup types
 	want := exec.Command(
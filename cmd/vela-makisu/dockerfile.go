@@ -0,0 +1,454 @@
+// Copyright (c) 2022 Target Brands, Inc. All rights reserved.
+//
+// Use of this source code is governed by the LICENSE file in this repository.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+)
+
+// predefinedArgs represents the build args available
+// without being declared by an ARG instruction.
+//
+// https://docs.docker.com/engine/reference/builder/#predefined-args
+var predefinedArgs = map[string]bool{
+	"HTTP_PROXY":  true,
+	"http_proxy":  true,
+	"HTTPS_PROXY": true,
+	"https_proxy": true,
+	"FTP_PROXY":   true,
+	"ftp_proxy":   true,
+	"NO_PROXY":    true,
+	"no_proxy":    true,
+	"ALL_PROXY":   true,
+	"all_proxy":   true,
+}
+
+type (
+	// Dockerfile represents the instructions parsed from a Dockerfile.
+	Dockerfile struct {
+		// ARG instructions declared before the first stage
+		Args []*DockerfileArg
+		// path to the Dockerfile
+		Path string
+		// stages declared in the Dockerfile
+		Stages []*DockerfileStage
+	}
+
+	// DockerfileArg represents an ARG instruction within a Dockerfile.
+	DockerfileArg struct {
+		// indicates if the ARG declares a default value
+		Default bool
+		// line number of the instruction
+		Line int
+		// name of the build arg
+		Name string
+		// default value of the build arg
+		Value string
+	}
+
+	// DockerfileCopy represents a COPY instruction
+	// copying from another stage or image.
+	DockerfileCopy struct {
+		// stage or image the files are copied from
+		From string
+		// line number of the instruction
+		Line int
+	}
+
+	// DockerfileStage represents a build stage within a Dockerfile.
+	DockerfileStage struct {
+		// ARG instructions declared within the stage
+		Args []*DockerfileArg
+		// COPY instructions copying from another stage or image
+		Copies []*DockerfileCopy
+		// image the stage is built from
+		Image string
+		// line number of the FROM instruction
+		Line int
+		// name of the stage from the "AS" clause
+		Name string
+	}
+
+	// baseImage represents an image the Dockerfile derives from.
+	baseImage struct {
+		// line number of the instruction referencing the image
+		Line int
+		// reference for the image with the build args substituted
+		Ref string
+	}
+)
+
+// parseDockerfile is a helper function to parse
+// the instructions from the provided Dockerfile.
+func parseDockerfile(path string) (*Dockerfile, error) {
+	logrus.Tracef("parsing Dockerfile %s", path)
+
+	// use custom filesystem which enables us to test
+	a := &afero.Afero{
+		Fs: appFS,
+	}
+
+	content, err := a.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseDockerfileContent(path, string(content))
+}
+
+// parseDockerfileContent is a helper function to parse the
+// instructions from the provided content of a Dockerfile.
+func parseDockerfileContent(path, content string) (*Dockerfile, error) {
+	d := &Dockerfile{
+		Path: path,
+	}
+
+	// variable to store the instruction spanning multiple lines
+	var (
+		instruction string
+		start       int
+	)
+
+	for i, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		// skip comments and empty lines within an instruction
+		if len(trimmed) == 0 || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		// capture the line the instruction starts on
+		if len(instruction) == 0 {
+			start = i + 1
+		}
+
+		// check if the instruction continues on the next line
+		if strings.HasSuffix(trimmed, "\\") {
+			instruction += strings.TrimSuffix(trimmed, "\\") + " "
+
+			continue
+		}
+
+		err := d.parse(instruction+trimmed, start)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Dockerfile %s: line %d: %w", path, start, err)
+		}
+
+		instruction = ""
+	}
+
+	// check if the last instruction continues past the end of the file
+	if len(instruction) > 0 {
+		err := d.parse(instruction, start)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Dockerfile %s: line %d: %w", path, start, err)
+		}
+	}
+
+	// verify a stage was declared
+	if len(d.Stages) == 0 {
+		return nil, fmt.Errorf("invalid Dockerfile %s: no FROM instruction found", path)
+	}
+
+	return d, nil
+}
+
+// parse is a helper function to capture the
+// provided instruction for the Dockerfile.
+func (d *Dockerfile) parse(instruction string, line int) error {
+	fields := strings.Fields(instruction)
+
+	// skip empty instructions
+	if len(fields) == 0 {
+		return nil
+	}
+
+	command := strings.ToUpper(fields[0])
+	args := fields[1:]
+
+	switch command {
+	case "FROM":
+		// skip flags for the instruction i.e. "--platform=linux/amd64"
+		for len(args) > 0 && strings.HasPrefix(args[0], "--") {
+			args = args[1:]
+		}
+
+		stage := &DockerfileStage{
+			Line: line,
+		}
+
+		switch {
+		case len(args) == 1:
+			stage.Image = args[0]
+		case len(args) == 3 && strings.EqualFold(args[1], "AS"):
+			stage.Image = args[0]
+			stage.Name = args[2]
+		default:
+			return fmt.Errorf("FROM requires an image and an optional stage name i.e. \"FROM <image> AS <name>\"")
+		}
+
+		d.Stages = append(d.Stages, stage)
+	case "ARG":
+		if len(args) == 0 {
+			return fmt.Errorf("ARG requires a name")
+		}
+
+		// variable to store the args for the instruction
+		var declared []*DockerfileArg
+
+		for _, arg := range args {
+			name, value, hasDefault := cut(arg, "=")
+
+			declared = append(declared, &DockerfileArg{
+				Default: hasDefault,
+				Line:    line,
+				Name:    name,
+				Value:   strings.Trim(value, `"'`),
+			})
+		}
+
+		// check if the ARG is declared before the first stage
+		if len(d.Stages) == 0 {
+			d.Args = append(d.Args, declared...)
+
+			return nil
+		}
+
+		stage := d.Stages[len(d.Stages)-1]
+		stage.Args = append(stage.Args, declared...)
+	case "COPY":
+		// verify the instruction is within a stage
+		if len(d.Stages) == 0 {
+			return fmt.Errorf("%s instruction found before the first FROM instruction", command)
+		}
+
+		stage := d.Stages[len(d.Stages)-1]
+
+		for _, arg := range args {
+			// check if the files are copied from another stage or image
+			if strings.HasPrefix(arg, "--from=") {
+				stage.Copies = append(stage.Copies, &DockerfileCopy{
+					From: strings.TrimPrefix(arg, "--from="),
+					Line: line,
+				})
+			}
+		}
+	default:
+		// verify the instruction is within a stage
+		if len(d.Stages) == 0 {
+			return fmt.Errorf("%s instruction found before the first FROM instruction", command)
+		}
+	}
+
+	return nil
+}
+
+// Preflight verifies the Dockerfile for the Build before running
+// the builder to surface common mistakes with a precise error.
+func (b *Build) Preflight() error {
+	logrus.Trace("analyzing Dockerfile for the build")
+
+	path := b.Dockerfile()
+
+	d, err := parseDockerfile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// skip the analysis when the Dockerfile is within a remote context
+			if remoteContext(b.Context) && len(b.AllowedBaseImages) == 0 {
+				logrus.Warnf("skipping analysis of Dockerfile %s from remote context %s", path, b.Context)
+
+				return nil
+			}
+
+			return fmt.Errorf("unable to find Dockerfile %s", path)
+		}
+
+		return err
+	}
+
+	// verify the base images are allowed
+	err = b.VerifyBaseImages(d)
+	if err != nil {
+		return err
+	}
+
+	// variable to store the names of the stages
+	stages := make(map[string]bool)
+
+	for _, stage := range d.Stages {
+		// check if the image is pinned to the latest tag
+		if !stages[strings.ToLower(stage.Image)] && latest(stage.Image) {
+			logrus.Warnf("%s: line %d: image %s uses the latest tag, consider pinning a version or digest",
+				path, stage.Line, stage.Image)
+		}
+
+		// check if the stage is named
+		if len(stage.Name) > 0 {
+			stages[strings.ToLower(stage.Name)] = true
+		}
+	}
+
+	// verify the target stage exists
+	if len(b.Target) > 0 && !stages[strings.ToLower(b.Target)] {
+		// variable to store the names of the stages for the error
+		var names []string
+
+		for _, stage := range d.Stages {
+			if len(stage.Name) > 0 {
+				names = append(names, stage.Name)
+			}
+		}
+
+		return fmt.Errorf("target stage %s not found in Dockerfile %s (stages: %s)",
+			b.Target, path, strings.Join(names, ", "))
+	}
+
+	// variable to store the args declared in the Dockerfile
+	declared := make(map[string]bool)
+
+	// variable to store the build args provided for the build
+	provided := make(map[string]bool)
+
+	for _, arg := range b.BuildArgs {
+		name, _, _ := cut(arg, "=")
+
+		provided[name] = true
+	}
+
+	// capture the args declared before the first stage and within each stage
+	args := append([]*DockerfileArg{}, d.Args...)
+
+	for _, stage := range d.Stages {
+		args = append(args, stage.Args...)
+	}
+
+	// variable to store the args with a default value before the first stage
+	defaults := make(map[string]bool)
+
+	for _, arg := range d.Args {
+		if arg.Default {
+			defaults[arg.Name] = true
+		}
+	}
+
+	for _, arg := range args {
+		declared[arg.Name] = true
+
+		// check if the arg has no value i.e. not inherited from before the first stage
+		if !arg.Default && !provided[arg.Name] && !defaults[arg.Name] {
+			logrus.Warnf("%s: line %d: ARG %s has no default value and no build arg was provided",
+				path, arg.Line, arg.Name)
+		}
+	}
+
+	for _, arg := range b.BuildArgs {
+		name, _, _ := cut(arg, "=")
+
+		// check if the build arg is used by the Dockerfile
+		if !declared[name] && !predefinedArgs[name] {
+			logrus.Warnf("build arg %s is not declared by an ARG instruction in Dockerfile %s", name, path)
+		}
+	}
+
+	return nil
+}
+
+// baseImages is a helper function to return the images the
+// Dockerfile derives from with the FROM and COPY instructions
+// skipping references to previous stages.
+func (b *Build) baseImages(d *Dockerfile) []*baseImage {
+	// variable to store the values for the args declared before the first stage
+	vars := make(map[string]string)
+
+	for _, arg := range d.Args {
+		vars[arg.Name] = arg.Value
+	}
+
+	for _, arg := range b.BuildArgs {
+		name, value, ok := cut(arg, "=")
+
+		// check if the build arg is declared before the first stage
+		if _, declared := vars[name]; declared {
+			// check if the build arg only provides the name
+			if !ok {
+				value = os.Getenv(name)
+			}
+
+			vars[name] = value
+		}
+	}
+
+	// variable to store the images for the Dockerfile
+	var images []*baseImage
+
+	// variable to store the names of the stages which can be referenced
+	stages := make(map[string]bool)
+
+	for i, stage := range d.Stages {
+		// variable to store the references for the stage
+		refs := []*baseImage{{Line: stage.Line, Ref: expand(stage.Image, vars)}}
+
+		for _, c := range stage.Copies {
+			refs = append(refs, &baseImage{Line: c.Line, Ref: expand(c.From, vars)})
+		}
+
+		for _, ref := range refs {
+			// skip references to previous stages
+			if !stages[strings.ToLower(ref.Ref)] {
+				images = append(images, ref)
+			}
+		}
+
+		// capture the name and index of the stage
+		stages[strconv.Itoa(i)] = true
+
+		if len(stage.Name) > 0 {
+			stages[strings.ToLower(stage.Name)] = true
+		}
+	}
+
+	return images
+}
+
+// latest is a helper function to check if the
+// provided image uses the latest tag.
+func latest(image string) bool {
+	// skip images without a base image or using variables
+	if strings.EqualFold(image, "scratch") || strings.Contains(image, "$") {
+		return false
+	}
+
+	// skip images pinned to a digest
+	if strings.Contains(image, "@") {
+		return false
+	}
+
+	_, tag := splitTag(image)
+
+	return len(tag) == 0 || tag == "latest"
+}
+
+// cut is a helper function to slice the provided
+// string around the first instance of the separator.
+func cut(s, sep string) (string, string, bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+
+	return s, "", false
+}
+
+// remoteContext is a helper function to check if the
+// context for the build is not on the local filesystem
+// i.e. git://github.com/octocat/hello-world.git.
+func remoteContext(context string) bool {
+	return strings.Contains(context, "://")
+}
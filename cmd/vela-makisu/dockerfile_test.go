@@ -0,0 +1,195 @@
+// Copyright (c) 2022 Target Brands, Inc. All rights reserved.
+//
+// Use of this source code is governed by the LICENSE file in this repository.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+// testDockerfile represents a multi-stage Dockerfile for testing.
+const testDockerfile = `# syntax=docker/dockerfile:1
+ARG GO_VERSION=1.17
+ARG APP
+
+FROM --platform=linux/amd64 golang:${GO_VERSION} AS builder
+ARG GO_VERSION
+ARG VERSION
+RUN go build \
+    -ldflags "-X main.version=${VERSION}" \
+    -o /bin/app
+
+FROM alpine as release
+COPY --from=builder /bin/app /bin/app
+`
+
+func TestMakisu_parseDockerfile(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	_ = afero.WriteFile(appFS, "Dockerfile", []byte(testDockerfile), 0644)
+
+	// setup types
+	want := &Dockerfile{
+		Args: []*DockerfileArg{
+			{Default: true, Line: 2, Name: "GO_VERSION", Value: "1.17"},
+			{Default: false, Line: 3, Name: "APP"},
+		},
+		Path: "Dockerfile",
+		Stages: []*DockerfileStage{
+			{
+				Args: []*DockerfileArg{
+					{Default: false, Line: 6, Name: "GO_VERSION"},
+					{Default: false, Line: 7, Name: "VERSION"},
+				},
+				Image: "golang:${GO_VERSION}",
+				Line:  5,
+				Name:  "builder",
+			},
+			{
+				Copies: []*DockerfileCopy{
+					{From: "builder", Line: 13},
+				},
+				Image: "alpine",
+				Line:  12,
+				Name:  "release",
+			},
+		},
+	}
+
+	got, err := parseDockerfile("Dockerfile")
+	if err != nil {
+		t.Errorf("parseDockerfile returned err: %v", err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseDockerfile is %+v, want %+v", got, want)
+	}
+}
+
+func TestMakisu_parseDockerfile_Invalid(t *testing.T) {
+	// setup tests
+	tests := []string{
+		"",
+		"RUN echo hello\nFROM alpine\n",
+		"FROM alpine AS\n",
+		"FROM alpine\nARG\n",
+	}
+
+	// run tests
+	for _, test := range tests {
+		appFS = afero.NewMemMapFs()
+
+		_ = afero.WriteFile(appFS, "Dockerfile", []byte(test), 0644)
+
+		_, err := parseDockerfile("Dockerfile")
+		if err == nil {
+			t.Errorf("parseDockerfile should have returned err for %q", test)
+		}
+	}
+}
+
+func TestMakisu_Build_Preflight(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		build   *Build
+		failure bool
+	}{
+		{ // valid target and build args
+			build: &Build{
+				BuildArgs: []string{"VERSION=1.0.0", "APP=api", "UNUSED=true", "HTTP_PROXY=proxy"},
+				Context:   ".",
+				Target:    "builder",
+			},
+			failure: false,
+		},
+		{ // target matched without case sensitivity
+			build: &Build{
+				Context: ".",
+				Target:  "Release",
+			},
+			failure: false,
+		},
+		{ // missing target
+			build: &Build{
+				Context: ".",
+				Target:  "test",
+			},
+			failure: true,
+		},
+		{ // missing Dockerfile
+			build: &Build{
+				Context: ".",
+				File:    "Dockerfile.missing",
+			},
+			failure: true,
+		},
+		{ // missing Dockerfile from remote context skipped
+			build: &Build{
+				Context: "git://github.com/octocat/hello-world.git",
+				Target:  "test",
+			},
+			failure: false,
+		},
+		{ // missing Dockerfile from remote context with allowed base images
+			build: &Build{
+				AllowedBaseImages: []string{"docker.company.com/*"},
+				Context:           "git://github.com/octocat/hello-world.git",
+			},
+			failure: true,
+		},
+		{ // base image not allowed
+			build: &Build{
+				AllowedBaseImages: []string{"docker.company.com/*"},
+				Context:           ".",
+			},
+			failure: true,
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		appFS = afero.NewMemMapFs()
+
+		_ = afero.WriteFile(appFS, "Dockerfile", []byte(testDockerfile), 0644)
+
+		err := test.build.Preflight()
+
+		if test.failure && err == nil {
+			t.Errorf("Preflight should have returned err")
+		}
+
+		if !test.failure && err != nil {
+			t.Errorf("Preflight returned err: %v", err)
+		}
+	}
+}
+
+func TestMakisu_latest(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		image string
+		want  bool
+	}{
+		{image: "alpine", want: true},
+		{image: "alpine:latest", want: true},
+		{image: "localhost:5000/alpine", want: true},
+		{image: "alpine:3.15", want: false},
+		{image: "alpine@sha256:21a3deaa0d32a8057914f36584b5288d2e5ecc984380bc0118285c70fa8c9300", want: false},
+		{image: "golang:${GO_VERSION}", want: false},
+		{image: "scratch", want: false},
+	}
+
+	// run tests
+	for _, test := range tests {
+		got := latest(test.image)
+
+		if got != test.want {
+			t.Errorf("latest is %v for %s, want %v", got, test.image, test.want)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+// Copyright (c) 2022 Target Brands, Inc. All rights reserved.
+//
+// Use of this source code is governed by the LICENSE file in this repository.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+	"github.com/urfave/cli/v2"
+)
+
+// Source represents where the value for a parameter was loaded from.
+type Source struct {
+	// name of the flag for the parameter
+	Name string
+	// origin of the value i.e. "env PARAMETER_TAG" or "file /vela/parameters/..."
+	Origin string
+	// value provided for the parameter
+	Value string
+}
+
+// Explain outputs the resolved builder invocation and
+// configuration without building the image.
+func (p *Plugin) Explain(w io.Writer, sources []*Source) error {
+	logrus.Debug("explaining plugin with provided configuration")
+
+	// render the registry configuration
+	registryConf, err := p.Registry.Render()
+	if err != nil {
+		return err
+	}
+
+	// indent the registry configuration for readability
+	var config bytes.Buffer
+
+	err = json.Indent(&config, registryConf, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// set any configuration for global flags
+	p.Build.GlobalFlags = p.Global.Flags()
+
+	// set required configuration for registry config
+	p.Build.RegistryConfig = configPath
+
+	// variable to store builds for the images
+	builds := []*Build{p.Build}
+
+	// check if multiple images should be built
+	if len(p.Build.Images) > 0 {
+		builds, err = p.Build.Builds()
+		if err != nil {
+			return err
+		}
+	}
+
+	switch p.Action {
+	case promoteAction:
+		fmt.Fprintln(w, "explain mode is enabled - the image will not be promoted")
+
+		fmt.Fprintln(w, "\npromote:")
+
+		for _, target := range p.Promote.Targets {
+			fmt.Fprintf(w, "  %s -> %s\n", p.Promote.Source, target)
+		}
+	case pushTarballAction:
+		fmt.Fprintln(w, "explain mode is enabled - the image will not be pushed")
+
+		fmt.Fprintln(w, "\ncommand:")
+
+		// capture the backend for pushing the image
+		builder, err := newBuilder(p.Build.Builder)
+		if err != nil {
+			return err
+		}
+
+		// create the push command run for the tarball
+		cmd, err := builder.Push(p.Build, p.Build.Destination)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintln(w, "$", strings.Join(redactArgs(cmd.Args), " "))
+	default:
+		fmt.Fprintln(w, "explain mode is enabled - the image will not be built")
+
+		fmt.Fprintln(w, "\ncommand:")
+
+		for _, build := range builds {
+			// create the build command run for the image
+			cmd, cleanup, err := build.Command()
+
+			// remove any files created for the command
+			cleanup()
+
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintln(w, "$", strings.Join(redactArgs(cmd.Args), " "))
+		}
+	}
+
+	fmt.Fprintf(w, "\nregistry config (%s):\n", configPath)
+	fmt.Fprintln(w, redact(config.String()))
+
+	fmt.Fprintln(w, "\nparameters:")
+
+	for _, s := range sources {
+		value := redact(s.Value)
+
+		// check if the parameter contains credentials
+		if credentialFlags[s.Name] {
+			value = redacted
+		}
+
+		fmt.Fprintf(w, "  %s=%s (%s)\n", s.Name, value, s.Origin)
+	}
+
+	return nil
+}
+
+// flagSources is a helper function to capture
+// where the value for each flag was loaded from.
+func flagSources(c *cli.Context) []*Source {
+	// variable to store sources for the flags
+	var sources []*Source
+
+	for _, flag := range c.App.Flags {
+		name := flag.Names()[0]
+
+		// capture the origin of the flag value
+		origin := flagOrigin(flag)
+
+		// check if the flag was provided on the command line
+		if len(origin) == 0 && c.IsSet(name) {
+			origin = "command line"
+		}
+
+		// skip flags using the default value
+		if len(origin) == 0 {
+			continue
+		}
+
+		// capture the value of the flag
+		value := fmt.Sprintf("%v", c.Value(name))
+
+		// check if the flag is a list of values
+		if _, ok := flag.(*cli.StringSliceFlag); ok {
+			value = strings.Join(c.StringSlice(name), ",")
+		}
+
+		sources = append(sources, &Source{
+			Name:   name,
+			Origin: origin,
+			Value:  value,
+		})
+	}
+
+	return sources
+}
+
+// flagOrigin is a helper function to return the environment
+// variable or file the flag value was loaded from.
+//
+// The lookup mirrors the urfave/cli precedence where
+// environment variables are checked before files.
+func flagOrigin(flag cli.Flag) string {
+	// use custom filesystem which enables us to test
+	a := &afero.Afero{
+		Fs: appFS,
+	}
+
+	envVars, filePath := flagLookup(flag)
+
+	for _, env := range envVars {
+		if _, ok := os.LookupEnv(strings.TrimSpace(env)); ok {
+			return fmt.Sprintf("env %s", env)
+		}
+	}
+
+	for _, path := range strings.Split(filePath, ",") {
+		if ok, _ := a.Exists(path); ok && len(path) > 0 {
+			return fmt.Sprintf("file %s", path)
+		}
+	}
+
+	return ""
+}
+
+// flagLookup is a helper function to return the environment
+// variables and file paths the flag value is loaded from.
+func flagLookup(flag cli.Flag) ([]string, string) {
+	switch f := flag.(type) {
+	case *cli.BoolFlag:
+		return f.EnvVars, f.FilePath
+	case *cli.DurationFlag:
+		return f.EnvVars, f.FilePath
+	case *cli.IntFlag:
+		return f.EnvVars, f.FilePath
+	case *cli.StringFlag:
+		return f.EnvVars, f.FilePath
+	case *cli.StringSliceFlag:
+		return f.EnvVars, f.FilePath
+	default:
+		return nil, ""
+	}
+}
@@ -0,0 +1,257 @@
+// Copyright (c) 2022 Target Brands, Inc. All rights reserved.
+//
+// Use of this source code is governed by the LICENSE file in this repository.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/urfave/cli/v2"
+)
+
+func TestMakisu_Plugin_Explain(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	// setup types
+	secrets = nil
+
+	p := &Plugin{
+		Build: &Build{
+			Context:    ".",
+			Docker:     &Docker{},
+			HTTPCache:  &HTTPCache{},
+			Pushes:     []string{"index.docker.io"},
+			RedisCache: &RedisCache{Addr: "redis.company.com", Password: "superSecret123"},
+			Tag:        "octocat/hello-world:latest",
+		},
+		Global: &Global{
+			CPU: &CPU{},
+			Log: &Log{Fmt: "console"},
+		},
+		Registry: &Registry{
+			Name:     "index.docker.io",
+			Password: "superSecretPassword",
+			Username: "octocat",
+		},
+	}
+
+	addSecrets(p.Secrets()...)
+
+	sources := []*Source{
+		{Name: "build.tag", Origin: "env PARAMETER_TAG", Value: "octocat/hello-world:latest"},
+		{
+			Name:   "registry.docker-config",
+			Origin: "env PARAMETER_DOCKER_CONFIG",
+			Value:  `{"auths":{"registry.company.com":{"auth":"b2N0b2NhdDpzdXBlclNlY3JldFBhc3M="}}}`,
+		},
+		{Name: "build.redis-cache-options", Origin: "env PARAMETER_REDIS_CACHE", Value: `{"password":"super\"Secret"}`},
+	}
+
+	var w bytes.Buffer
+
+	err := p.Explain(&w, sources)
+	if err != nil {
+		t.Errorf("Explain returned err: %v", err)
+	}
+
+	got := w.String()
+
+	for _, want := range []string{
+		"$ /bin/makisu build --log-fmt=console --push index.docker.io --redis-cache-addr redis.company.com --redis-cache-password *** --registry-config /makisu/registry/config.json --tag octocat/hello-world:latest .",
+		`"password": "***"`,
+		"build.tag=octocat/hello-world:latest (env PARAMETER_TAG)",
+		"registry.docker-config=*** (env PARAMETER_DOCKER_CONFIG)",
+		"build.redis-cache-options=*** (env PARAMETER_REDIS_CACHE)",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Explain is %s, want %s", got, want)
+		}
+	}
+
+	for _, secret := range []string{"superSecret123", "superSecretPassword", "b2N0b2NhdDpzdXBlclNlY3JldFBhc3M=", "Secret\"}"} {
+		if strings.Contains(got, secret) {
+			t.Errorf("Explain is %s, should not contain %s", got, secret)
+		}
+	}
+
+	// verify the registry config was not written
+	if ok, _ := afero.Exists(appFS, configPath); ok {
+		t.Errorf("Explain should not have written %s", configPath)
+	}
+}
+
+func TestMakisu_Plugin_Explain_Exec(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	_ = afero.WriteFile(appFS, "Dockerfile", []byte("FROM alpine\n"), 0644)
+
+	// setup types
+	p := &Plugin{
+		Build: &Build{
+			Context:    ".",
+			Docker:     &Docker{},
+			HTTPCache:  &HTTPCache{},
+			Labels:     []string{"foo=bar"},
+			Pushes:     []string{"index.docker.io"},
+			RedisCache: &RedisCache{},
+			Replicas:   []string{"index.docker.io/octocat/hello-world:1"},
+			Scan:       &Scan{},
+			Tag:        "octocat/hello-world:latest",
+		},
+		Global: &Global{
+			CPU: &CPU{},
+			Log: &Log{},
+		},
+		Registry: &Registry{Name: "index.docker.io"},
+	}
+
+	var w bytes.Buffer
+
+	err := p.Explain(&w, nil)
+	if err != nil {
+		t.Errorf("Explain returned err: %v", err)
+	}
+
+	got := w.String()
+
+	// the command is built from the copy of the Dockerfile with the labels
+	if !strings.Contains(got, "--file "+filepath.Join(os.TempDir(), "vela-makisu-labels-")) {
+		t.Errorf("Explain is %s, want the Dockerfile with the labels", got)
+	}
+
+	// the image is not pushed until it is scanned
+	if strings.Contains(got, "--push") || strings.Contains(got, "--replica") {
+		t.Errorf("Explain is %s, should not push the image before it is scanned", got)
+	}
+
+	// verify the copy of the Dockerfile was removed
+	dirs, _ := afero.Glob(appFS, filepath.Join(os.TempDir(), "vela-makisu-labels-*"))
+	if len(dirs) > 0 {
+		t.Errorf("Explain should have removed %v", dirs)
+	}
+}
+
+func TestMakisu_Plugin_Explain_Promote(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	// setup types
+	p := &Plugin{
+		Action: promoteAction,
+		Build:  &Build{},
+		Global: &Global{
+			CPU: &CPU{},
+			Log: &Log{},
+		},
+		Promote: &Promote{
+			Source:  "index.docker.io/octocat/hello-world:staging",
+			Targets: []string{"index.docker.io/octocat/hello-world:prod"},
+		},
+		Registry: &Registry{Name: "index.docker.io"},
+	}
+
+	var w bytes.Buffer
+
+	err := p.Explain(&w, nil)
+	if err != nil {
+		t.Errorf("Explain returned err: %v", err)
+	}
+
+	got := w.String()
+
+	want := "index.docker.io/octocat/hello-world:staging -> index.docker.io/octocat/hello-world:prod"
+	if !strings.Contains(got, want) {
+		t.Errorf("Explain is %s, want %s", got, want)
+	}
+
+	if strings.Contains(got, "/bin/makisu build") {
+		t.Errorf("Explain is %s, should not contain the build command", got)
+	}
+}
+
+func TestMakisu_Plugin_Explain_PushTarball(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	// setup types
+	p := &Plugin{
+		Action: pushTarballAction,
+		Build: &Build{
+			Destination: "image.tar",
+			Replicas:    []string{"docker.company.com/octocat/hello-world:1"},
+			Tag:         "index.docker.io/octocat/hello-world:latest",
+		},
+		Global: &Global{
+			CPU: &CPU{},
+			Log: &Log{},
+		},
+		Registry: &Registry{Name: "index.docker.io"},
+	}
+
+	var w bytes.Buffer
+
+	err := p.Explain(&w, nil)
+	if err != nil {
+		t.Errorf("Explain returned err: %v", err)
+	}
+
+	got := w.String()
+
+	want := "$ /bin/makisu push --registry-config /makisu/registry/config.json " +
+		"--replica index.docker.io/octocat/hello-world:latest --replica docker.company.com/octocat/hello-world:1 " +
+		"--tag index.docker.io/octocat/hello-world:latest image.tar"
+	if !strings.Contains(got, want) {
+		t.Errorf("Explain is %s, want %s", got, want)
+	}
+}
+
+func TestMakisu_flagOrigin(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	_ = afero.WriteFile(appFS, "/vela/secrets/makisu/build/tag", []byte("latest"), 0644)
+
+	// setup types
+	flag := &cli.StringFlag{
+		EnvVars:  []string{"PARAMETER_TAG"},
+		FilePath: "/vela/parameters/makisu/build/tag,/vela/secrets/makisu/build/tag",
+		Name:     "build.tag",
+	}
+
+	got := flagOrigin(flag)
+	if got != "file /vela/secrets/makisu/build/tag" {
+		t.Errorf("flagOrigin is %s, want file /vela/secrets/makisu/build/tag", got)
+	}
+
+	t.Setenv("PARAMETER_TAG", "latest")
+
+	got = flagOrigin(flag)
+	if got != "env PARAMETER_TAG" {
+		t.Errorf("flagOrigin is %s, want env PARAMETER_TAG", got)
+	}
+}
+
+func TestMakisu_flagOrigin_Default(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	// setup types
+	flag := &cli.BoolFlag{
+		EnvVars:  []string{"PARAMETER_FOO"},
+		FilePath: "/vela/parameters/makisu/foo",
+		Name:     "foo",
+	}
+
+	got := flagOrigin(flag)
+	if len(got) > 0 {
+		t.Errorf("flagOrigin is %s, want empty origin", got)
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright (c) 2022 Target Brands, Inc. All rights reserved.
+//
+// Use of this source code is governed by the LICENSE file in this repository.
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Image represents the configuration for
+// one of multiple images built in a step.
+type Image struct {
+	// enables setting build time arguments for the Dockerfile
+	BuildArgs []string `json:"build_args"`
+	// enables setting the context for the image to be built
+	Context string `json:"context"`
+	// enables setting a the absolute path to dockerfile
+	File string `json:"file"`
+	// enables setting registries to push the image to
+	Pushes []string `json:"pushes"`
+	// enables setting the tag for an image
+	Tag string `json:"tag"`
+	// enables setting the target build stage to build
+	Target string `json:"target"`
+}
+
+// Builds outputs the configuration for building each of the
+// images with the settings of the Build used as defaults.
+func (b *Build) Builds() ([]*Build, error) {
+	logrus.Trace("creating builds for the images")
+
+	// variable to store builds for the images
+	builds := make([]*Build, 0, len(b.Images))
+
+	for i := range b.Images {
+		build := b.image(i)
+
+		// check if tags should be derived from the build metadata
+		if b.AutoTag {
+			err := build.Tags()
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		builds = append(builds, build)
+	}
+
+	return builds, nil
+}
+
+// image is a helper function to return the configuration for building
+// the image at the index with the settings of the Build used as defaults.
+func (b *Build) image(i int) *Build {
+	image := b.Images[i]
+
+	// copy the settings for the image
+	build := *b
+
+	build.Images = nil
+	build.Replicas = nil
+
+	// the secrets are staged once for all of the images
+	build.BuildSecrets = nil
+	build.Tag = image.Tag
+
+	// add the build args for the image after the shared build args
+	build.BuildArgs = append(append([]string{}, b.BuildArgs...), image.BuildArgs...)
+
+	// check if Context is provided
+	if len(image.Context) > 0 {
+		build.Context = image.Context
+	}
+
+	// check if File is provided
+	if len(image.File) > 0 {
+		build.File = image.File
+	}
+
+	// check if Pushes is provided
+	if len(image.Pushes) > 0 {
+		build.Pushes = image.Pushes
+	}
+
+	// check if Target is provided
+	if len(image.Target) > 0 {
+		build.Target = image.Target
+	}
+
+	// check if Destination is provided
+	//
+	// the paths derived from the Destination i.e. the software
+	// bill of materials are separated with the Destination
+	if len(b.Destination) > 0 {
+		build.Destination = imagePath(b.Destination, i)
+	}
+
+	// check if ProvenancePath is provided
+	if len(b.ProvenancePath) > 0 {
+		build.ProvenancePath = imagePath(b.ProvenancePath, i)
+	}
+
+	// check if the scan report path is provided
+	if b.Scan != nil && len(b.Scan.Report) > 0 {
+		scan := *b.Scan
+
+		scan.Report = imagePath(b.Scan.Report, i)
+		build.Scan = &scan
+	}
+
+	// check if ReportPath is provided
+	if len(b.ReportPath) > 0 {
+		build.ReportPath = imagePath(b.ReportPath, i)
+	}
+
+	// check if ResultsPath is provided
+	if len(b.ResultsPath) > 0 {
+		build.ResultsPath = imagePath(b.ResultsPath, i)
+	}
+
+	return &build
+}
+
+// ExecImages formats and runs the commands for
+// building each of the images sequentially.
+//
+// The images are not built in parallel since makisu
+// modifies the filesystem of the container while building.
+func (b *Build) ExecImages() error {
+	logrus.Trace("running builds for the images")
+
+	builds, err := b.Builds()
+	if err != nil {
+		return err
+	}
+
+	// stage the secrets for the images
+	cleanup, err := b.StageSecrets()
+	defer cleanup()
+
+	if err != nil {
+		return err
+	}
+
+	// share the signals and timeout for the step with every image
+	ctx, cancel := b.execContext()
+	defer cancel()
+
+	// variable to store the errors for the images
+	var errs []string
+
+	for i, build := range builds {
+		logrus.Infof("building image %d/%d: %s", i+1, len(builds), build.Tag)
+
+		build.ctx = ctx
+
+		// build the image
+		err = build.Exec()
+		if err != nil {
+			logrus.Errorf("unable to build image %s: %v", build.Tag, err)
+
+			errs = append(errs, fmt.Sprintf("%s: %v", build.Tag, err))
+		}
+
+		// check if the step was cancelled
+		if ctx.Err() != nil {
+			return fmt.Errorf("step was cancelled (%v) after building %d/%d images: %s",
+				ctx.Err(), i+1, len(builds), strings.Join(errs, "; "))
+		}
+	}
+
+	// check if any images failed to build
+	if len(errs) > 0 {
+		return fmt.Errorf("unable to build %d/%d images: %s", len(errs), len(builds), strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+// Validate verifies the Image is properly configured.
+func (i *Image) Validate() error {
+	// verify tag is provided
+	if len(i.Tag) == 0 {
+		return fmt.Errorf("no tag provided for image")
+	}
+
+	return nil
+}
+
+// imagePath is a helper function to return the path
+// for the file written for the image at the index.
+//
+// i.e. ".makisu/results.json" becomes ".makisu/results-1.json".
+func imagePath(path string, index int) string {
+	ext := filepath.Ext(path)
+
+	return fmt.Sprintf("%s-%d%s", strings.TrimSuffix(path, ext), index+1, ext)
+}
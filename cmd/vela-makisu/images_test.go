@@ -0,0 +1,274 @@
+// Copyright (c) 2022 Target Brands, Inc. All rights reserved.
+//
+// Use of this source code is governed by the LICENSE file in this repository.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+func TestMakisu_Build_Builds(t *testing.T) {
+	// setup types
+	b := &Build{
+		BuildArgs: []string{"FOO=bar"},
+		Context:   ".",
+		Images: []*Image{
+			{
+				BuildArgs: []string{"APP=api"},
+				Context:   "api",
+				Tag:       "octocat/api:latest",
+			},
+			{
+				File:   "web/Dockerfile.prod",
+				Pushes: []string{"docker.company.com"},
+				Tag:    "octocat/web:latest",
+				Target: "prod",
+			},
+		},
+		Destination:    "image.tar",
+		ProvenancePath: "provenance.json",
+		Pushes:         []string{"index.docker.io"},
+		ResultsPath:    ".makisu/results.json",
+		Scan:           &Scan{Report: "vulnerabilities.json", Severity: "high"},
+		Replicas:       []string{"index.docker.io/octocat/hello-world:1"},
+		Storage:        "/tmp/makisu",
+		Tag:            "octocat/hello-world:latest",
+	}
+
+	want := []*Build{
+		{
+			BuildArgs:      []string{"FOO=bar", "APP=api"},
+			Context:        "api",
+			Destination:    "image-1.tar",
+			ProvenancePath: "provenance-1.json",
+			Pushes:         []string{"index.docker.io"},
+			ResultsPath:    ".makisu/results-1.json",
+			Scan:           &Scan{Report: "vulnerabilities-1.json", Severity: "high"},
+			Storage:        "/tmp/makisu",
+			Tag:            "octocat/api:latest",
+		},
+		{
+			BuildArgs:      []string{"FOO=bar"},
+			Context:        ".",
+			Destination:    "image-2.tar",
+			File:           "web/Dockerfile.prod",
+			ProvenancePath: "provenance-2.json",
+			Pushes:         []string{"docker.company.com"},
+			ResultsPath:    ".makisu/results-2.json",
+			Scan:           &Scan{Report: "vulnerabilities-2.json", Severity: "high"},
+			Storage:        "/tmp/makisu",
+			Tag:            "octocat/web:latest",
+			Target:         "prod",
+		},
+	}
+
+	got, err := b.Builds()
+	if err != nil {
+		t.Errorf("Builds returned err: %v", err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Builds is %+v, want %+v", got, want)
+	}
+}
+
+// testImagesBuilder represents a builder
+// tracking the builds running at once.
+type testImagesBuilder struct {
+	mu      sync.Mutex
+	running int
+	max     int
+	tags    []string
+}
+
+func (t *testImagesBuilder) Command(b *Build) (*exec.Cmd, func(), error) {
+	return exec.Command("echo", b.Tag), func() {}, nil
+}
+
+func (t *testImagesBuilder) Exec(b *Build) error {
+	t.mu.Lock()
+	t.running++
+	t.tags = append(t.tags, b.Tag)
+
+	if t.running > t.max {
+		t.max = t.running
+	}
+
+	t.mu.Unlock()
+
+	time.Sleep(50 * time.Millisecond)
+
+	t.mu.Lock()
+	t.running--
+	t.mu.Unlock()
+
+	// fail the build for the image
+	if b.Tag == "octocat/fail:latest" {
+		return errors.New("unsupported directive")
+	}
+
+	return nil
+}
+
+func (t *testImagesBuilder) Push(b *Build, path string) (*exec.Cmd, error) {
+	return exec.Command("echo", b.Tag), nil
+}
+
+func (t *testImagesBuilder) Version() *exec.Cmd {
+	return exec.Command("echo")
+}
+
+func TestMakisu_Build_ExecImages(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		images  []*Image
+		failure bool
+	}{
+		{ // sequential builds
+			images:  []*Image{{Tag: "octocat/api:latest"}, {Tag: "octocat/web:latest"}, {Tag: "octocat/worker:latest"}},
+			failure: false,
+		},
+		{ // remaining images built after a failure
+			images:  []*Image{{Tag: "octocat/fail:latest"}, {Tag: "octocat/web:latest"}},
+			failure: true,
+		},
+	}
+
+	defer delete(builders, "test")
+
+	// run tests
+	for _, test := range tests {
+		builder := new(testImagesBuilder)
+		builders["test"] = builder
+
+		b := &Build{
+			Builder: "test",
+			Images:  test.images,
+		}
+
+		err := b.ExecImages()
+
+		if test.failure && err == nil {
+			t.Errorf("ExecImages should have returned err")
+		}
+
+		if !test.failure && err != nil {
+			t.Errorf("ExecImages returned err: %v", err)
+		}
+
+		if builder.max != 1 {
+			t.Errorf("ExecImages ran %d builds at once, want 1", builder.max)
+		}
+
+		if len(builder.tags) != len(test.images) {
+			t.Errorf("ExecImages built %d images, want %d", len(builder.tags), len(test.images))
+		}
+	}
+}
+
+func TestMakisu_Build_ExecImages_Timeout(t *testing.T) {
+	// setup types
+	builder := new(testImagesBuilder)
+
+	builders["test"] = builder
+	defer delete(builders, "test")
+
+	b := &Build{
+		Builder: "test",
+		Images:  []*Image{{Tag: "octocat/api:latest"}, {Tag: "octocat/web:latest"}, {Tag: "octocat/worker:latest"}},
+		Timeout: 10 * time.Millisecond,
+	}
+
+	err := b.ExecImages()
+	if err == nil {
+		t.Errorf("ExecImages should have returned err")
+	}
+
+	// the remaining images are not built after the timeout expires
+	if len(builder.tags) != 1 {
+		t.Errorf("ExecImages built %d images, want 1", len(builder.tags))
+	}
+}
+
+func TestMakisu_Build_ExecImages_Results(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	// setup types
+	s := testRegistry(t)
+
+	host := strings.TrimPrefix(s.URL, "http://")
+
+	defer delete(builders, "test")
+
+	builders["test"] = new(testImagesBuilder)
+
+	b := &Build{
+		Builder: "test",
+		Images: []*Image{
+			{Tag: "octocat/api:latest"},
+			{Tag: "octocat/web:latest"},
+			{Tag: "octocat/worker:latest"},
+			{Tag: "octocat/cron:latest"},
+		},
+		Pushes:         []string{host},
+		RegistryConfig: fmt.Sprintf(`{%q: {".*": {"security": {"tls": {"client": {"disabled": true}}}}}}`, host),
+		ResultsPath:    ".makisu/results.json",
+	}
+
+	err := b.ExecImages()
+	if err != nil {
+		t.Errorf("ExecImages returned err: %v", err)
+	}
+
+	for i := range b.Images {
+		path := imagePath(b.ResultsPath, i)
+
+		if ok, _ := afero.Exists(appFS, path); !ok {
+			t.Errorf("ExecImages should have written %s", path)
+		}
+	}
+}
+
+func TestMakisu_Image_Validate(t *testing.T) {
+	// setup types
+	i := &Image{
+		Context: "api",
+	}
+
+	err := i.Validate()
+	if err == nil {
+		t.Errorf("Validate should have returned err")
+	}
+}
+
+func TestMakisu_imagePath(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		path  string
+		index int
+		want  string
+	}{
+		{path: ".makisu/results.json", index: 0, want: ".makisu/results-1.json"},
+		{path: "report", index: 2, want: "report-3"},
+	}
+
+	// run tests
+	for _, test := range tests {
+		got := imagePath(test.path, test.index)
+
+		if got != test.want {
+			t.Errorf("imagePath is %s, want %s", got, test.want)
+		}
+	}
+}
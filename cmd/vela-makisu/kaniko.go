@@ -0,0 +1,309 @@
+// Copyright (c) 2022 Target Brands, Inc. All rights reserved.
+//
+// Use of this source code is governed by the LICENSE file in this repository.
+
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+	"github.com/uber/makisu/lib/registry"
+)
+
+const (
+	// _kaniko is the path to the kaniko executor binary in the image.
+	_kaniko = "/kaniko/executor"
+
+	// kanikoDockerHub represents the key kaniko expects for docker hub authentication.
+	kanikoDockerHub = "https://index.docker.io/v1/"
+)
+
+// kanikoConfigPath represents the location of the Docker config file read by kaniko.
+var kanikoConfigPath = "/kaniko/.docker/config.json"
+
+// Kaniko represents the builder backend for building
+// and publishing images with the kaniko executor.
+//
+// Kaniko documents their command usage:
+// https://github.com/GoogleContainerTools/kaniko#additional-flags
+type Kaniko struct{}
+
+// Command formats and outputs the kaniko build command from
+// the provided configuration to build a Docker image.
+func (k *Kaniko) Command(b *Build) (*exec.Cmd, func(), error) {
+	logrus.Trace("creating kaniko build command from plugin configuration")
+
+	// variable to store flags for command
+	var flags []string
+
+	// check if BuildArgs is provided
+	if len(b.BuildArgs) > 0 {
+		for _, arg := range b.BuildArgs {
+			// add flag for BuildArgs from provided build command
+			flags = append(flags, "--build-arg", arg)
+		}
+	}
+
+	// add the required context param
+	flags = append(flags, "--context", b.Context)
+
+	// check if DenyList is provided
+	if len(b.DenyList) > 0 {
+		for _, d := range b.DenyList {
+			// add flag for DenyList from provided build command
+			flags = append(flags, "--ignore-path", d)
+		}
+	}
+
+	// add flags for each destination of the image
+	for _, d := range k.destinations(b) {
+		flags = append(flags, "--destination", d)
+	}
+
+	// check if Destination is provided
+	if len(b.Destination) > 0 {
+		// add flag for Destination from provided build command
+		flags = append(flags, "--tar-path", b.Destination)
+	}
+
+	// check if File is provided
+	if len(b.File) > 0 {
+		// add flag for File from provided build command
+		flags = append(flags, "--dockerfile", b.File)
+	}
+
+	// capture the labels for the image
+	labels, err := b.ImageLabels()
+	if err != nil {
+		return nil, func() {}, err
+	}
+
+	for _, key := range labelKeys(labels) {
+		// add flag for Labels from provided build command
+		flags = append(flags, "--label", fmt.Sprintf("%s=%s", key, labels[key]))
+	}
+
+	// check if Pushes is provided
+	if len(b.Pushes) == 0 {
+		// add flag for skipping the push of the image
+		flags = append(flags, "--no-push")
+	}
+
+	// check if Target is provided
+	if len(b.Target) > 0 {
+		// add flag for Target from provided build command
+		flags = append(flags, "--target", b.Target)
+	}
+
+	// check if any makisu specific parameters were provided
+	for _, param := range k.ignored(b) {
+		logrus.Warnf("parameter %s is not supported by the kaniko builder and will be ignored", param)
+	}
+
+	// nolint: gosec // this functionality is not exploitable the way
+	// the plugin accepts configuration
+	return exec.Command(_kaniko, flags...), func() {}, nil
+}
+
+// Exec formats and runs the kaniko commands for building a Docker image.
+func (k *Kaniko) Exec(b *Build) error {
+	logrus.Trace("running kaniko build with provided configuration")
+
+	// create Docker config for authentication to a registry
+	err := k.Write(b.RegistryConfig)
+	if err != nil {
+		return err
+	}
+
+	// create the build command for the file
+	cmd, cleanup, err := b.command(k)
+	defer cleanup()
+
+	if err != nil {
+		return err
+	}
+
+	// capture the output for classifying a failed build
+	b.capture(cmd)
+
+	// run the build command for the file
+	return execCmdContext(b.context(), cmd, b.GracePeriod)
+}
+
+// Push outputs an error since kaniko is not
+// capable of pushing an image from a tarball.
+func (k *Kaniko) Push(*Build, string) (*exec.Cmd, error) {
+	return nil, fmt.Errorf("pushing an image tarball is not supported by the kaniko builder")
+}
+
+// Version outputs the kaniko command for
+// displaying the version information.
+func (k *Kaniko) Version() *exec.Cmd {
+	logrus.Trace("creating kaniko version command")
+
+	return exec.Command(_kaniko, "version")
+}
+
+// Write converts the provided makisu registry configuration into a
+// Docker config.json file for authenticating kaniko to registries.
+func (k *Kaniko) Write(registryConfig string) error {
+	logrus.Trace("creating kaniko docker configuration file")
+
+	// use custom filesystem which enables us to test
+	a := &afero.Afero{
+		Fs: appFS,
+	}
+
+	// allocate a config registry map
+	config := make(registry.Map)
+
+	// capture the raw registry configuration
+	raw := []byte(registryConfig)
+
+	// check if the registry configuration is a path to a file
+	if len(registryConfig) > 0 && !json.Valid(raw) {
+		var err error
+
+		raw, err = a.ReadFile(registryConfig)
+		if err != nil {
+			return err
+		}
+	}
+
+	// check if any registry configuration was provided
+	if len(raw) > 0 {
+		err := json.Unmarshal(raw, &config)
+		if err != nil {
+			return err
+		}
+	}
+
+	// allocate the Docker config
+	docker := &dockerConfig{
+		Auths: make(map[string]dockerAuth),
+	}
+
+	for name, repos := range config {
+		// sort the repositories to pick the same credentials for every build
+		//
+		// kaniko only supports one set of credentials for each registry
+		keys := make([]string, 0, len(repos))
+		for key := range repos {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			repo := repos[key]
+
+			// skip repositories without basic authentication
+			if repo.Security.BasicAuth == nil || len(repo.Security.BasicAuth.Username) == 0 {
+				continue
+			}
+
+			// kaniko expects the legacy address for docker hub
+			if strings.EqualFold(name, "index.docker.io") {
+				name = kanikoDockerHub
+			}
+
+			// encode the credentials for the registry
+			docker.Auths[name] = dockerAuth{
+				Auth: base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf(
+					"%s:%s",
+					repo.Security.BasicAuth.Username,
+					repo.Security.BasicAuth.Password,
+				))),
+			}
+
+			break
+		}
+	}
+
+	dockerConf, err := json.Marshal(docker)
+	if err != nil {
+		return err
+	}
+
+	return a.WriteFile(kanikoConfigPath, dockerConf, 0600)
+}
+
+// destinations is a helper function to return the
+// full references the image should be pushed to.
+func (k *Kaniko) destinations(b *Build) []string {
+	// check if no registries to push to were provided
+	if len(b.Pushes) == 0 {
+		return append([]string{b.Tag}, b.Replicas...)
+	}
+
+	return b.References()
+}
+
+// ignored is a helper function to return the makisu specific
+// parameters provided that are not supported by kaniko.
+func (k *Kaniko) ignored(b *Build) []string {
+	// variable to store the ignored parameters
+	var params []string
+
+	if len(b.Commit) > 0 {
+		params = append(params, "commit")
+	}
+
+	if len(b.Compression) > 0 {
+		params = append(params, "compression")
+	}
+
+	if b.Docker != nil && len(b.Docker.Flags()) > 0 {
+		params = append(params, "docker")
+	}
+
+	// the log format is always provided to makisu so it is not reported
+	for _, flag := range b.GlobalFlags {
+		if !strings.HasPrefix(flag, "--log-fmt") {
+			params = append(params, "global flags")
+
+			break
+		}
+	}
+
+	if b.HTTPCache != nil && len(b.HTTPCache.Flags()) > 0 {
+		params = append(params, "http_cache")
+	}
+
+	if b.Load {
+		params = append(params, "load")
+	}
+
+	if b.LocalCacheTTL > 0 {
+		params = append(params, "local_cache_ttl")
+	}
+
+	if b.ModifyFS {
+		params = append(params, "modify_fs")
+	}
+
+	if b.ParseLogs {
+		params = append(params, "parse_logs")
+	}
+
+	if b.PreserveRoot {
+		params = append(params, "preserve_root")
+	}
+
+	if b.RedisCache != nil && len(b.RedisCache.Addr) > 0 {
+		params = append(params, "redis_cache")
+	}
+
+	if len(b.Storage) > 0 {
+		params = append(params, "storage")
+	}
+
+	return params
+}
@@ -0,0 +1,219 @@
+// Copyright (c) 2022 Target Brands, Inc. All rights reserved.
+//
+// Use of this source code is governed by the LICENSE file in this repository.
+
+package main
+
+import (
+	"os/exec"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+func TestMakisu_Kaniko_Command(t *testing.T) {
+	// setup types
+	k := &Kaniko{}
+
+	b := &Build{
+		BuildArgs:   []string{"FOO"},
+		Context:     ".",
+		DenyList:    []string{"FOO"},
+		Docker:      &Docker{},
+		Destination: "/path/to/dest",
+		File:        "Dockerfile",
+		HTTPCache:   &HTTPCache{},
+		Labels:      []string{"foo=bar"},
+		Pushes:      []string{"index.docker.io"},
+		RedisCache:  &RedisCache{},
+		Replicas:    []string{"index.docker.io/octocat/hello-world:1"},
+		Tag:         "octocat/hello-world:latest",
+		Target:      "dev",
+	}
+
+	// nolint: gosec // this functionality is not exploitable the way
+	// the plugin accepts configuration
+	want := exec.Command(
+		_kaniko,
+		"--build-arg", b.BuildArgs[0],
+		"--context", b.Context,
+		"--ignore-path", b.DenyList[0],
+		"--destination", "index.docker.io/octocat/hello-world:latest",
+		"--destination", b.Replicas[0],
+		"--tar-path", b.Destination,
+		"--dockerfile", b.File,
+		"--label", b.Labels[0],
+		"--target", b.Target,
+	)
+
+	got, _, err := k.Command(b)
+	if err != nil {
+		t.Errorf("Command returned err: %v", err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Command is %v, want %v", got, want)
+	}
+}
+
+func TestMakisu_Kaniko_Command_NoPush(t *testing.T) {
+	// setup types
+	k := &Kaniko{}
+
+	b := &Build{
+		Context:    ".",
+		Docker:     &Docker{},
+		HTTPCache:  &HTTPCache{},
+		RedisCache: &RedisCache{},
+		Tag:        "index.docker.io/octocat/hello-world:latest",
+	}
+
+	// nolint: gosec // this functionality is not exploitable the way
+	// the plugin accepts configuration
+	want := exec.Command(
+		_kaniko,
+		"--context", b.Context,
+		"--destination", b.Tag,
+		"--no-push",
+	)
+
+	got, _, err := k.Command(b)
+	if err != nil {
+		t.Errorf("Command returned err: %v", err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Command is %v, want %v", got, want)
+	}
+}
+
+func TestMakisu_Kaniko_ignored(t *testing.T) {
+	// setup types
+	k := &Kaniko{}
+
+	// setup tests
+	tests := []struct {
+		build *Build
+		want  []string
+	}{
+		{
+			build: &Build{
+				Docker:      &Docker{},
+				GlobalFlags: []string{"--log-fmt=console"},
+				HTTPCache:   &HTTPCache{},
+				RedisCache:  &RedisCache{},
+			},
+			want: nil,
+		},
+		{
+			build: &Build{
+				Commit:        "abc123",
+				Compression:   "size",
+				Docker:        &Docker{Host: "unix:///var/run/docker.sock"},
+				GlobalFlags:   []string{"--log-fmt=json", "--log-level=debug"},
+				HTTPCache:     &HTTPCache{Addr: "cache.company.com"},
+				Load:          true,
+				LocalCacheTTL: time.Hour,
+				ModifyFS:      true,
+				ParseLogs:     true,
+				PreserveRoot:  true,
+				RedisCache:    &RedisCache{Addr: "redis.company.com"},
+				Storage:       "/tmp/makisu",
+			},
+			want: []string{
+				"commit", "compression", "docker", "global flags", "http_cache", "load",
+				"local_cache_ttl", "modify_fs", "parse_logs", "preserve_root", "redis_cache", "storage",
+			},
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		got := k.ignored(test.build)
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ignored is %v, want %v", got, test.want)
+		}
+	}
+}
+
+func TestMakisu_Kaniko_Version(t *testing.T) {
+	// setup types
+	k := &Kaniko{}
+
+	want := exec.Command(_kaniko, "version")
+
+	got := k.Version()
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Version is %v, want %v", got, want)
+	}
+}
+
+func TestMakisu_Kaniko_Write(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	// setup types
+	k := &Kaniko{}
+
+	r := &Registry{
+		Name:     "index.docker.io",
+		Password: "superSecretPassword",
+		Username: "octocat",
+	}
+
+	err := r.Write()
+	if err != nil {
+		t.Errorf("Write returned err: %v", err)
+	}
+
+	want := `{"auths":{"https://index.docker.io/v1/":{"auth":"b2N0b2NhdDpzdXBlclNlY3JldFBhc3N3b3Jk"}}}`
+
+	err = k.Write(configPath)
+	if err != nil {
+		t.Errorf("Write returned err: %v", err)
+	}
+
+	got, err := afero.ReadFile(appFS, kanikoConfigPath)
+	if err != nil {
+		t.Errorf("ReadFile returned err: %v", err)
+	}
+
+	if string(got) != want {
+		t.Errorf("Write is %s, want %s", got, want)
+	}
+}
+
+func TestMakisu_Kaniko_Write_MultipleRepos(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	// setup types
+	k := &Kaniko{}
+
+	config := `{"docker.company.com":{` +
+		`"octocat/.*":{"security":{"basic":{"username":"octocat","password":"superSecretPassword"}}},` +
+		`".*":{"security":{"basic":{"username":"foo","password":"bar"}}}}}`
+
+	want := `{"auths":{"docker.company.com":{"auth":"Zm9vOmJhcg=="}}}`
+
+	// the credentials must not depend on the order of the map
+	for i := 0; i < 10; i++ {
+		err := k.Write(config)
+		if err != nil {
+			t.Errorf("Write returned err: %v", err)
+		}
+
+		got, err := afero.ReadFile(appFS, kanikoConfigPath)
+		if err != nil {
+			t.Errorf("ReadFile returned err: %v", err)
+		}
+
+		if string(got) != want {
+			t.Errorf("Write is %s, want %s", got, want)
+		}
+	}
+}
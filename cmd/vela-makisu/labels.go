@@ -0,0 +1,181 @@
+// Copyright (c) 2022 Target Brands, Inc. All rights reserved.
+//
+// Use of this source code is governed by the LICENSE file in this repository.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+)
+
+// autoLabels is a helper function to derive the OCI
+// image labels from the Vela build metadata.
+//
+// https://github.com/opencontainers/image-spec/blob/main/annotations.md
+func autoLabels() map[string]string {
+	logrus.Trace("deriving labels from vela build metadata")
+
+	// capture the time the image was created
+	created := time.Now().UTC()
+
+	// use the time the build was created when provided
+	unix, err := strconv.ParseInt(os.Getenv("VELA_BUILD_CREATED"), 10, 64)
+	if err == nil && unix > 0 {
+		created = time.Unix(unix, 0).UTC()
+	}
+
+	labels := map[string]string{
+		"org.opencontainers.image.created":  created.Format(time.RFC3339),
+		"org.opencontainers.image.revision": os.Getenv("VELA_BUILD_COMMIT"),
+		"org.opencontainers.image.source":   os.Getenv("VELA_REPO_LINK"),
+		"org.opencontainers.image.version":  os.Getenv("VELA_BUILD_TAG"),
+		"io.vela.build.link":                os.Getenv("VELA_BUILD_LINK"),
+	}
+
+	// remove labels without a value
+	for key, value := range labels {
+		if len(value) == 0 {
+			delete(labels, key)
+		}
+	}
+
+	return labels
+}
+
+// parseLabels is a helper function to convert
+// a list of "key=value" pairs into labels.
+func parseLabels(pairs []string) (map[string]string, error) {
+	labels := make(map[string]string)
+
+	for _, pair := range pairs {
+		parts := strings.SplitN(pair, "=", 2)
+
+		// verify the label is a "key=value" pair
+		if len(parts) != 2 || len(strings.TrimSpace(parts[0])) == 0 {
+			return nil, fmt.Errorf("invalid label provided: %s", pair)
+		}
+
+		labels[strings.TrimSpace(parts[0])] = parts[1]
+	}
+
+	return labels, nil
+}
+
+// labelKeys is a helper function to return
+// the sorted keys for the provided labels.
+func labelKeys(labels map[string]string) []string {
+	keys := make([]string, 0, len(labels))
+
+	for key := range labels {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// labelInstruction is a helper function to format
+// a Dockerfile LABEL instruction for the labels.
+func labelInstruction(labels map[string]string) string {
+	// variable to store label pairs for the instruction
+	pairs := make([]string, 0, len(labels))
+
+	for _, key := range labelKeys(labels) {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", strconv.Quote(key), strconv.Quote(labels[key])))
+	}
+
+	return fmt.Sprintf("LABEL %s", strings.Join(pairs, " "))
+}
+
+// overlayLabels is a helper function to add a LABEL instruction
+// to the end of the target stage within the Dockerfile content.
+func overlayLabels(path, content, target string, labels map[string]string) (string, error) {
+	d, err := parseDockerfileContent(path, content)
+	if err != nil {
+		return "", err
+	}
+
+	lines := strings.Split(strings.TrimRight(content, "\n"), "\n")
+
+	// default to adding the labels to the final stage
+	insert := len(lines)
+
+	// check if a target stage is provided
+	if len(target) > 0 {
+		// variable to track if the target stage was found
+		found := false
+
+		for _, stage := range d.Stages {
+			// add the labels before the stage following the target
+			if found {
+				insert = stage.Line - 1
+
+				break
+			}
+
+			found = strings.EqualFold(stage.Name, target)
+		}
+
+		if !found {
+			return "", fmt.Errorf("target stage %s not found in Dockerfile %s", target, path)
+		}
+	}
+
+	// variable to store the lines for the overlay
+	overlay := append([]string{}, lines[:insert]...)
+	overlay = append(overlay, labelInstruction(labels))
+	overlay = append(overlay, lines[insert:]...)
+
+	return strings.Join(overlay, "\n") + "\n", nil
+}
+
+// writeLabelOverlay is a helper function to create a copy of the Dockerfile
+// with the labels added and returns the path to the copy.
+//
+// The copy is written to a temporary directory outside of the build
+// context to avoid adding it to the image or invalidating the cache.
+func writeLabelOverlay(path, target string, labels map[string]string) (string, error) {
+	logrus.Tracef("creating Dockerfile overlay with labels for %s", path)
+
+	// use custom filesystem which enables us to test
+	a := &afero.Afero{
+		Fs: appFS,
+	}
+
+	content, err := a.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	overlay, err := overlayLabels(path, string(content), target, labels)
+	if err != nil {
+		return "", err
+	}
+
+	// create the directory for the overlay outside of the build context
+	dir, err := a.TempDir("", "vela-makisu-labels-")
+	if err != nil {
+		return "", err
+	}
+
+	file := filepath.Join(dir, "Dockerfile")
+
+	err = a.WriteFile(file, []byte(overlay), 0644)
+	if err != nil {
+		_ = a.RemoveAll(dir)
+
+		return "", err
+	}
+
+	return file, nil
+}
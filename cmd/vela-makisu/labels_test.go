@@ -0,0 +1,153 @@
+// Copyright (c) 2022 Target Brands, Inc. All rights reserved.
+//
+// Use of this source code is governed by the LICENSE file in this repository.
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+func TestMakisu_autoLabels(t *testing.T) {
+	// setup types
+	t.Setenv("VELA_BUILD_CREATED", "1563474077")
+	t.Setenv("VELA_BUILD_COMMIT", "b0bb040e6a6d71ddf98684349c42d36fa6c539ad")
+	t.Setenv("VELA_BUILD_LINK", "https://vela.company.com/octocat/hello-world/1")
+	t.Setenv("VELA_BUILD_TAG", "")
+	t.Setenv("VELA_REPO_LINK", "https://github.com/octocat/hello-world")
+
+	want := map[string]string{
+		"org.opencontainers.image.created":  "2019-07-18T18:21:17Z",
+		"org.opencontainers.image.revision": "b0bb040e6a6d71ddf98684349c42d36fa6c539ad",
+		"org.opencontainers.image.source":   "https://github.com/octocat/hello-world",
+		"io.vela.build.link":                "https://vela.company.com/octocat/hello-world/1",
+	}
+
+	got := autoLabels()
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("autoLabels is %v, want %v", got, want)
+	}
+}
+
+func TestMakisu_parseLabels(t *testing.T) {
+	// setup types
+	want := map[string]string{
+		"foo":   "bar=baz",
+		"empty": "",
+	}
+
+	got, err := parseLabels([]string{"foo=bar=baz", "empty="})
+	if err != nil {
+		t.Errorf("parseLabels returned err: %v", err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseLabels is %v, want %v", got, want)
+	}
+}
+
+func TestMakisu_parseLabels_Failure(t *testing.T) {
+	// setup tests
+	tests := []string{"foo", "=bar"}
+
+	// run tests
+	for _, test := range tests {
+		_, err := parseLabels([]string{test})
+		if err == nil {
+			t.Errorf("parseLabels should have returned err for %s", test)
+		}
+	}
+}
+
+func TestMakisu_overlayLabels(t *testing.T) {
+	// setup types
+	content := `FROM --platform=linux/amd64 golang:1.17 \
+    AS builder
+RUN go build
+FROM alpine
+COPY --from=builder /app /app
+`
+
+	labels := map[string]string{
+		"org.opencontainers.image.revision": "b0bb040",
+		"foo":                               `say "hello"`,
+	}
+
+	// setup tests
+	tests := []struct {
+		target string
+		want   string
+	}{
+		{
+			target: "",
+			want: `FROM --platform=linux/amd64 golang:1.17 \
+    AS builder
+RUN go build
+FROM alpine
+COPY --from=builder /app /app
+LABEL "foo"="say \"hello\"" "org.opencontainers.image.revision"="b0bb040"
+`,
+		},
+		{
+			target: "builder",
+			want: `FROM --platform=linux/amd64 golang:1.17 \
+    AS builder
+RUN go build
+LABEL "foo"="say \"hello\"" "org.opencontainers.image.revision"="b0bb040"
+FROM alpine
+COPY --from=builder /app /app
+`,
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		got, err := overlayLabels("Dockerfile", content, test.target, labels)
+		if err != nil {
+			t.Errorf("overlayLabels returned err: %v", err)
+		}
+
+		if got != test.want {
+			t.Errorf("overlayLabels is %s, want %s", got, test.want)
+		}
+	}
+
+	_, err := overlayLabels("Dockerfile", content, "foo", labels)
+	if err == nil {
+		t.Errorf("overlayLabels should have returned err")
+	}
+}
+
+func TestMakisu_writeLabelOverlay(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	_ = afero.WriteFile(appFS, "/workspace/Dockerfile", []byte("FROM alpine\n"), 0644)
+
+	// setup types
+	want := "FROM alpine\nLABEL \"foo\"=\"bar\"\n"
+
+	path, err := writeLabelOverlay("/workspace/Dockerfile", "", map[string]string{"foo": "bar"})
+	if err != nil {
+		t.Errorf("writeLabelOverlay returned err: %v", err)
+	}
+
+	got, err := afero.ReadFile(appFS, path)
+	if err != nil {
+		t.Errorf("ReadFile returned err: %v", err)
+	}
+
+	if string(got) != want {
+		t.Errorf("writeLabelOverlay is %s, want %s", got, want)
+	}
+
+	// verify the copy is not written to the build context
+	if strings.HasPrefix(path, "/workspace/") {
+		t.Errorf("writeLabelOverlay is %s, should be outside of the build context", path)
+	}
+}
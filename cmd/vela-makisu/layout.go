@@ -0,0 +1,328 @@
+// Copyright (c) 2022 Target Brands, Inc. All rights reserved.
+//
+// Use of this source code is governed by the LICENSE file in this repository.
+
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+	"github.com/uber/makisu/lib/docker/image"
+)
+
+const (
+	// dockerTarFormat represents the output format for
+	// an image tarball in the format of "docker save".
+	dockerTarFormat = "docker-tar"
+
+	// ociLayoutFormat represents the output format
+	// for an image in an OCI image layout directory.
+	ociLayoutFormat = "oci-layout"
+
+	// ociTarFormat represents the output format for
+	// an image in a tarball of an OCI image layout.
+	ociTarFormat = "oci-tar"
+
+	// ociLayerMediaType represents the media type for an uncompressed OCI image layer.
+	ociLayerMediaType = "application/vnd.oci.image.layer.v1.tar"
+
+	// ociGzipLayerMediaType represents the media type for a gzip compressed OCI image layer.
+	ociGzipLayerMediaType = "application/vnd.oci.image.layer.v1.tar+gzip"
+
+	// ociRefNameAnnotation represents the annotation for the tag of a manifest within an OCI image layout.
+	ociRefNameAnnotation = "org.opencontainers.image.ref.name"
+
+	// ociLayoutVersion represents the version of the OCI image layout written for the image.
+	ociLayoutVersion = "1.0.0"
+)
+
+type (
+	// ociIndex represents an OCI image index.
+	//
+	// https://github.com/opencontainers/image-spec/blob/main/image-index.md
+	ociIndex struct {
+		SchemaVersion int             `json:"schemaVersion"`
+		MediaType     string          `json:"mediaType"`
+		Manifests     []ociDescriptor `json:"manifests"`
+	}
+
+	// ociLayout represents the marker file for an OCI image layout.
+	//
+	// https://github.com/opencontainers/image-spec/blob/main/image-layout.md
+	ociLayout struct {
+		ImageLayoutVersion string `json:"imageLayoutVersion"`
+	}
+)
+
+// OutputPath outputs the path the image is
+// written to in the format for the Build.
+//
+// i.e. "image.tar" with "oci-layout" becomes "image".
+func (b *Build) OutputPath() string {
+	// check if the image is written to a directory
+	if b.OutputFormat == ociLayoutFormat {
+		return strings.TrimSuffix(b.Destination, filepath.Ext(b.Destination))
+	}
+
+	return b.Destination
+}
+
+// WriteOutput converts the image written to the Destination by
+// the builder into the format for the Build with the digests of
+// the configuration, layers and manifest for the image recomputed.
+func (b *Build) WriteOutput() error {
+	logrus.Trace("converting image tarball to the output format")
+
+	t, err := openTarball(b.Destination)
+	if err != nil {
+		return err
+	}
+
+	// capture the tag for the manifest within the layout
+	_, tag := splitTag(b.Tag)
+	if len(tag) == 0 && len(b.Tag) > 0 {
+		tag = "latest"
+	}
+
+	// check if the layout is written to a tarball
+	if b.OutputFormat == ociTarFormat {
+		dir, err := afero.TempDir(appFS, "", "oci-layout")
+		if err != nil {
+			return err
+		}
+
+		// remove the layout once it is written to the tarball
+		defer func() {
+			_ = appFS.RemoveAll(dir)
+		}()
+
+		err = t.WriteLayout(dir, tag)
+		if err != nil {
+			return err
+		}
+
+		logrus.Infof("writing OCI image layout tarball to %s", b.Destination)
+
+		// replace the image tarball with the layout
+		return tarDirectory(dir, b.Destination)
+	}
+
+	path := b.OutputPath()
+
+	err = t.WriteLayout(path, tag)
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("wrote OCI image layout to %s", path)
+
+	// remove the image tarball replaced by the layout
+	return appFS.Remove(b.Destination)
+}
+
+// WriteLayout writes the image in the tarball to the provided
+// directory in the format of an OCI image layout with the
+// manifest for the image annotated with the provided tag.
+func (t *Tarball) WriteLayout(dir, tag string) error {
+	logrus.Tracef("writing image tarball %s to OCI image layout %s", t.Path, dir)
+
+	// use custom filesystem which enables us to test
+	a := &afero.Afero{
+		Fs: appFS,
+	}
+
+	// create the directory for the blobs
+	err := a.MkdirAll(filepath.Join(dir, "blobs", "sha256"), 0755)
+	if err != nil {
+		return err
+	}
+
+	manifest := &ociManifest{
+		SchemaVersion: 2,
+		MediaType:     ociManifestMediaType,
+		Layers:        []ociDescriptor{},
+	}
+
+	manifest.Config, err = writeBlob(dir, ociConfigMediaType, bytes.NewReader(t.Config))
+	if err != nil {
+		return err
+	}
+
+	for _, layer := range t.Manifest.Layers {
+		err = t.Entry(layer.String(), func(r io.Reader) error {
+			br := bufio.NewReader(r)
+
+			// variable to store the media type for the layer
+			mediaType := ociLayerMediaType
+
+			// check if the layer is compressed
+			magic, err := br.Peek(2)
+			if err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+				mediaType = ociGzipLayerMediaType
+			}
+
+			d, err := writeBlob(dir, mediaType, br)
+			if err != nil {
+				return err
+			}
+
+			manifest.Layers = append(manifest.Layers, d)
+
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("unable to write layer %s: %w", layer, err)
+		}
+	}
+
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+
+	d, err := writeBlob(dir, ociManifestMediaType, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	// check if the manifest should be tagged
+	if len(tag) > 0 {
+		d.Annotations = map[string]string{ociRefNameAnnotation: tag}
+	}
+
+	index, err := json.Marshal(&ociIndex{
+		SchemaVersion: 2,
+		MediaType:     ociIndexMediaType,
+		Manifests:     []ociDescriptor{d},
+	})
+	if err != nil {
+		return err
+	}
+
+	err = a.WriteFile(filepath.Join(dir, "index.json"), index, 0644)
+	if err != nil {
+		return err
+	}
+
+	layout, err := json.Marshal(&ociLayout{ImageLayoutVersion: ociLayoutVersion})
+	if err != nil {
+		return err
+	}
+
+	return a.WriteFile(filepath.Join(dir, "oci-layout"), layout, 0644)
+}
+
+// writeBlob is a helper function to write the provided content to the
+// blobs of the OCI image layout and output the descriptor for the blob.
+func writeBlob(dir, mediaType string, r io.Reader) (ociDescriptor, error) {
+	blobs := filepath.Join(dir, "blobs", "sha256")
+
+	f, err := afero.TempFile(appFS, blobs, "blob")
+	if err != nil {
+		return ociDescriptor{}, err
+	}
+
+	h := sha256.New()
+
+	// compute the digest while the blob is written
+	size, err := io.Copy(io.MultiWriter(f, h), r)
+
+	// close the blob before it is renamed
+	cerr := f.Close()
+	if err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		_ = appFS.Remove(f.Name())
+
+		return ociDescriptor{}, err
+	}
+
+	digest := image.Digest(fmt.Sprintf("sha256:%x", h.Sum(nil)))
+
+	// name the blob with the digest of the content
+	err = appFS.Rename(f.Name(), filepath.Join(blobs, digest.Hex()))
+	if err != nil {
+		return ociDescriptor{}, err
+	}
+
+	return ociDescriptor{
+		MediaType: mediaType,
+		Digest:    string(digest),
+		Size:      size,
+	}, nil
+}
+
+// tarDirectory is a helper function to write the
+// contents of the directory to the provided tarball.
+func tarDirectory(dir, path string) error {
+	f, err := appFS.Create(path)
+	if err != nil {
+		return err
+	}
+
+	tw := tar.NewWriter(f)
+
+	err = afero.Walk(appFS, dir, func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, name)
+		if err != nil || rel == "." {
+			return err
+		}
+
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+
+		hdr.Name = filepath.ToSlash(rel)
+
+		// check if the entry is a directory
+		if info.IsDir() {
+			hdr.Name += "/"
+
+			return tw.WriteHeader(hdr)
+		}
+
+		err = tw.WriteHeader(hdr)
+		if err != nil {
+			return err
+		}
+
+		src, err := appFS.Open(name)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+
+		_, err = io.Copy(tw, src)
+
+		return err
+	})
+	if err == nil {
+		err = tw.Close()
+	}
+
+	// close the tarball once after it is written
+	cerr := f.Close()
+	if err == nil {
+		err = cerr
+	}
+
+	return err
+}
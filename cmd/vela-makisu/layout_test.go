@@ -0,0 +1,239 @@
+// Copyright (c) 2022 Target Brands, Inc. All rights reserved.
+//
+// Use of this source code is governed by the LICENSE file in this repository.
+
+package main
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+// testLayout is a helper function to verify the OCI image
+// layout in the provided directory references valid blobs.
+func testLayout(t *testing.T, dir, tag string) *ociManifest {
+	t.Helper()
+
+	data, err := afero.ReadFile(appFS, filepath.Join(dir, "oci-layout"))
+	if err != nil || !strings.Contains(string(data), ociLayoutVersion) {
+		t.Errorf("layout marker is %s: %v", data, err)
+	}
+
+	data, err = afero.ReadFile(appFS, filepath.Join(dir, "index.json"))
+	if err != nil {
+		t.Fatalf("ReadFile returned err: %v", err)
+	}
+
+	index := new(ociIndex)
+
+	_ = json.Unmarshal(data, index)
+
+	if len(index.Manifests) != 1 || index.Manifests[0].Annotations[ociRefNameAnnotation] != tag {
+		t.Fatalf("index is %s", data)
+	}
+
+	// readBlob verifies the blob for the descriptor matches the digest and size
+	readBlob := func(d ociDescriptor) []byte {
+		blob, err := afero.ReadFile(appFS, filepath.Join(dir, "blobs", "sha256", strings.TrimPrefix(d.Digest, "sha256:")))
+		if err != nil {
+			t.Fatalf("ReadFile returned err: %v", err)
+		}
+
+		if string(digestOf(blob)) != d.Digest || int64(len(blob)) != d.Size {
+			t.Errorf("blob is %s (%d bytes), want %s (%d bytes)", digestOf(blob), len(blob), d.Digest, d.Size)
+		}
+
+		return blob
+	}
+
+	manifest := new(ociManifest)
+
+	_ = json.Unmarshal(readBlob(index.Manifests[0]), manifest)
+
+	for _, d := range append(manifest.Layers, manifest.Config) {
+		readBlob(d)
+	}
+
+	return manifest
+}
+
+func TestMakisu_Tarball_WriteLayout(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	testTarball(t, "image.tar",
+		[]testFile{{Name: "etc/hostname", Content: "octocat"}},
+		[]testFile{{Name: "etc/hosts", Content: "127.0.0.1 localhost"}},
+	)
+
+	// setup types
+	tarball, err := openTarball("image.tar")
+	if err != nil {
+		t.Errorf("openTarball returned err: %v", err)
+	}
+
+	err = tarball.WriteLayout("layout", "latest")
+	if err != nil {
+		t.Errorf("WriteLayout returned err: %v", err)
+	}
+
+	manifest := testLayout(t, "layout", "latest")
+
+	if manifest.Config.MediaType != ociConfigMediaType {
+		t.Errorf("WriteLayout config media type is %s", manifest.Config.MediaType)
+	}
+
+	want := []string{ociGzipLayerMediaType, ociLayerMediaType}
+
+	for i, layer := range manifest.Layers {
+		if i >= len(want) || layer.MediaType != want[i] {
+			t.Errorf("WriteLayout layer %d media type is %s", i, layer.MediaType)
+		}
+	}
+
+	// verify the temporary blobs were renamed
+	blobs, _ := afero.ReadDir(appFS, "layout/blobs/sha256")
+	if len(blobs) != 4 {
+		t.Errorf("WriteLayout wrote %d blobs, want %d", len(blobs), 4)
+	}
+}
+
+func TestMakisu_Build_WriteOutput_Layout(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	testTarball(t, "image.tar", []testFile{{Name: "etc/hostname", Content: "octocat"}})
+
+	// setup types
+	b := &Build{
+		Destination:  "image.tar",
+		OutputFormat: ociLayoutFormat,
+		Tag:          "index.docker.io/octocat/hello-world:1.0.0",
+	}
+
+	err := b.WriteOutput()
+	if err != nil {
+		t.Errorf("WriteOutput returned err: %v", err)
+	}
+
+	testLayout(t, "image", "1.0.0")
+
+	if ok, _ := afero.Exists(appFS, "image.tar"); ok {
+		t.Errorf("WriteOutput should have removed the image tarball")
+	}
+}
+
+func TestMakisu_Build_WriteOutput_Tarball(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	testTarball(t, "image.tar", []testFile{{Name: "etc/hostname", Content: "octocat"}})
+
+	// setup types
+	b := &Build{
+		Destination:  "image.tar",
+		OutputFormat: ociTarFormat,
+		Tag:          "octocat/hello-world",
+	}
+
+	err := b.WriteOutput()
+	if err != nil {
+		t.Errorf("WriteOutput returned err: %v", err)
+	}
+
+	f, err := appFS.Open("image.tar")
+	if err != nil {
+		t.Fatalf("Open returned err: %v", err)
+	}
+	defer f.Close()
+
+	// extract the layout from the tarball
+	tr := tar.NewReader(f)
+
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			t.Fatalf("Next returned err: %v", err)
+		}
+
+		if hdr.Typeflag == tar.TypeDir {
+			continue
+		}
+
+		data, _ := io.ReadAll(tr)
+
+		_ = afero.WriteFile(appFS, filepath.Join("extracted", hdr.Name), data, 0644)
+	}
+
+	testLayout(t, "extracted", "latest")
+
+	// verify the temporary layout was removed
+	dirs, _ := afero.Glob(appFS, filepath.Join(os.TempDir(), "oci-layout*"))
+	if len(dirs) > 0 {
+		t.Errorf("WriteOutput should have removed %s", dirs)
+	}
+}
+
+func TestMakisu_Build_OutputPath(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		build *Build
+		want  string
+	}{
+		{
+			build: &Build{Destination: "image.tar", OutputFormat: ociLayoutFormat},
+			want:  "image",
+		},
+		{
+			build: &Build{Destination: "image.tar", OutputFormat: ociTarFormat},
+			want:  "image.tar",
+		},
+		{
+			build: &Build{Destination: "image.tar"},
+			want:  "image.tar",
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		got := test.build.OutputPath()
+
+		if got != test.want {
+			t.Errorf("OutputPath is %s, want %s", got, test.want)
+		}
+	}
+}
+
+func TestMakisu_Build_WriteOutput_BadTarball(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	_ = afero.WriteFile(appFS, "image.tar", []byte("foo"), 0644)
+
+	// setup types
+	b := &Build{
+		Destination:  "image.tar",
+		OutputFormat: ociLayoutFormat,
+	}
+
+	err := b.WriteOutput()
+	if err == nil {
+		t.Errorf("WriteOutput should have returned err")
+	}
+
+	if ok, _ := afero.Exists(appFS, "image.tar"); !ok {
+		t.Errorf("WriteOutput should not have removed the image tarball")
+	}
+}
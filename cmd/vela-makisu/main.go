@@ -56,6 +56,19 @@ func main() {
 	// Plugin Flags
 
 	app.Flags = []cli.Flag{
+		&cli.StringFlag{
+			EnvVars:  []string{"PARAMETER_ACTION"},
+			FilePath: string("/vela/parameters/makisu/action,/vela/secrets/makisu/action"),
+			Name:     "action",
+			Usage:    "set the action for the plugin - options: (build|promote|push_tarball)",
+			Value:    buildAction,
+		},
+		&cli.BoolFlag{
+			EnvVars:  []string{"PARAMETER_DRY_RUN", "PARAMETER_EXPLAIN"},
+			FilePath: string("/vela/parameters/makisu/dry_run,/vela/secrets/makisu/dry_run"),
+			Name:     "dry-run",
+			Usage:    "enables printing the resolved command and configuration without building the image",
+		},
 		&cli.StringFlag{
 			EnvVars:  []string{"PARAMETER_LOG_LEVEL", "VELA_LOG_LEVEL", "MAKISU_LOG_LEVEL"},
 			FilePath: string("/vela/parameters/makisu/log_level,/vela/secrets/makisu/log_level"),
@@ -71,6 +84,9 @@ func main() {
 	// add config flags
 	app.Flags = append(app.Flags, configFlags...)
 
+	// add promote flags
+	app.Flags = append(app.Flags, promoteFlags...)
+
 	// add global flags
 	app.Flags = append(app.Flags, globalFlags...)
 
@@ -102,6 +118,12 @@ func run(c *cli.Context) error {
 		logrus.SetLevel(logrus.InfoLevel)
 	}
 
+	// redact any secrets from the plugin logs
+	logrus.AddHook(new(redactHook))
+
+	// redact any values loaded from Vela secret files
+	addSecrets(secretFiles(c.App.Flags)...)
+
 	logrus.WithFields(logrus.Fields{
 		"code":     "https://github.com/go-vela/vela-makisu",
 		"docs":     "https://go-vela.github.io/docs/plugins/registry/makisu",
@@ -110,34 +132,64 @@ func run(c *cli.Context) error {
 
 	// create the plugin
 	p := Plugin{
+		Action: c.String("action"),
 		Build: &Build{
-			BuildArgs:      c.StringSlice("build.build-args"),
-			Commit:         c.String("build.commit"),
-			Compression:    c.String("build.compression"),
-			Context:        c.String("build.context"),
-			DenyList:       c.StringSlice("build.deny-list"),
-			DockerRaw:      c.String("build.docker-options"),
-			Destination:    c.String("build.destination"),
-			File:           c.String("build.file"),
-			HTTPCacheRaw:   c.String("build.http-cache-options"),
-			Load:           c.Bool("build.load"),
-			LocalCacheTTL:  c.Duration("build.local-cache-ttl"),
-			ModifyFS:       c.Bool("build.modify-fs"),
-			PreserveRoot:   c.Bool("build.preserve-root"),
-			Pushes:         c.StringSlice("build.pushes"),
-			RedisCacheRaw:  c.String("build.redis-cache-options"),
-			RegistryConfig: c.String("build.registry-config"),
-			Replicas:       c.StringSlice("build.replicas"),
-			Storage:        c.String("build.storage"),
-			Tag:            c.String("build.tag"),
-			Target:         c.String("build.target"),
+			AllowedBaseImages: c.StringSlice("build.allowed-base-images"),
+			AutoLabels:        c.Bool("build.auto-labels"),
+			AutoTag:           c.Bool("build.auto-tag"),
+			BuildArgs:         c.StringSlice("build.build-args"),
+			BuildSecretsRaw:   c.String("build.secrets"),
+			Builder:           c.String("build.builder"),
+			Commit:            c.String("build.commit"),
+			Compression:       c.String("build.compression"),
+			Context:           c.String("build.context"),
+			DenyList:          c.StringSlice("build.deny-list"),
+			DockerRaw:         c.String("build.docker-options"),
+			Destination:       c.String("build.destination"),
+			File:              c.String("build.file"),
+			GracePeriod:       c.Duration("build.grace-period"),
+			HTTPCacheRaw:      c.String("build.http-cache-options"),
+			ImagesRaw:         c.String("build.images"),
+			Labels:            c.StringSlice("build.labels"),
+			Load:              c.Bool("build.load"),
+			LocalCacheTTL:     c.Duration("build.local-cache-ttl"),
+			ModifyFS:          c.Bool("build.modify-fs"),
+			OutputFormat:      c.String("build.output-format"),
+			ParseLogs:         c.Bool("build.parse-logs"),
+			PolicyFile:        c.String("build.policy-file"),
+			PolicyRaw:         c.String("build.policy"),
+			PreserveRoot:      c.Bool("build.preserve-root"),
+			ProvenancePath:    c.String("build.provenance-path"),
+			PushProvenance:    c.Bool("build.push-provenance"),
+			Pushes:            c.StringSlice("build.pushes"),
+			RedisCacheRaw:     c.String("build.redis-cache-options"),
+			Replicas:          c.StringSlice("build.replicas"),
+			ReportPath:        c.String("build.report-path"),
+			ResultsPath:       c.String("build.results-path"),
+			RetryRaw:          c.String("build.retry-options"),
+			SBOM:              c.String("build.sbom"),
+			ScanRaw:           c.String("build.scan"),
+			Sign:              c.Bool("build.sign"),
+			SignKey:           c.String("build.sign-key"),
+			Storage:           c.String("build.storage"),
+			Tag:               c.String("build.tag"),
+			Target:            c.String("build.target"),
+			Timeout:           c.Duration("build.timeout"),
 		},
+		DryRun:    c.Bool("dry-run"),
 		GlobalRaw: c.String("global.flags"),
+		Promote: &Promote{
+			Source:  c.String("promote.source"),
+			Targets: c.StringSlice("promote.targets"),
+		},
 		Registry: &Registry{
-			Mirror:   c.String("registry.mirror"),
-			Name:     c.String("registry.name"),
-			Password: c.String("registry.password"),
-			Username: c.String("registry.username"),
+			Config:          c.String("build.registry-config"),
+			DockerConfigRaw: c.String("registry.docker-config"),
+			Mirror:          c.String("registry.mirror"),
+			Name:            c.String("registry.name"),
+			Password:        c.String("registry.password"),
+			RegistriesRaw:   c.String("registry.registries"),
+			Username:        c.String("registry.username"),
 		},
 	}
 
@@ -153,6 +205,14 @@ func run(c *cli.Context) error {
 		return err
 	}
 
+	// redact any sensitive values from the plugin configuration
+	addSecrets(p.Secrets()...)
+
+	// check if the plugin should only explain the configuration
+	if p.DryRun {
+		return p.Explain(os.Stdout, flagSources(c))
+	}
+
 	// execute the plugin
 	return p.Exec()
 }
@@ -0,0 +1,301 @@
+// Copyright (c) 2022 Target Brands, Inc. All rights reserved.
+//
+// Use of this source code is governed by the LICENSE file in this repository.
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+)
+
+// pushAction represents the makisu command
+// for pushing an image from a tarball.
+const pushAction = "push"
+
+// Makisu represents the builder backend for building
+// and publishing images with the makisu binary.
+//
+// Makisu documents their command usage:
+// https://github.com/uber/makisu/blob/master/docs/COMMAND.md
+type Makisu struct{}
+
+// Command formats and outputs the makisu build command from
+// the provided configuration to build a Docker image.
+//
+// The labels for the image are added to a copy of the Dockerfile
+// which is removed by the returned function after the build.
+func (m *Makisu) Command(b *Build) (*exec.Cmd, func(), error) {
+	logrus.Trace("creating makisu build command from plugin configuration")
+
+	// variable to store the function removing the copy of the Dockerfile
+	cleanup := func() {}
+
+	// capture the labels for the image
+	labels, err := b.ImageLabels()
+	if err != nil {
+		return nil, cleanup, err
+	}
+
+	// check if labels should be added to the image
+	if len(labels) > 0 {
+		// create a copy of the Dockerfile with the labels
+		file, err := writeLabelOverlay(b.Dockerfile(), b.Target, labels)
+		if err != nil {
+			return nil, cleanup, err
+		}
+
+		// remove the copy of the Dockerfile after the build
+		cleanup = func() {
+			_ = appFS.RemoveAll(filepath.Dir(file))
+		}
+
+		// build the image from the copy of the Dockerfile
+		overlay := *b
+		overlay.File = file
+
+		b = &overlay
+	}
+
+	cmd, err := m.command(b)
+	if err != nil {
+		cleanup()
+
+		return nil, func() {}, err
+	}
+
+	return cmd, cleanup, nil
+}
+
+// command is a helper function to format the makisu
+// build command with the flags for the configuration.
+func (m *Makisu) command(b *Build) (*exec.Cmd, error) {
+
+	// variable to store flags for command
+	var flags []string
+
+	// add any global flags that may have been set
+	flags = append(flags, b.GlobalFlags...)
+
+	// check if BuildArgs is provided
+	if len(b.BuildArgs) > 0 {
+		for _, arg := range b.BuildArgs {
+			// add flag for BuildArgs from provided build command
+			flags = append(flags, "--build-arg", arg)
+		}
+	}
+
+	// check if Commit is provided
+	if len(b.Commit) > 0 {
+		// add flag for Commit from provided build command
+		flags = append(flags, "--commit", b.Commit)
+	}
+
+	// check if Compression is provided
+	if len(b.Compression) > 0 {
+		// add flag for Compression from provided build command
+		flags = append(flags, "--compression", b.Compression)
+	}
+
+	// check if DenyList is provided
+	if len(b.DenyList) > 0 {
+		for _, d := range b.DenyList {
+			// add flag for DenyList from provided build command
+			flags = append(flags, "--blacklist", d)
+		}
+	}
+
+	// add flags for Docker configuration
+	flags = append(flags, b.Docker.Flags()...)
+
+	// check if Destination is provided
+	if len(b.Destination) > 0 {
+		// add flag for Destination from provided build command
+		flags = append(flags, "--dest", b.Destination)
+	}
+
+	// check if File is provided
+	if len(b.File) > 0 {
+		// add flag for File from provided build command
+		flags = append(flags, "--file", b.File)
+	}
+
+	// add flags for HTTPCache configuration
+	flags = append(flags, b.HTTPCache.Flags()...)
+
+	// check if Load is provided
+	if b.Load {
+		// add flag for Load from provided build command
+		flags = append(flags, "--load")
+	}
+
+	// check if LocalCacheTTL is provided
+	if b.LocalCacheTTL > 0 {
+		// add flag for LocalCacheTTL from provided build command
+		flags = append(flags, "--local-cache-ttl", b.LocalCacheTTL.String())
+	}
+
+	// check if ModifyFS is provided
+	if b.ModifyFS {
+		// add flag for ModifyFS from provided build command
+		flags = append(flags, "--modifyfs")
+	}
+
+	// check if PreserveRoot is provided
+	if b.PreserveRoot {
+		// add flag for PreserveRoot from provided build command
+		flags = append(flags, "--preserve-root")
+	}
+
+	// check if Pushes is provided
+	if len(b.Pushes) > 0 {
+		for _, p := range b.Pushes {
+			// add flag for Pushes from provided build command
+			flags = append(flags, "--push", p)
+		}
+	}
+
+	redisFlags, err := b.RedisCache.Flags()
+	if err != nil {
+		return nil, err
+	}
+
+	// add flags for RedisCache configuration
+	flags = append(flags, redisFlags...)
+
+	// check if RegistryConfig is provided
+	if len(b.RegistryConfig) > 0 {
+		// add flag for RegistryConfig from provided build command
+		flags = append(flags, "--registry-config", b.RegistryConfig)
+	}
+
+	// check if Replicas is provided
+	if len(b.Replicas) > 0 {
+		for _, r := range b.Replicas {
+			// add flag for Replicas from provided build command
+			flags = append(flags, "--replica", r)
+		}
+	}
+
+	// check if Tag is provided
+	if len(b.Storage) > 0 {
+		// add flag for Tag from provided build command
+		flags = append(flags, "--storage", b.Storage)
+	}
+
+	// check if Tag is provided
+	if len(b.Tag) > 0 {
+		// add flag for Tag from provided build command
+		flags = append(flags, "--tag", b.Tag)
+	}
+
+	// check if Target is provided
+	if len(b.Target) > 0 {
+		// add flag for Target from provided build command
+		flags = append(flags, "--target", b.Target)
+	}
+
+	// add the required directory param
+	flags = append(flags, b.Context)
+
+	// nolint: gosec // this functionality is not exploitable the way
+	// the plugin accepts configuration
+	return exec.Command(_makisu, append([]string{buildAction}, flags...)...), nil
+}
+
+// Exec formats and runs the makisu commands for building a Docker image.
+func (m *Makisu) Exec(b *Build) error {
+	logrus.Trace("running makisu build with provided configuration")
+
+	// create the build command for the file
+	cmd, cleanup, err := b.command(m)
+	defer cleanup()
+
+	if err != nil {
+		return err
+	}
+
+	// parse the makisu logs to track the build
+	processor := newLogProcessor(os.Stdout)
+	processor.Progress = b.progress
+	processor.Report = b.report
+
+	// output the logs without rendering them unless the makisu logs should be parsed
+	processor.Raw = !b.ParseLogs
+
+	// output any remaining logs and the final error message after the build
+	defer func() {
+		_ = processor.Close()
+	}()
+
+	cmd.Stdout = processor
+	cmd.Stderr = processor
+
+	// capture the output for classifying a failed build
+	b.capture(cmd)
+
+	// run the build command for the file
+	return execCmdContext(b.context(), cmd, b.GracePeriod)
+}
+
+// Push formats and outputs the makisu push command from the
+// provided configuration to push the image in the tarball.
+//
+// The tag is pushed as a replica when no registries are
+// provided since it already contains the registry.
+func (m *Makisu) Push(b *Build, path string) (*exec.Cmd, error) {
+	logrus.Trace("creating makisu push command from plugin configuration")
+
+	// variable to store flags for command
+	var flags []string
+
+	// add any global flags that may have been set
+	flags = append(flags, b.GlobalFlags...)
+
+	// check if Pushes is provided
+	if len(b.Pushes) > 0 {
+		for _, p := range b.Pushes {
+			// add flag for Pushes from provided build command
+			flags = append(flags, "--push", p)
+		}
+	}
+
+	// check if RegistryConfig is provided
+	if len(b.RegistryConfig) > 0 {
+		// add flag for RegistryConfig from provided build command
+		flags = append(flags, "--registry-config", b.RegistryConfig)
+	}
+
+	// check if Pushes is not provided
+	if len(b.Pushes) == 0 {
+		// add flag for Tag as a replica from provided build command
+		flags = append(flags, "--replica", b.Tag)
+	}
+
+	// check if Replicas is provided
+	if len(b.Replicas) > 0 {
+		for _, r := range b.Replicas {
+			// add flag for Replicas from provided build command
+			flags = append(flags, "--replica", r)
+		}
+	}
+
+	// add flag for Tag from provided build command
+	flags = append(flags, "--tag", b.Tag)
+
+	// add the required tarball param
+	flags = append(flags, path)
+
+	// nolint: gosec // this functionality is not exploitable the way
+	// the plugin accepts configuration
+	return exec.Command(_makisu, append([]string{pushAction}, flags...)...), nil
+}
+
+// Version outputs the makisu command for
+// displaying the version information.
+func (m *Makisu) Version() *exec.Cmd {
+	return versionCmd()
+}
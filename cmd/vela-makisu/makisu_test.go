@@ -0,0 +1,107 @@
+// Copyright (c) 2022 Target Brands, Inc. All rights reserved.
+//
+// Use of this source code is governed by the LICENSE file in this repository.
+
+package main
+
+import (
+	"os/exec"
+	"reflect"
+	"testing"
+)
+
+func TestMakisu_Makisu_Command(t *testing.T) {
+	// setup types
+	m := &Makisu{}
+
+	b := &Build{
+		Context:    ".",
+		Docker:     &Docker{},
+		HTTPCache:  &HTTPCache{},
+		Pushes:     []string{"index.docker.io"},
+		RedisCache: &RedisCache{},
+		Tag:        "octocat/hello-world:latest",
+	}
+
+	// nolint: gosec // this functionality is not exploitable the way
+	// the plugin accepts configuration
+	want := exec.Command(
+		_makisu,
+		buildAction,
+		"--push", b.Pushes[0],
+		"--tag", b.Tag,
+		".",
+	)
+
+	got, _, err := m.Command(b)
+	if err != nil {
+		t.Errorf("Command returned err: %v", err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Command is %v, want %v", got, want)
+	}
+}
+
+func TestMakisu_Makisu_Push(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		build *Build
+		want  []string
+	}{
+		{ // pushes with replicas
+			build: &Build{
+				GlobalFlags:    []string{"--log-fmt=console"},
+				Pushes:         []string{"index.docker.io"},
+				RegistryConfig: configPath,
+				Replicas:       []string{"index.docker.io/octocat/hello-world:1"},
+				Tag:            "octocat/hello-world:latest",
+			},
+			want: []string{
+				_makisu, pushAction,
+				"--log-fmt=console",
+				"--push", "index.docker.io",
+				"--registry-config", configPath,
+				"--replica", "index.docker.io/octocat/hello-world:1",
+				"--tag", "octocat/hello-world:latest",
+				"image.tar",
+			},
+		},
+		{ // tag with the registry
+			build: &Build{
+				Tag: "index.docker.io/octocat/hello-world:latest",
+			},
+			want: []string{
+				_makisu, pushAction,
+				"--replica", "index.docker.io/octocat/hello-world:latest",
+				"--tag", "index.docker.io/octocat/hello-world:latest",
+				"image.tar",
+			},
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		got, err := new(Makisu).Push(test.build, "image.tar")
+		if err != nil {
+			t.Errorf("Push returned err: %v", err)
+		}
+
+		if !reflect.DeepEqual(got.Args, test.want) {
+			t.Errorf("Push is %v, want %v", got.Args, test.want)
+		}
+	}
+}
+
+func TestMakisu_Makisu_Version(t *testing.T) {
+	// setup types
+	m := &Makisu{}
+
+	want := versionCmd()
+
+	got := m.Version()
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Version is %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,260 @@
+// Copyright (c) 2022 Target Brands, Inc. All rights reserved.
+//
+// Use of this source code is governed by the LICENSE file in this repository.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	// stageMessage represents the makisu log message when a build stage starts.
+	stageMessage = regexp.MustCompile(`^\* Stage (\d+/\d+) : (.*)$`)
+
+	// stepMessage represents the makisu log message when an instruction starts.
+	stepMessage = regexp.MustCompile(`^\* Step (\d+/\d+) \(.*\) : (.*)$`)
+
+	// cacheMessage represents the makisu log messages when a cached layer is used.
+	cacheMessage = regexp.MustCompile(`^\* (Skipping execution; cache was applied|Applying cache layer)`)
+
+	// skipMessage represents the makisu log message when a later step was cached.
+	skipMessage = regexp.MustCompile(`^\* Skipping execution; a later step was cached`)
+
+	// executedMessage represents the makisu log message when an instruction completes.
+	executedMessage = regexp.MustCompile(`^\* Executed `)
+
+	// committedMessage represents the makisu log message when a layer is created.
+	committedMessage = regexp.MustCompile(`^\* Committed gzipped layer (\S+) \((\d+) bytes\)`)
+
+	// layerMessage represents the makisu log message when a layer is pushed.
+	layerMessage = regexp.MustCompile(`^\* Finished pushing layer (\S+)`)
+
+	// existingLayerMessage represents the makisu log message when a layer already exists.
+	existingLayerMessage = regexp.MustCompile(`^\* Skipped pushing existing layer (\S+)`)
+
+	// pushedMessage represents the makisu log message when an image is pushed.
+	pushedMessage = regexp.MustCompile(`^\* Pushed image (\S+)`)
+
+	// colorCodes represents the terminal color codes for the levels of the makisu console logs.
+	colorCodes = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+)
+
+// consoleTimeFormat represents the format of the timestamp for the makisu console logs.
+const consoleTimeFormat = "2006-01-02T15:04:05.000Z0700"
+
+// logEntry represents a single line of the makisu JSON logs.
+type logEntry struct {
+	// duration of the operation in seconds
+	Duration float64 `json:"duration"`
+	// severity of the log line i.e. "info" or "error"
+	Level string `json:"level"`
+	// message for the log line
+	Msg string `json:"msg"`
+	// timestamp of the log line in seconds since the epoch
+	TS float64 `json:"ts"`
+}
+
+// failed is a helper function to check if
+// the log entry is logged for an error.
+func (e *logEntry) failed() bool {
+	switch e.Level {
+	case "error", "dpanic", "panic", "fatal":
+		return true
+	default:
+		return false
+	}
+}
+
+// LogProcessor represents a writer which renders the
+// makisu JSON logs into a readable progress output.
+type LogProcessor struct {
+	// last error message logged by makisu
+	LastError string
+	// stage and step currently running from the logs
+	Progress *progress
+	// indicates if the logs are output without rendering them
+	Raw bool
+	// report capturing the performance data from the logs
+	Report *Report
+
+	// buffer for partially written log lines
+	buf []byte
+	// output for the rendered progress
+	out io.Writer
+}
+
+// newLogProcessor creates a LogProcessor rendering to the provided output.
+func newLogProcessor(out io.Writer) *LogProcessor {
+	return &LogProcessor{
+		out: out,
+	}
+}
+
+// Write captures the provided makisu log output and
+// renders each complete line to the output.
+func (l *LogProcessor) Write(p []byte) (int, error) {
+	l.buf = append(l.buf, p...)
+
+	for {
+		i := bytes.IndexByte(l.buf, '\n')
+		if i < 0 {
+			break
+		}
+
+		l.process(string(l.buf[:i]))
+
+		l.buf = l.buf[i+1:]
+	}
+
+	return len(p), nil
+}
+
+// Close renders any remaining log output and the
+// final error message logged by makisu.
+func (l *LogProcessor) Close() error {
+	// check if a partial log line remains
+	if len(l.buf) > 0 {
+		l.process(string(l.buf))
+
+		l.buf = nil
+	}
+
+	// check if an error was logged for the rendered logs
+	if len(l.LastError) > 0 && !l.Raw {
+		fmt.Fprintf(l.out, "\nmakisu failed: %s\n", l.LastError)
+	}
+
+	return nil
+}
+
+// process renders a single makisu log line to the output.
+func (l *LogProcessor) process(line string) {
+	line = strings.TrimRight(line, "\r")
+
+	// skip empty lines
+	if len(strings.TrimSpace(line)) == 0 {
+		return
+	}
+
+	entry, ok := parseLogLine(line)
+	if ok {
+		// check if the stage and step should be tracked
+		if l.Progress != nil {
+			l.Progress.Record(entry)
+		}
+
+		// check if the performance data should be captured
+		if l.Report != nil {
+			l.Report.Record(entry)
+		}
+
+		// capture the last error logged by makisu
+		if entry.failed() {
+			l.LastError = entry.Msg
+		}
+	}
+
+	// output lines which are not makisu logs or are not rendered without modification
+	if !ok || l.Raw {
+		fmt.Fprintln(l.out, line)
+
+		return
+	}
+
+	switch {
+	case entry.failed():
+		fmt.Fprintf(l.out, "[error] %s\n", entry.Msg)
+
+		return
+	case entry.Level == "warn":
+		fmt.Fprintf(l.out, "[warn] %s\n", entry.Msg)
+
+		return
+	}
+
+	fmt.Fprintln(l.out, render(entry))
+}
+
+// parseLogLine is a helper function to parse a makisu
+// log line in either the JSON or console format.
+//
+// The console format separates the timestamp, level, message
+// and JSON fields of the log line with tabs.
+func parseLogLine(line string) (*logEntry, bool) {
+	entry := new(logEntry)
+
+	// check if the line is in the JSON format
+	err := json.Unmarshal([]byte(line), entry)
+	if err == nil {
+		return entry, len(entry.Level) > 0
+	}
+
+	parts := strings.SplitN(line, "\t", 4)
+	if len(parts) < 3 {
+		return nil, false
+	}
+
+	ts, err := time.Parse(consoleTimeFormat, parts[0])
+	if err != nil {
+		return nil, false
+	}
+
+	// capture the fields for the log line i.e. the duration
+	if len(parts) == 4 {
+		_ = json.Unmarshal([]byte(parts[3]), entry)
+	}
+
+	entry.Level = strings.ToLower(colorCodes.ReplaceAllString(parts[1], ""))
+	entry.Msg = parts[2]
+	entry.TS = float64(ts.UnixNano()) / float64(time.Second)
+
+	return entry, true
+}
+
+// render is a helper function to format the
+// makisu log entry into a readable form.
+func render(entry *logEntry) string {
+	msg := entry.Msg
+
+	switch {
+	case stageMessage.MatchString(msg):
+		m := stageMessage.FindStringSubmatch(msg)
+
+		return fmt.Sprintf("==> stage %s: %s", m[1], m[2])
+	case stepMessage.MatchString(msg):
+		m := stepMessage.FindStringSubmatch(msg)
+
+		return fmt.Sprintf("  -> step %s: %s", m[1], m[2])
+	case cacheMessage.MatchString(msg):
+		return "     cache hit"
+	case skipMessage.MatchString(msg):
+		return "     skipped (a later step was cached)"
+	case executedMessage.MatchString(msg):
+		return fmt.Sprintf("     cache miss, executed in %s", duration(entry.Duration))
+	case committedMessage.MatchString(msg):
+		m := committedMessage.FindStringSubmatch(msg)
+
+		return fmt.Sprintf("     committed layer %s (%s bytes)", m[1], m[2])
+	case layerMessage.MatchString(msg):
+		return fmt.Sprintf("     pushed layer %s", layerMessage.FindStringSubmatch(msg)[1])
+	case existingLayerMessage.MatchString(msg):
+		return fmt.Sprintf("     layer %s already exists", existingLayerMessage.FindStringSubmatch(msg)[1])
+	case pushedMessage.MatchString(msg):
+		return fmt.Sprintf("==> pushed image %s in %s", pushedMessage.FindStringSubmatch(msg)[1], duration(entry.Duration))
+	default:
+		return msg
+	}
+}
+
+// duration is a helper function to format
+// the provided seconds as a duration.
+func duration(seconds float64) string {
+	return time.Duration(seconds * float64(time.Second)).Round(time.Millisecond).String()
+}
@@ -0,0 +1,161 @@
+// Copyright (c) 2022 Target Brands, Inc. All rights reserved.
+//
+// Use of this source code is governed by the LICENSE file in this repository.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestMakisu_LogProcessor_Write(t *testing.T) {
+	// setup types
+	logs := []string{
+		`{"level":"info","ts":1643040000.1,"msg":"* Stage 1/2 : FROM golang:1.17 AS builder"}`,
+		`{"level":"info","ts":1643040000.2,"msg":"* Step 1/2 (commit) : RUN go build"}`,
+		`{"level":"info","ts":1643040000.3,"msg":"* Skipping execution; cache was applied *"}`,
+		`{"level":"info","ts":1643040000.4,"msg":"* Step 2/2 (commit) : COPY . /app"}`,
+		`{"level":"info","ts":1643040001.6,"msg":"* Executed COPY . /app","duration":1.234}`,
+		`{"level":"info","ts":1643040001.7,"msg":"* Committed gzipped layer sha256:abc (1024 bytes)"}`,
+		`not a json line`,
+		`{"level":"info","ts":1643040002.1,"msg":"* Finished pushing layer sha256:abc"}`,
+		`{"level":"info","ts":1643040002.2,"msg":"* Skipped pushing existing layer sha256:def"}`,
+		`{"level":"info","ts":1643040002.3,"msg":"* Pushed image index.docker.io/octocat/hello-world:latest","duration":2.5}`,
+		`{"level":"warn","ts":1643040002.4,"msg":"Failed to push cache: timeout"}`,
+		`{"level":"error","ts":1643040002.5,"msg":"failed to push image: unauthorized"}`,
+	}
+
+	want := `==> stage 1/2: FROM golang:1.17 AS builder
+  -> step 1/2: RUN go build
+     cache hit
+  -> step 2/2: COPY . /app
+     cache miss, executed in 1.234s
+     committed layer sha256:abc (1024 bytes)
+not a json line
+     pushed layer sha256:abc
+     layer sha256:def already exists
+==> pushed image index.docker.io/octocat/hello-world:latest in 2.5s
+[warn] Failed to push cache: timeout
+[error] failed to push image: unauthorized
+
+makisu failed: failed to push image: unauthorized
+`
+
+	// run test
+	out := new(bytes.Buffer)
+	l := newLogProcessor(out)
+
+	for _, line := range logs {
+		// write the lines in parts to verify partial writes are buffered
+		_, _ = l.Write([]byte(line[:5]))
+		_, _ = l.Write([]byte(line[5:] + "\n"))
+	}
+
+	err := l.Close()
+	if err != nil {
+		t.Errorf("Close returned err: %v", err)
+	}
+
+	got := out.String()
+
+	if got != want {
+		t.Errorf("Write is %s, want %s", got, want)
+	}
+
+	if l.LastError != "failed to push image: unauthorized" {
+		t.Errorf("LastError is %s, want %s", l.LastError, "failed to push image: unauthorized")
+	}
+}
+
+func TestMakisu_LogProcessor_Close(t *testing.T) {
+	// setup types
+	out := new(bytes.Buffer)
+	l := newLogProcessor(out)
+
+	// run test
+	_, _ = l.Write([]byte(`{"level":"info","ts":1643040000.1,"msg":"Computed total image size 1024"}`))
+
+	err := l.Close()
+	if err != nil {
+		t.Errorf("Close returned err: %v", err)
+	}
+
+	if out.String() != "Computed total image size 1024\n" {
+		t.Errorf("Close is %s, want %s", out.String(), "Computed total image size 1024\n")
+	}
+}
+
+func TestMakisu_LogProcessor_Raw(t *testing.T) {
+	// setup types
+	logs := "2022-01-24T16:00:00.100Z\t\x1b[34mINFO\x1b[0m\t* Stage 1/1 : FROM alpine\n" +
+		"2022-01-24T16:00:00.200Z\t\x1b[34mINFO\x1b[0m\t* Step 1/1 (commit) : RUN make\n" +
+		"2022-01-24T16:00:01.700Z\t\x1b[34mINFO\x1b[0m\t* Executed RUN make\t{\"duration\": 1.5}\n" +
+		"2022-01-24T16:00:01.800Z\t\x1b[31mERROR\x1b[0m\tfailed to push image: unauthorized\n"
+
+	out := new(bytes.Buffer)
+
+	l := newLogProcessor(out)
+	l.Raw = true
+	l.Report = new(Report)
+
+	// run test
+	_, _ = l.Write([]byte(logs))
+
+	err := l.Close()
+	if err != nil {
+		t.Errorf("Close returned err: %v", err)
+	}
+
+	// the logs are output without modification
+	if out.String() != logs {
+		t.Errorf("Write is %q, want %q", out.String(), logs)
+	}
+
+	l.Report.Finish(time.Now(), time.Now(), nil)
+
+	if len(l.Report.Stages) != 1 || l.Report.Steps != 1 || l.Report.Stages[0].Steps[0].Duration != 1.6 {
+		t.Errorf("Report is %+v, want one stage with one step of 1.6s", l.Report)
+	}
+
+	if l.Report.Error != "failed to push image: unauthorized" {
+		t.Errorf("Report error is %s, want %s", l.Report.Error, "failed to push image: unauthorized")
+	}
+}
+
+func TestMakisu_parseLogLine(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		line string
+		want *logEntry
+	}{
+		{
+			line: `{"level":"info","ts":1643040000.5,"msg":"* Executed RUN make","duration":1.5}`,
+			want: &logEntry{Duration: 1.5, Level: "info", Msg: "* Executed RUN make", TS: 1643040000.5},
+		},
+		{
+			line: "2022-01-24T16:00:00.500Z\t\x1b[34mINFO\x1b[0m\t* Executed RUN make\t{\"duration\": 1.5}",
+			want: &logEntry{Duration: 1.5, Level: "info", Msg: "* Executed RUN make", TS: 1643040000.5},
+		},
+		{
+			line: "not a log line",
+			want: nil,
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		got, ok := parseLogLine(test.line)
+
+		if ok != (test.want != nil) {
+			t.Errorf("parseLogLine for %q is %v, want %v", test.line, ok, test.want != nil)
+
+			continue
+		}
+
+		if ok && *got != *test.want {
+			t.Errorf("parseLogLine is %+v, want %+v", got, test.want)
+		}
+	}
+}
@@ -0,0 +1,473 @@
+// Copyright (c) 2022 Target Brands, Inc. All rights reserved.
+//
+// Use of this source code is governed by the LICENSE file in this repository.
+
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// apkDatabase represents the path to the apk package database.
+	apkDatabase = "lib/apk/db/installed"
+
+	// dpkgDatabase represents the path to the dpkg package database.
+	dpkgDatabase = "var/lib/dpkg/status"
+
+	// dpkgDatabaseDir represents the directory containing a dpkg
+	// package database for each package i.e. distroless images.
+	dpkgDatabaseDir = "var/lib/dpkg/status.d/"
+
+	// maxBinarySize represents the maximum size of
+	// a binary inspected for Go module build info.
+	maxBinarySize = 512 * 1024 * 1024
+
+	// maxBuildInfoSize represents the maximum amount of a binary
+	// read after the header of the Go module build info.
+	maxBuildInfoSize = 1024 * 1024
+
+	// buildInfoChunkSize represents the amount of a binary
+	// read at once while searching for the Go module build info.
+	buildInfoChunkSize = 64 * 1024
+
+	// maxDatabaseLineSize represents the maximum
+	// length of a line in a package database.
+	maxDatabaseLineSize = 1024 * 1024
+)
+
+// package types for the packages found within an image.
+const (
+	apkPackage    = "apk"
+	debPackage    = "deb"
+	golangPackage = "golang"
+	rpmPackage    = "rpm"
+)
+
+var (
+	// buildInfoMagic represents the header for the
+	// build info embedded in a Go binary.
+	buildInfoMagic = []byte("\xff Go buildinf:")
+
+	// osReleaseFiles represents the paths to the
+	// file identifying the distribution of an image.
+	osReleaseFiles = map[string]bool{
+		"etc/os-release":     true,
+		"usr/lib/os-release": true,
+	}
+
+	// errUnsupportedDatabase represents the error when an image
+	// contains a package database which can not be read.
+	errUnsupportedDatabase = errors.New("unsupported package database")
+
+	// rpmDatabases represents the paths to the rpm package
+	// databases in the SQLite, NDB or Berkeley DB format.
+	rpmDatabases = map[string]bool{
+		"var/lib/rpm/Packages":              true,
+		"var/lib/rpm/Packages.db":           true,
+		"var/lib/rpm/rpmdb.sqlite":          true,
+		"usr/lib/sysimage/rpm/Packages":     true,
+		"usr/lib/sysimage/rpm/Packages.db":  true,
+		"usr/lib/sysimage/rpm/rpmdb.sqlite": true,
+	}
+)
+
+type (
+	// Distro represents the distribution of an image
+	// captured from the os-release file.
+	Distro struct {
+		// identifier for the distribution i.e. "alpine"
+		ID string
+		// version of the distribution i.e. "3.15.4"
+		VersionID string
+	}
+
+	// Package represents software found within an image.
+	Package struct {
+		// license declared for the package
+		License string
+		// path to the file the package was found in
+		Location string
+		// name of the package i.e. "musl" or "github.com/sirupsen/logrus"
+		Name string
+		// name of the source package the package was built from i.e. "openssl" for "libssl1.1"
+		Source string
+		// type of the package - options: (apk|deb|golang|rpm)
+		Type string
+		// version of the package
+		Version string
+	}
+)
+
+// SourceName outputs the name of the source package for the
+// Package which is used by the distro to track vulnerabilities.
+func (p *Package) SourceName() string {
+	// check if the source package is known
+	if len(p.Source) > 0 {
+		return p.Source
+	}
+
+	return p.Name
+}
+
+// PURL outputs the package URL for the Package.
+//
+// https://github.com/package-url/purl-spec
+func (p *Package) PURL(d *Distro) string {
+	// variable to store the namespace for the package
+	namespace := ""
+
+	// check if the package is installed by the distribution
+	if p.Type != golangPackage && d != nil && len(d.ID) > 0 {
+		namespace = d.ID + "/"
+	}
+
+	purl := fmt.Sprintf("pkg:%s/%s%s@%s", p.Type, namespace, p.Name, p.Version)
+
+	// check if the distribution version is known
+	if p.Type != golangPackage && d != nil && len(d.ID) > 0 && len(d.VersionID) > 0 {
+		purl += fmt.Sprintf("?distro=%s-%s", d.ID, d.VersionID)
+	}
+
+	return purl
+}
+
+// Packages outputs the distribution and the packages found
+// within the image from the OS package databases and the
+// module build info embedded in Go binaries.
+//
+// The packages which were found are returned with an error
+// wrapping errUnsupportedDatabase when the image contains a
+// package database which can not be read i.e. a corrupt rpm database.
+func (t *Tarball) Packages() (*Distro, []*Package, error) {
+	logrus.Tracef("finding packages in image tarball %s", t.Path)
+
+	files, err := t.Files(extractPackages)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// variable to store the distribution for the image
+	distro := new(Distro)
+
+	// variable to store the packages for the image
+	var packages []*Package
+
+	// variable to store the errors for the package databases which can not be read
+	var unsupported []string
+
+	for name, data := range files {
+		switch {
+		case osReleaseFiles[name]:
+			// prefer /etc/os-release over /usr/lib/os-release
+			if len(distro.ID) == 0 || name == "etc/os-release" {
+				distro = parseOSRelease(data)
+			}
+		case name == apkDatabase:
+			packages = append(packages, parseApkDatabase(name, data)...)
+		case name == dpkgDatabase || strings.HasPrefix(name, dpkgDatabaseDir):
+			packages = append(packages, parseDpkgDatabase(name, data)...)
+		case rpmDatabases[name]:
+			rpms, err := parseRpmDatabase(name, data)
+			if err != nil {
+				unsupported = append(unsupported, err.Error())
+
+				continue
+			}
+
+			packages = append(packages, rpms...)
+		default:
+			packages = append(packages, parseBuildInfo(name, data)...)
+		}
+	}
+
+	// sort the packages for consistent output
+	sort.Slice(packages, func(i, j int) bool {
+		if packages[i].Location != packages[j].Location {
+			return packages[i].Location < packages[j].Location
+		}
+
+		return packages[i].Name < packages[j].Name
+	})
+
+	// check if a package database could not be read
+	if len(unsupported) > 0 {
+		sort.Strings(unsupported)
+
+		return distro, packages, fmt.Errorf("%w: %s", errUnsupportedDatabase, strings.Join(unsupported, "; "))
+	}
+
+	return distro, packages, nil
+}
+
+// extractPackages is a helper function to capture the files
+// within the image containing information about packages.
+func extractPackages(hdr *tar.Header, r io.Reader) ([]byte, bool, error) {
+	name := cleanPath(hdr.Name)
+
+	// check if the file is a package database
+	if osReleaseFiles[name] || name == apkDatabase || name == dpkgDatabase ||
+		strings.HasPrefix(name, dpkgDatabaseDir) || rpmDatabases[name] {
+		data, err := io.ReadAll(r)
+
+		return data, true, err
+	}
+
+	// skip files which are not executable or too large
+	if hdr.Mode&0111 == 0 || hdr.Size < int64(len(buildInfoMagic)) || hdr.Size > maxBinarySize {
+		return nil, false, nil
+	}
+
+	// capture the module build info from the binary
+	info, err := readBuildInfo(r)
+	if err != nil || len(info) == 0 {
+		return nil, false, err
+	}
+
+	return info, true, nil
+}
+
+// readBuildInfo is a helper function to capture the module build info
+// from the binary read from the provided reader without holding the
+// entire binary in memory.
+//
+// The binary is read in chunks until the header of the build info
+// is found and only the build info following the header is kept.
+func readBuildInfo(r io.Reader) ([]byte, error) {
+	chunk := make([]byte, buildInfoChunkSize)
+
+	// variable to store the bytes which may contain the header
+	var window []byte
+
+	for {
+		n, err := r.Read(chunk)
+
+		window = append(window, chunk[:n]...)
+
+		// check if the header of the build info was found
+		if i := bytes.Index(window, buildInfoMagic); i >= 0 {
+			window = window[i:]
+
+			// capture the remaining build info after the header
+			rest, err := io.ReadAll(io.LimitReader(r, int64(maxBuildInfoSize)))
+			if err != nil {
+				return nil, err
+			}
+
+			return buildInfo(append(window, rest...)), nil
+		}
+
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		// keep the bytes which may contain the start of the header
+		if keep := len(buildInfoMagic) - 1; len(window) > keep {
+			window = append(window[:0], window[len(window)-keep:]...)
+		}
+	}
+}
+
+// buildInfo is a helper function to capture the module
+// build info embedded in a Go binary built with Go 1.18+.
+func buildInfo(data []byte) []byte {
+	for offset := 0; ; {
+		i := bytes.Index(data[offset:], buildInfoMagic)
+		if i < 0 {
+			return nil
+		}
+
+		i += offset
+		offset = i + 1
+
+		// check if the header is complete
+		if len(data) < i+32 {
+			continue
+		}
+
+		// check if the build info is stored inline
+		if data[i+15]&0x2 == 0 {
+			return nil
+		}
+
+		// skip the Go version preceding the module info
+		p := data[i+32:]
+
+		_, p = varintString(p)
+
+		mod, _ := varintString(p)
+
+		// trim the sentinels surrounding the module info
+		if len(mod) >= 33 && mod[len(mod)-17] == '\n' {
+			return mod[16 : len(mod)-16]
+		}
+
+		return nil
+	}
+}
+
+// varintString is a helper function to read a string prefixed
+// by its length as a varint and output the remaining data.
+func varintString(data []byte) ([]byte, []byte) {
+	n, size := binary.Uvarint(data)
+	if size <= 0 || n > uint64(len(data)-size) {
+		return nil, nil
+	}
+
+	return data[size : size+int(n)], data[size+int(n):]
+}
+
+// parseBuildInfo is a helper function to capture the
+// modules from the build info of a Go binary.
+func parseBuildInfo(location string, data []byte) []*Package {
+	// variable to store the modules for the binary
+	var packages []*Package
+
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Split(line, "\t")
+
+		// skip lines which do not describe a module
+		if len(fields) < 3 {
+			continue
+		}
+
+		switch fields[0] {
+		case "mod", "dep":
+			packages = append(packages, &Package{
+				Location: location,
+				Name:     fields[1],
+				Type:     golangPackage,
+				Version:  fields[2],
+			})
+		case "=>":
+			// the module is replaced by the previous module
+			if len(packages) > 0 {
+				packages[len(packages)-1].Name = fields[1]
+				packages[len(packages)-1].Version = fields[2]
+			}
+		}
+	}
+
+	return packages
+}
+
+// parseApkDatabase is a helper function to capture
+// the packages from an apk package database.
+func parseApkDatabase(location string, data []byte) []*Package {
+	// variable to store the packages for the database
+	var packages []*Package
+
+	pkg := &Package{Location: location, Type: apkPackage}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, maxDatabaseLineSize)
+
+	for {
+		ok := scanner.Scan()
+		line := scanner.Text()
+
+		// check if the package is complete
+		if !ok || len(line) == 0 {
+			if len(pkg.Name) > 0 {
+				packages = append(packages, pkg)
+			}
+
+			if !ok {
+				return packages
+			}
+
+			pkg = &Package{Location: location, Type: apkPackage}
+
+			continue
+		}
+
+		key, value, _ := cut(line, ":")
+
+		switch key {
+		case "P":
+			pkg.Name = value
+		case "V":
+			pkg.Version = value
+		case "L":
+			pkg.License = value
+		case "o":
+			pkg.Source = value
+		}
+	}
+}
+
+// parseDpkgDatabase is a helper function to capture
+// the installed packages from a dpkg package database.
+func parseDpkgDatabase(location string, data []byte) []*Package {
+	// variable to store the packages for the database
+	var packages []*Package
+
+	for _, paragraph := range strings.Split(string(data), "\n\n") {
+		pkg := &Package{Location: location, Type: debPackage}
+
+		// variable to store if the package is installed
+		installed := true
+
+		for _, line := range strings.Split(paragraph, "\n") {
+			// skip the continuation of a field
+			if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+				continue
+			}
+
+			key, value, _ := cut(line, ":")
+			value = strings.TrimSpace(value)
+
+			switch key {
+			case "Package":
+				pkg.Name = value
+			case "Version":
+				pkg.Version = value
+			case "Source":
+				// remove the version of the source package i.e. "openssl (1.1.1n-0+deb11u1)"
+				pkg.Source, _, _ = cut(value, " ")
+			case "Status":
+				installed = strings.HasSuffix(value, " installed")
+			}
+		}
+
+		if len(pkg.Name) > 0 && installed {
+			packages = append(packages, pkg)
+		}
+	}
+
+	return packages
+}
+
+// parseOSRelease is a helper function to capture
+// the distribution from an os-release file.
+func parseOSRelease(data []byte) *Distro {
+	distro := new(Distro)
+
+	for _, line := range strings.Split(string(data), "\n") {
+		key, value, _ := cut(strings.TrimSpace(line), "=")
+		value = strings.Trim(value, `"'`)
+
+		switch key {
+		case "ID":
+			distro.ID = value
+		case "VERSION_ID":
+			distro.VersionID = value
+		}
+	}
+
+	return distro
+}
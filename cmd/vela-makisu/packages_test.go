@@ -0,0 +1,282 @@
+// Copyright (c) 2022 Target Brands, Inc. All rights reserved.
+//
+// Use of this source code is governed by the LICENSE file in this repository.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+// testApkDatabase represents an apk package database for testing.
+const testApkDatabase = `C:Q1abc=
+P:musl
+V:1.2.2-r7
+L:MIT
+
+C:Q1def=
+P:libcrypto1.1
+V:1.1.1l-r7
+L:OpenSSL
+o:openssl
+`
+
+// testDpkgDatabase represents a dpkg package database for testing.
+const testDpkgDatabase = `Package: libc6
+Status: install ok installed
+Source: glibc (2.31-13)
+Version: 2.31-13
+Description: GNU C Library
+ multiple lines
+
+Package: removed
+Status: deinstall ok config-files
+Version: 1.0.0
+`
+
+func TestMakisu_Tarball_Packages(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	testTarball(t, "image.tar",
+		[]testFile{
+			{Name: "etc/os-release", Content: "ID=alpine\nVERSION_ID=3.15.4\n"},
+			{Name: "lib/apk/db/installed", Content: testApkDatabase},
+			{Name: "var/lib/dpkg/status.d/base", Content: "Package: base-files\nVersion: 11.1\n"},
+			{Name: "usr/bin/script", Content: "#!/bin/sh\necho hello\n", Mode: 0755},
+		},
+	)
+
+	// setup types
+	wantDistro := &Distro{ID: "alpine", VersionID: "3.15.4"}
+
+	want := []*Package{
+		{License: "OpenSSL", Location: apkDatabase, Name: "libcrypto1.1", Source: "openssl", Type: apkPackage, Version: "1.1.1l-r7"},
+		{License: "MIT", Location: apkDatabase, Name: "musl", Type: apkPackage, Version: "1.2.2-r7"},
+		{Location: "var/lib/dpkg/status.d/base", Name: "base-files", Type: debPackage, Version: "11.1"},
+	}
+
+	tarball, err := openTarball("image.tar")
+	if err != nil {
+		t.Errorf("openTarball returned err: %v", err)
+	}
+
+	gotDistro, got, err := tarball.Packages()
+	if err != nil {
+		t.Errorf("Packages returned err: %v", err)
+	}
+
+	if !reflect.DeepEqual(gotDistro, wantDistro) {
+		t.Errorf("Packages distro is %v, want %v", gotDistro, wantDistro)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Packages is %v, want %v", got, want)
+	}
+}
+
+func TestMakisu_Tarball_Packages_Rpm(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	testTarball(t, "image.tar",
+		[]testFile{
+			{Name: "etc/os-release", Content: "ID=rocky\nVERSION_ID=8.5\n"},
+			{Name: "var/lib/rpm/rpmdb.sqlite", Content: string(testSqliteDatabase(testRpmHeader("bash", "4.4.20", "3.el8", "", "GPLv3+")))},
+		},
+	)
+
+	// setup types
+	want := []*Package{
+		{License: "GPLv3+", Location: "var/lib/rpm/rpmdb.sqlite", Name: "bash", Source: "bash", Type: rpmPackage, Version: "4.4.20-3.el8"},
+	}
+
+	tarball, err := openTarball("image.tar")
+	if err != nil {
+		t.Errorf("openTarball returned err: %v", err)
+	}
+
+	_, got, err := tarball.Packages()
+	if err != nil {
+		t.Errorf("Packages returned err: %v", err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Packages is %v, want %v", got, want)
+	}
+}
+
+func TestMakisu_Tarball_Packages_InvalidRpm(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	testTarball(t, "image.tar",
+		[]testFile{
+			{Name: "etc/os-release", Content: "ID=rhel\nVERSION_ID=8.5\n"},
+			{Name: "var/lib/rpm/rpmdb.sqlite", Content: "SQLite format 3"},
+		},
+	)
+
+	tarball, err := openTarball("image.tar")
+	if err != nil {
+		t.Errorf("openTarball returned err: %v", err)
+	}
+
+	_, _, err = tarball.Packages()
+	if !errors.Is(err, errUnsupportedDatabase) {
+		t.Errorf("Packages returned err %v, want %v", err, errUnsupportedDatabase)
+	}
+}
+
+func TestMakisu_Package_PURL(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		pkg    *Package
+		distro *Distro
+		want   string
+	}{
+		{
+			pkg:    &Package{Name: "musl", Type: apkPackage, Version: "1.2.2-r7"},
+			distro: &Distro{ID: "alpine", VersionID: "3.15.4"},
+			want:   "pkg:apk/alpine/musl@1.2.2-r7?distro=alpine-3.15.4",
+		},
+		{
+			pkg:    &Package{Name: "libc6", Type: debPackage, Version: "2.31-13"},
+			distro: &Distro{},
+			want:   "pkg:deb/libc6@2.31-13",
+		},
+		{
+			pkg:    &Package{Name: "github.com/sirupsen/logrus", Type: golangPackage, Version: "v1.8.1"},
+			distro: &Distro{ID: "alpine", VersionID: "3.15.4"},
+			want:   "pkg:golang/github.com/sirupsen/logrus@v1.8.1",
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		got := test.pkg.PURL(test.distro)
+
+		if got != test.want {
+			t.Errorf("PURL is %s, want %s", got, test.want)
+		}
+	}
+}
+
+func TestMakisu_readBuildInfo(t *testing.T) {
+	// setup types
+	path, err := os.Executable()
+	if err != nil {
+		t.Fatalf("unable to find test binary: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("unable to read test binary: %v", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("unable to open test binary: %v", err)
+	}
+	defer f.Close()
+
+	got, err := readBuildInfo(f)
+	if err != nil {
+		t.Errorf("readBuildInfo returned err: %v", err)
+	}
+
+	want := buildInfo(data)
+
+	if len(want) == 0 || !bytes.Equal(got, want) {
+		t.Errorf("readBuildInfo is %q, want %q", got, want)
+	}
+
+	got, err = readBuildInfo(strings.NewReader("#!/bin/sh\necho hello\n"))
+	if err != nil || got != nil {
+		t.Errorf("readBuildInfo is %q (err: %v), want no build info", got, err)
+	}
+}
+
+func TestMakisu_buildInfo(t *testing.T) {
+	// setup types
+	path, err := os.Executable()
+	if err != nil {
+		t.Fatalf("unable to find test binary: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("unable to read test binary: %v", err)
+	}
+
+	got := parseBuildInfo("usr/bin/app", buildInfo(data))
+
+	// variable to store if the dependency was found
+	found := false
+
+	for _, p := range got {
+		if p.Name == "github.com/sirupsen/logrus" && strings.HasPrefix(p.Version, "v") {
+			found = true
+		}
+	}
+
+	if !found {
+		t.Errorf("buildInfo is %v, want github.com/sirupsen/logrus", got)
+	}
+
+	if buildInfo([]byte("#!/bin/sh\necho hello\n")) != nil {
+		t.Errorf("buildInfo should be nil for a script")
+	}
+}
+
+func TestMakisu_parseBuildInfo(t *testing.T) {
+	// setup types
+	info := "path\tgithub.com/octocat/app\n" +
+		"mod\tgithub.com/octocat/app\t(devel)\t\n" +
+		"dep\tgithub.com/sirupsen/logrus\tv1.8.1\th1:abc=\n" +
+		"dep\tgithub.com/octocat/lib\tv1.0.0\t\n" +
+		"=>\tgithub.com/octocat/fork\tv1.0.1\th1:def=\n"
+
+	want := []*Package{
+		{Location: "app", Name: "github.com/octocat/app", Type: golangPackage, Version: "(devel)"},
+		{Location: "app", Name: "github.com/sirupsen/logrus", Type: golangPackage, Version: "v1.8.1"},
+		{Location: "app", Name: "github.com/octocat/fork", Type: golangPackage, Version: "v1.0.1"},
+	}
+
+	got := parseBuildInfo("app", []byte(info))
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseBuildInfo is %v, want %v", got, want)
+	}
+}
+
+func TestMakisu_parseDpkgDatabase(t *testing.T) {
+	// setup types
+	want := []*Package{
+		{Location: dpkgDatabase, Name: "libc6", Source: "glibc", Type: debPackage, Version: "2.31-13"},
+	}
+
+	got := parseDpkgDatabase(dpkgDatabase, []byte(testDpkgDatabase))
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseDpkgDatabase is %v, want %v", got, want)
+	}
+}
+
+func TestMakisu_parseOSRelease(t *testing.T) {
+	// setup types
+	want := &Distro{ID: "debian", VersionID: "11"}
+
+	got := parseOSRelease([]byte("PRETTY_NAME=\"Debian GNU/Linux 11 (bullseye)\"\nID=debian\nVERSION_ID=\"11\"\n"))
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseOSRelease is %v, want %v", got, want)
+	}
+}
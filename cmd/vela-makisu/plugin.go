@@ -6,18 +6,25 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
 
 	"github.com/sirupsen/logrus"
 )
 
 // Plugin represents the configuration loaded for the plugin.
 type Plugin struct {
+	// enables setting the action for the plugin - options: (build|promote|push_tarball)
+	Action string
 	// build arguments loaded for the plugin
 	Build *Build
+	// enables printing the resolved configuration without building the image
+	DryRun bool
 	// Used for translating the raw docker configuration
 	Global *Global
 	// enables setting configuration for the global flags
 	GlobalRaw string
+	// promote arguments loaded for the plugin
+	Promote *Promote
 	// registry arguments loaded for the plugin
 	Registry *Registry
 }
@@ -26,8 +33,14 @@ type Plugin struct {
 func (p *Plugin) Exec() error {
 	logrus.Debug("running plugin with provided configuration")
 
-	// output makisu version for troubleshooting
-	err := execCmd(versionCmd())
+	// capture the backend for building the image
+	builder, err := newBuilder(p.Build.Builder)
+	if err != nil {
+		return err
+	}
+
+	// output builder version for troubleshooting
+	err = execCmd(builder.Version())
 	if err != nil {
 		return err
 	}
@@ -45,12 +58,37 @@ func (p *Plugin) Exec() error {
 	p.Build.GlobalFlags = globalFlags
 
 	// set required configuration for registry config
+	//
+	// any custom registry configuration was merged into the file
 	p.Build.RegistryConfig = configPath
 
+	// check if the image should be promoted
+	if p.Action == promoteAction {
+		// execute promote action
+		return p.Promote.Exec(configPath)
+	}
+
+	// check if the image tarball should be pushed
+	if p.Action == pushTarballAction {
+		// execute push tarball action
+		return p.Build.PushTarball()
+	}
+
+	// check if multiple images should be built
+	if len(p.Build.Images) > 0 {
+		// execute build action for each image
+		return p.Build.ExecImages()
+	}
+
 	// execute build action
 	return p.Build.Exec()
 }
 
+// Secrets outputs the sensitive values for configuring the Plugin.
+func (p *Plugin) Secrets() []string {
+	return append(p.Build.Secrets(), p.Registry.Secrets()...)
+}
+
 // Unmarshal captures the provided properties and
 // serializes them into their expected form.
 func (p *Plugin) Unmarshal() error {
@@ -71,6 +109,17 @@ func (p *Plugin) Unmarshal() error {
 		}
 	}
 
+	// check if the makisu logs should be parsed
+	if p.Build != nil && p.Build.ParseLogs {
+		// check if log flags were provided
+		if p.Global.Log == nil {
+			p.Global.Log = &Log{}
+		}
+
+		// the makisu logs must be JSON to be parsed
+		p.Global.Log.Fmt = "json"
+	}
+
 	return nil
 }
 
@@ -78,12 +127,36 @@ func (p *Plugin) Unmarshal() error {
 func (p *Plugin) Validate() error {
 	logrus.Debug("validating plugin configuration")
 
+	// when user adds configuration for additional registries
+	err := p.Registry.Unmarshal()
+	if err != nil {
+		return err
+	}
+
 	// validate config configuration
-	err := p.Registry.Validate()
+	err = p.Registry.Validate()
 	if err != nil {
 		return err
 	}
 
+	// check if the image should be promoted
+	if p.Action == promoteAction {
+		// load the policies restricting the registries and tags
+		p.Promote.Policies, err = loadPolicies(p.Build.PolicyRaw, p.Build.PolicyFile)
+		if err != nil {
+			return err
+		}
+
+		// validate promote configuration
+		return p.Promote.Validate()
+	}
+
+	// verify the action is supported
+	if len(p.Action) > 0 && p.Action != buildAction && p.Action != pushTarballAction {
+		return fmt.Errorf("invalid action provided: %s (options: %s|%s|%s)",
+			p.Action, buildAction, promoteAction, pushTarballAction)
+	}
+
 	// when user adds configuration additional options
 	// for: docker, http, redis
 	err = p.Build.Unmarshal()
@@ -91,6 +164,12 @@ func (p *Plugin) Validate() error {
 		return err
 	}
 
+	// check if the image tarball should be pushed
+	if p.Action == pushTarballAction {
+		// validate push tarball configuration
+		return p.Build.ValidateTarball()
+	}
+
 	// validate build configuration
 	err = p.Build.Validate()
 	if err != nil {
@@ -8,6 +8,8 @@ import (
 	"reflect"
 	"testing"
 	"time"
+
+	"github.com/spf13/afero"
 )
 
 func TestMakisu_Plugin_Exec(t *testing.T) {
@@ -44,6 +46,11 @@ func TestMakisu_Plugin_Unmarshal(t *testing.T) {
 }
 
 func TestMakisu_Plugin_Validate(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	_ = afero.WriteFile(appFS, "Dockerfile", []byte("FROM alpine:3.16 AS dev\n"), 0644)
+
 	// setup types
 	p := &Plugin{
 		Registry: &Registry{
@@ -109,3 +116,93 @@ func TestMakisu_Plugin_Validate_BadBuild(t *testing.T) {
 		t.Errorf("Validate should have returned err")
 	}
 }
+
+func TestMakisu_Plugin_Validate_Promote(t *testing.T) {
+	// setup types
+	p := &Plugin{
+		Action: promoteAction,
+		Registry: &Registry{
+			Password: "superSecretPassword",
+			Name:     "index.docker.io",
+			Username: "octocat",
+		},
+		Build: &Build{},
+		Promote: &Promote{
+			Source:  "index.docker.io/octocat/hello-world:staging",
+			Targets: []string{"index.docker.io/octocat/hello-world:prod"},
+		},
+	}
+
+	err := p.Validate()
+	if err != nil {
+		t.Errorf("Validate returned err: %v", err)
+	}
+}
+
+func TestMakisu_Plugin_Validate_PushTarball(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	_ = afero.WriteFile(appFS, "image.tar", []byte{}, 0644)
+
+	// setup types
+	p := &Plugin{
+		Action: pushTarballAction,
+		Registry: &Registry{
+			Password: "superSecretPassword",
+			Name:     "index.docker.io",
+			Username: "octocat",
+		},
+		Build: &Build{
+			Destination: "image.tar",
+			Tag:         "index.docker.io/octocat/hello-world:latest",
+		},
+	}
+
+	err := p.Validate()
+	if err != nil {
+		t.Errorf("Validate returned err: %v", err)
+	}
+}
+
+func TestMakisu_Plugin_Validate_BadAction(t *testing.T) {
+	// setup types
+	p := &Plugin{
+		Action: "foo",
+		Registry: &Registry{
+			Password: "superSecretPassword",
+			Name:     "index.docker.io",
+			Username: "octocat",
+		},
+		Build: &Build{},
+	}
+
+	err := p.Validate()
+	if err == nil {
+		t.Errorf("Validate should have returned err")
+	}
+}
+
+func TestMakisu_Plugin_Unmarshal_ParseLogs(t *testing.T) {
+	// setup types
+	p := &Plugin{
+		Build: &Build{
+			ParseLogs: true,
+		},
+		GlobalRaw: `{"cpu": {}, "log": { "fmt": "console", "level": "info" } }`,
+	}
+
+	want := &Log{
+		Fmt:   "json",
+		Level: "info",
+	}
+
+	err := p.Unmarshal()
+	if err != nil {
+		t.Errorf("Unmarshal returned err: %v", err)
+	}
+
+	if !reflect.DeepEqual(p.Global.Log, want) {
+		t.Errorf("Unmarshal is %+v, want %+v", p.Global.Log, want)
+	}
+}
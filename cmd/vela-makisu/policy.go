@@ -0,0 +1,303 @@
+// Copyright (c) 2022 Target Brands, Inc. All rights reserved.
+//
+// Use of this source code is governed by the LICENSE file in this repository.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+	"github.com/uber/makisu/lib/docker/image"
+)
+
+const (
+	// regexPrefix represents the prefix for a policy
+	// pattern containing a regular expression.
+	regexPrefix = "regex:"
+
+	// defaultPolicyFile represents the path to the policy
+	// enforced for every build when mounted into the image.
+	defaultPolicyFile = "/etc/vela-makisu/policy.json"
+)
+
+// Policy represents the organisation-level restrictions
+// for the registries and tags an image is pushed to.
+type Policy struct {
+	// enables restricting the registries the image is pushed to i.e. "docker.company.com"
+	AllowedRegistries []string `json:"allowed_registries"`
+	// enables forbidding tags for the image i.e. "latest"
+	ForbiddenTags []string `json:"forbidden_tags"`
+	// enables requiring every tag for the image to match one of the patterns
+	RequiredTags []string `json:"required_tags"`
+}
+
+// Validate verifies the Policy is properly configured.
+func (p *Policy) Validate() error {
+	logrus.Trace("validating policy configuration")
+
+	patterns := append(append(append([]string{}, p.AllowedRegistries...), p.ForbiddenTags...), p.RequiredTags...)
+
+	for _, pattern := range patterns {
+		// verify the pattern is valid
+		_, err := matchPattern(pattern, "")
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Verify verifies the registries and tags the
+// image is pushed to by the Build are allowed.
+func (p *Policy) Verify(b *Build) error {
+	logrus.Trace("verifying policy for the build")
+
+	// variable to store the registries the image is pushed to
+	registries := append([]string{}, b.Pushes...)
+
+	// variable to store the names for the image
+	names := []string{b.Tag}
+
+	for _, replica := range b.Replicas {
+		names = append(names, replica)
+
+		// capture the registry for the replica
+		name, err := image.ParseNameForPull(replica)
+		if err != nil {
+			return fmt.Errorf("invalid replica %s: %w", replica, err)
+		}
+
+		registries = append(registries, name.GetRegistry())
+	}
+
+	// check if the registries are restricted
+	if len(p.AllowedRegistries) > 0 {
+		for _, registry := range registries {
+			ok, err := matchAny(p.AllowedRegistries, registry)
+			if err != nil {
+				return err
+			}
+
+			if !ok {
+				return fmt.Errorf("registry %s is not allowed by policy (allowed_registries: %s)",
+					registry, strings.Join(p.AllowedRegistries, ", "))
+			}
+		}
+	}
+
+	for _, n := range names {
+		_, tag := splitTag(n)
+
+		// default to the tag used by the builder
+		if len(tag) == 0 {
+			tag = "latest"
+		}
+
+		// verify the tag is not forbidden
+		forbidden, err := matchAny(p.ForbiddenTags, tag)
+		if err != nil {
+			return err
+		}
+
+		if forbidden {
+			return fmt.Errorf("tag %s for %s is forbidden by policy (forbidden_tags: %s)",
+				tag, n, strings.Join(p.ForbiddenTags, ", "))
+		}
+
+		// check if the tags are required to match a pattern
+		if len(p.RequiredTags) == 0 {
+			continue
+		}
+
+		required, err := matchAny(p.RequiredTags, tag)
+		if err != nil {
+			return err
+		}
+
+		if !required {
+			return fmt.Errorf("tag %s for %s does not match policy (required_tags: %s)",
+				tag, n, strings.Join(p.RequiredTags, ", "))
+		}
+	}
+
+	return nil
+}
+
+// loadPolicies is a helper function to load the policy mounted
+// into the image, the policy file and the raw policy provided.
+func loadPolicies(raw, path string) ([]*Policy, error) {
+	logrus.Trace("loading policies for the build")
+
+	// use custom filesystem which enables us to test
+	a := &afero.Afero{
+		Fs: appFS,
+	}
+
+	// variable to store the policies for the build
+	var policies []*Policy
+
+	// variable to store the policy documents to load
+	var documents []string
+
+	// check if a policy is mounted into the image
+	content, err := a.ReadFile(defaultPolicyFile)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("unable to read policy %s: %w", defaultPolicyFile, err)
+	}
+
+	if err == nil {
+		logrus.Infof("enforcing policy from %s", defaultPolicyFile)
+
+		documents = append(documents, string(content))
+	}
+
+	// check if a policy file is provided
+	if len(path) > 0 && path != defaultPolicyFile {
+		content, err = a.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read policy %s: %w", path, err)
+		}
+
+		documents = append(documents, string(content))
+	}
+
+	// check if a raw policy is provided
+	if len(raw) > 0 {
+		documents = append(documents, raw)
+	}
+
+	for _, document := range documents {
+		policy := new(Policy)
+
+		// serialize the policy into expected Policy type
+		err = json.Unmarshal([]byte(document), policy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid policy provided: %w", err)
+		}
+
+		policies = append(policies, policy)
+	}
+
+	return policies, nil
+}
+
+// VerifyBaseImages verifies every image the Dockerfile
+// derives from is allowed by the AllowedBaseImages.
+func (b *Build) VerifyBaseImages(d *Dockerfile) error {
+	logrus.Trace("verifying base images for the build")
+
+	// check if the base images are restricted
+	if len(b.AllowedBaseImages) == 0 {
+		return nil
+	}
+
+	for _, base := range b.baseImages(d) {
+		err := b.verifyBaseImage(d.Path, base.Line, base.Ref)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// verifyBaseImage is a helper function to verify the
+// provided image is allowed by the AllowedBaseImages.
+func (b *Build) verifyBaseImage(path string, line int, ref string) error {
+	// variable to store the names to match for the image
+	names := []string{ref}
+
+	// capture the full name of the image i.e. "index.docker.io/library/alpine:latest"
+	name, err := image.ParseNameForPull(ref)
+	if err == nil && name.IsValid() {
+		names = append(names, name.String())
+	}
+
+	for _, n := range names {
+		ok, err := matchAny(b.AllowedBaseImages, n)
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%s: line %d: base image %s is not allowed by allowed_base_images", path, line, ref)
+}
+
+// matchPattern is a helper function to match the provided value
+// against a glob pattern i.e. "docker.company.com/*" or a
+// regular expression prefixed with "regex:".
+//
+// Both patterns must match the entire value.
+func matchPattern(pattern, value string) (bool, error) {
+	// check if the pattern is a regular expression
+	if strings.HasPrefix(pattern, regexPrefix) {
+		// anchor the expression so it can not match part of the value
+		re, err := regexp.Compile("^(?:" + strings.TrimPrefix(pattern, regexPrefix) + ")$")
+		if err != nil {
+			return false, fmt.Errorf("invalid pattern %s: %w", pattern, err)
+		}
+
+		return re.MatchString(value), nil
+	}
+
+	// convert the glob to a regular expression where "*" matches any characters
+	glob := regexp.QuoteMeta(pattern)
+	glob = strings.ReplaceAll(glob, `\*`, `.*`)
+	glob = strings.ReplaceAll(glob, `\?`, `.`)
+
+	return regexp.MustCompile("^" + glob + "$").MatchString(value), nil
+}
+
+// matchAny is a helper function to match the provided
+// value against any of the provided patterns.
+func matchAny(patterns []string, value string) (bool, error) {
+	for _, pattern := range patterns {
+		ok, err := matchPattern(pattern, value)
+		if err != nil {
+			return false, err
+		}
+
+		if ok {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// expand is a helper function to substitute the provided
+// variables within the value i.e. "golang:${GO_VERSION}".
+func expand(value string, vars map[string]string) string {
+	return os.Expand(value, func(name string) string {
+		// check if a default value is provided i.e. "${VERSION:-1.0.0}"
+		if key, def, ok := cut(name, ":-"); ok {
+			if len(vars[key]) > 0 {
+				return vars[key]
+			}
+
+			return def
+		}
+
+		// check if an alternate value is provided i.e. "${VERSION:+-alpine}"
+		if key, alt, ok := cut(name, ":+"); ok {
+			if len(vars[key]) > 0 {
+				return alt
+			}
+
+			return ""
+		}
+
+		return vars[name]
+	})
+}
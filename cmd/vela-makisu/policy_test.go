@@ -0,0 +1,346 @@
+// Copyright (c) 2022 Target Brands, Inc. All rights reserved.
+//
+// Use of this source code is governed by the LICENSE file in this repository.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+func TestMakisu_Build_VerifyBaseImages(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		build   *Build
+		file    string
+		failure bool
+	}{
+		{ // no allowed base images
+			build:   &Build{},
+			file:    "FROM alpine\n",
+			failure: false,
+		},
+		{ // allowed by normalized name
+			build:   &Build{AllowedBaseImages: []string{"index.docker.io/library/*"}},
+			file:    "FROM alpine:3.15\n",
+			failure: false,
+		},
+		{ // allowed by regular expression
+			build:   &Build{AllowedBaseImages: []string{"regex:docker\\.company\\.com/(base|golang)/.*"}},
+			file:    "FROM docker.company.com/golang/go:1.17\n",
+			failure: false,
+		},
+		{ // not allowed
+			build:   &Build{AllowedBaseImages: []string{"docker.company.com/*"}},
+			file:    "FROM docker.company.com/golang:1.17 AS builder\nFROM alpine\n",
+			failure: true,
+		},
+		{ // allowed with previous stages
+			build:   &Build{AllowedBaseImages: []string{"docker.company.com/*", "scratch"}},
+			file:    "FROM docker.company.com/golang:1.17 AS builder\nFROM builder AS test\nFROM scratch\nCOPY --from=0 /app /app\n",
+			failure: false,
+		},
+		{ // not allowed with copy from image
+			build:   &Build{AllowedBaseImages: []string{"docker.company.com/*"}},
+			file:    "FROM docker.company.com/alpine:3.15\nCOPY --from=golang:1.17 /usr/local/go /usr/local/go\n",
+			failure: true,
+		},
+		{ // allowed with default arg
+			build:   &Build{AllowedBaseImages: []string{"docker.company.com/*"}},
+			file:    "ARG REGISTRY=docker.company.com\nFROM ${REGISTRY}/alpine:3.15\n",
+			failure: false,
+		},
+		{ // not allowed with build arg
+			build: &Build{
+				AllowedBaseImages: []string{"docker.company.com/*"},
+				BuildArgs:         []string{"REGISTRY=index.docker.io"},
+			},
+			file:    "ARG REGISTRY=docker.company.com\nFROM ${REGISTRY}/alpine:3.15\n",
+			failure: true,
+		},
+		{ // invalid pattern
+			build:   &Build{AllowedBaseImages: []string{"regex:("}},
+			file:    "FROM alpine\n",
+			failure: true,
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		appFS = afero.NewMemMapFs()
+
+		_ = afero.WriteFile(appFS, "Dockerfile", []byte(test.file), 0644)
+
+		d, err := parseDockerfile("Dockerfile")
+		if err != nil {
+			t.Errorf("parseDockerfile returned err: %v", err)
+		}
+
+		err = test.build.VerifyBaseImages(d)
+
+		if test.failure && err == nil {
+			t.Errorf("VerifyBaseImages should have returned err for %q", test.file)
+		}
+
+		if !test.failure && err != nil {
+			t.Errorf("VerifyBaseImages returned err for %q: %v", test.file, err)
+		}
+	}
+}
+
+func TestMakisu_matchPattern(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		pattern string
+		value   string
+		want    bool
+	}{
+		{pattern: "alpine", value: "alpine", want: true},
+		{pattern: "alpine", value: "alpine:3.15", want: false},
+		{pattern: "alpine:*", value: "alpine:3.15", want: true},
+		{pattern: "alpine:3.1?", value: "alpine:3.15", want: true},
+		{pattern: "docker.company.com/*", value: "docker.company.com/base/alpine:3.15", want: true},
+		{pattern: "docker.company.com/*", value: "docker.company.com.evil.io/alpine", want: false},
+		{pattern: "regex:alpine:3\\..*", value: "alpine:3.15", want: true},
+		{pattern: "regex:alpine:3\\..*", value: "alpine:latest", want: false},
+		{pattern: "regex:alpine:3\\.", value: "alpine:3.15", want: false},
+		{pattern: "regex:docker\\.company\\.com", value: "docker.company.com", want: true},
+		{pattern: "regex:docker\\.company\\.com", value: "docker.company.com.evil.io", want: false},
+		{pattern: "regex:docker\\.company\\.com", value: "evil.io/docker.company.com", want: false},
+		{pattern: "regex:ghcr\\.io|docker\\.company\\.com", value: "ghcr.io.evil.io", want: false},
+	}
+
+	// run tests
+	for _, test := range tests {
+		got, err := matchPattern(test.pattern, test.value)
+		if err != nil {
+			t.Errorf("matchPattern returned err: %v", err)
+		}
+
+		if got != test.want {
+			t.Errorf("matchPattern is %v for %s with %s, want %v", got, test.pattern, test.value, test.want)
+		}
+	}
+}
+
+func TestMakisu_expand(t *testing.T) {
+	// setup types
+	vars := map[string]string{
+		"GO_VERSION": "1.17",
+		"VARIANT":    "",
+	}
+
+	// setup tests
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "golang:${GO_VERSION}", want: "golang:1.17"},
+		{value: "golang:$GO_VERSION", want: "golang:1.17"},
+		{value: "golang:${VERSION:-1.18}", want: "golang:1.18"},
+		{value: "golang:${GO_VERSION:-1.18}", want: "golang:1.17"},
+		{value: "golang:1.17${GO_VERSION:+-alpine}", want: "golang:1.17-alpine"},
+		{value: "golang:1.17${VARIANT:+-alpine}", want: "golang:1.17"},
+	}
+
+	// run tests
+	for _, test := range tests {
+		got := expand(test.value, vars)
+
+		if got != test.want {
+			t.Errorf("expand is %s for %s, want %s", got, test.value, test.want)
+		}
+	}
+}
+
+func TestMakisu_Policy_Validate(t *testing.T) {
+	// setup types
+	p := &Policy{
+		AllowedRegistries: []string{"docker.company.com", "regex:^ghcr\\.io$"},
+		ForbiddenTags:     []string{"latest"},
+		RequiredTags:      []string{"v*"},
+	}
+
+	err := p.Validate()
+	if err != nil {
+		t.Errorf("Validate returned err: %v", err)
+	}
+
+	p.RequiredTags = []string{"regex:("}
+
+	err = p.Validate()
+	if err == nil {
+		t.Errorf("Validate should have returned err")
+	}
+}
+
+func TestMakisu_Policy_Verify(t *testing.T) {
+	// setup types
+	p := &Policy{
+		AllowedRegistries: []string{"docker.company.com", "*.company.com"},
+		ForbiddenTags:     []string{"latest", "dev-*"},
+		RequiredTags:      []string{"regex:^v?[0-9]+\\.[0-9]+\\.[0-9]+$", "main"},
+	}
+
+	// setup tests
+	tests := []struct {
+		build   *Build
+		failure bool
+	}{
+		{ // allowed
+			build: &Build{
+				Pushes:   []string{"docker.company.com"},
+				Replicas: []string{"registry.company.com/octocat/hello-world:main"},
+				Tag:      "octocat/hello-world:v1.0.0",
+			},
+			failure: false,
+		},
+		{ // push registry not allowed
+			build: &Build{
+				Pushes: []string{"index.docker.io"},
+				Tag:    "octocat/hello-world:v1.0.0",
+			},
+			failure: true,
+		},
+		{ // replica registry not allowed
+			build: &Build{
+				Pushes:   []string{"docker.company.com"},
+				Replicas: []string{"index.docker.io/octocat/hello-world:v1.0.0"},
+				Tag:      "octocat/hello-world:v1.0.0",
+			},
+			failure: true,
+		},
+		{ // default tag forbidden
+			build: &Build{
+				Pushes: []string{"docker.company.com"},
+				Tag:    "octocat/hello-world",
+			},
+			failure: true,
+		},
+		{ // replica tag forbidden
+			build: &Build{
+				Pushes:   []string{"docker.company.com"},
+				Replicas: []string{"docker.company.com/octocat/hello-world:dev-123"},
+				Tag:      "octocat/hello-world:v1.0.0",
+			},
+			failure: true,
+		},
+		{ // tag not matching required tags
+			build: &Build{
+				Pushes: []string{"docker.company.com"},
+				Tag:    "octocat/hello-world:feature",
+			},
+			failure: true,
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		err := p.Verify(test.build)
+
+		if test.failure && err == nil {
+			t.Errorf("Verify should have returned err for %s", test.build.Tag)
+		}
+
+		if !test.failure && err != nil {
+			t.Errorf("Verify returned err for %s: %v", test.build.Tag, err)
+		}
+	}
+}
+
+func TestMakisu_Policy_Verify_Regex(t *testing.T) {
+	// setup types
+	p := &Policy{
+		AllowedRegistries: []string{"regex:docker\\.company\\.com"},
+	}
+
+	// setup tests
+	tests := []struct {
+		build   *Build
+		failure bool
+	}{
+		{ // allowed
+			build: &Build{
+				Pushes: []string{"docker.company.com"},
+				Tag:    "octocat/hello-world:v1.0.0",
+			},
+			failure: false,
+		},
+		{ // foreign registry containing the allowed registry
+			build: &Build{
+				Pushes: []string{"docker.company.com.evil.io"},
+				Tag:    "octocat/hello-world:v1.0.0",
+			},
+			failure: true,
+		},
+		{ // foreign replica registry containing the allowed registry
+			build: &Build{
+				Pushes:   []string{"docker.company.com"},
+				Replicas: []string{"evil-docker.company.com/octocat/hello-world:v1.0.0"},
+				Tag:      "octocat/hello-world:v1.0.0",
+			},
+			failure: true,
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		err := p.Verify(test.build)
+
+		if test.failure && err == nil {
+			t.Errorf("Verify should have returned err for %s", test.build.Tag)
+		}
+
+		if !test.failure && err != nil {
+			t.Errorf("Verify returned err for %s: %v", test.build.Tag, err)
+		}
+	}
+}
+
+func TestMakisu_loadPolicies(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	_ = afero.WriteFile(appFS, defaultPolicyFile, []byte(`{"allowed_registries":["docker.company.com"]}`), 0644)
+	_ = afero.WriteFile(appFS, "policy.json", []byte(`{"forbidden_tags":["latest"]}`), 0644)
+
+	// setup types
+	want := []*Policy{
+		{AllowedRegistries: []string{"docker.company.com"}},
+		{ForbiddenTags: []string{"latest"}},
+		{RequiredTags: []string{"v*"}},
+	}
+
+	got, err := loadPolicies(`{"required_tags":["v*"]}`, "policy.json")
+	if err != nil {
+		t.Errorf("loadPolicies returned err: %v", err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("loadPolicies is %v, want %v", got, want)
+	}
+}
+
+func TestMakisu_loadPolicies_Failure(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		raw  string
+		path string
+	}{
+		{raw: "", path: "missing.json"},
+		{raw: "!@#$%^&*()", path: ""},
+	}
+
+	// run tests
+	for _, test := range tests {
+		appFS = afero.NewMemMapFs()
+
+		_, err := loadPolicies(test.raw, test.path)
+		if err == nil {
+			t.Errorf("loadPolicies should have returned err for %s%s", test.raw, test.path)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright (c) 2022 Target Brands, Inc. All rights reserved.
+//
+// Use of this source code is governed by the LICENSE file in this repository.
+
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+// progress represents the stage and step currently
+// running from the makisu logs of the build.
+type progress struct {
+	mu sync.Mutex
+
+	// stage currently running i.e. "1/2 (FROM alpine)"
+	stage string
+	// step currently running i.e. "3/5 (RUN make)"
+	step string
+}
+
+// Record captures the stage or step from the provided makisu log entry.
+func (p *progress) Record(entry *logEntry) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// check if a stage is starting
+	if m := stageMessage.FindStringSubmatch(entry.Msg); m != nil {
+		p.stage = fmt.Sprintf("%s (%s)", m[1], m[2])
+		p.step = ""
+
+		return
+	}
+
+	// check if a step is starting
+	if m := stepMessage.FindStringSubmatch(entry.Msg); m != nil {
+		p.step = fmt.Sprintf("%s (%s)", m[1], m[2])
+	}
+}
+
+// String outputs the stage and step currently running.
+func (p *progress) String() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	switch {
+	case len(p.stage) > 0 && len(p.step) > 0:
+		return fmt.Sprintf("stage %s, step %s", p.stage, p.step)
+	case len(p.stage) > 0:
+		return fmt.Sprintf("stage %s", p.stage)
+	case len(p.step) > 0:
+		return fmt.Sprintf("step %s", p.step)
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright (c) 2022 Target Brands, Inc. All rights reserved.
+//
+// Use of this source code is governed by the LICENSE file in this repository.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMakisu_progress_Record(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		output string
+		want   string
+	}{
+		{ // json logs
+			output: `{"level":"info","ts":1643040000.1,"msg":"* Stage 1/2 : FROM golang:1.17 AS builder"}
+{"level":"info","ts":1643040000.2,"msg":"* Step 3/5 (commit) : RUN go build"}
+go: downloading github.com/sirupsen/logrus v1.8.1
+`,
+			want: "stage 1/2 (FROM golang:1.17 AS builder), step 3/5 (RUN go build)",
+		},
+		{ // console logs
+			output: "2022-01-24T16:00:00.000Z\tINFO\t* Stage 2/2 : FROM alpine\n",
+			want:   "stage 2/2 (FROM alpine)",
+		},
+		{ // kaniko logs
+			output: "INFO[0001] Running: [/bin/sh -c make]\n",
+			want:   "",
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		p := new(progress)
+
+		// the progress is captured from the parsed makisu logs
+		l := newLogProcessor(new(bytes.Buffer))
+		l.Progress = p
+		l.Raw = true
+
+		_, err := l.Write([]byte(test.output))
+		if err != nil {
+			t.Errorf("Write returned err: %v", err)
+		}
+
+		if p.String() != test.want {
+			t.Errorf("Record is %s, want %s", p.String(), test.want)
+		}
+	}
+}
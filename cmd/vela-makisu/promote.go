@@ -0,0 +1,211 @@
+// Copyright (c) 2022 Target Brands, Inc. All rights reserved.
+//
+// Use of this source code is governed by the LICENSE file in this repository.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/uber/makisu/lib/docker/image"
+	"github.com/urfave/cli/v2"
+)
+
+const promoteAction = "promote"
+
+type (
+	// Promote represents the plugin configuration for copying an
+	// existing image to other references without rebuilding it.
+	Promote struct {
+		// used for translating the policy configurations
+		Policies []*Policy
+		// enables setting the image to promote i.e. "index.docker.io/octocat/hello-world:staging"
+		Source string
+		// enables setting the references to promote the image to i.e. "index.docker.io/octocat/hello-world:prod"
+		Targets []string
+	}
+
+	// promoteManifest represents the fields of a manifest, manifest
+	// list or index referencing the content copied for an image.
+	promoteManifest struct {
+		MediaType string          `json:"mediaType"`
+		Config    *ociDescriptor  `json:"config"`
+		Layers    []ociDescriptor `json:"layers"`
+		Manifests []ociDescriptor `json:"manifests"`
+	}
+)
+
+// promoteFlags represents for promote settings on the cli.
+var promoteFlags = []cli.Flag{
+	&cli.StringFlag{
+		EnvVars:  []string{"PARAMETER_SOURCE"},
+		FilePath: string("/vela/parameters/makisu/promote/source,/vela/secrets/makisu/promote/source"),
+		Name:     "promote.source",
+		Usage:    "enables setting the image to promote",
+	},
+	&cli.StringSliceFlag{
+		EnvVars:  []string{"PARAMETER_TARGETS"},
+		FilePath: string("/vela/parameters/makisu/promote/targets,/vela/secrets/makisu/promote/targets"),
+		Name:     "promote.targets",
+		Usage:    "enables setting the references to promote the image to",
+	},
+}
+
+// Exec copies the manifest and blobs for the Source
+// image to each of the Targets across registries.
+func (p *Promote) Exec(registryConfig string) error {
+	logrus.Trace("running promote with provided configuration")
+
+	src, err := newClient(registryConfig, p.Source)
+	if err != nil {
+		return err
+	}
+
+	for _, target := range p.Targets {
+		dst, err := newClient(registryConfig, target)
+		if err != nil {
+			return err
+		}
+
+		logrus.Infof("promoting image %s to %s", p.Source, target)
+
+		digest, err := copyManifest(src, dst, src.Name.GetTag(), dst.Name.GetTag())
+		if err != nil {
+			return fmt.Errorf("unable to promote image %s to %s: %w", p.Source, target, err)
+		}
+
+		logrus.Infof("promoted image %s to %s with digest %s", p.Source, target, digest)
+	}
+
+	return nil
+}
+
+// Validate verifies the Promote is properly configured.
+func (p *Promote) Validate() error {
+	logrus.Trace("validating promote plugin configuration")
+
+	// verify source is provided
+	if len(p.Source) == 0 {
+		return fmt.Errorf("no promote source provided")
+	}
+
+	// verify targets are provided
+	if len(p.Targets) == 0 {
+		return fmt.Errorf("no promote targets provided")
+	}
+
+	for _, reference := range append([]string{p.Source}, p.Targets...) {
+		name, err := image.ParseNameForPull(reference)
+		if err != nil || !name.IsValid() {
+			return fmt.Errorf("invalid image reference provided: %s", reference)
+		}
+	}
+
+	for _, target := range p.Targets {
+		// verify the target is a tag
+		if strings.Contains(target, "@") {
+			return fmt.Errorf("invalid promote target provided: %s (targets must be tags)", target)
+		}
+	}
+
+	for _, policy := range p.Policies {
+		// verify policy is valid
+		err := policy.Validate()
+		if err != nil {
+			return err
+		}
+
+		// verify the registries and tags of the targets
+		err = policy.Verify(&Build{Replicas: p.Targets, Tag: p.Targets[0]})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// copyManifest is a helper function to copy the manifest with the provided
+// reference and the content it references from the source repository to the
+// destination repository with the provided reference and output the digest.
+func copyManifest(src, dst *Client, srcRef, dstRef string) (image.Digest, error) {
+	data, mediaType, err := src.RawManifest(srcRef)
+	if err != nil {
+		return "", err
+	}
+
+	manifest := new(promoteManifest)
+
+	err = json.Unmarshal(data, manifest)
+	if err != nil {
+		return "", fmt.Errorf("invalid manifest %s for %s: %w", srcRef, src.Name, err)
+	}
+
+	// fallback to the media type within the manifest
+	if len(mediaType) == 0 {
+		mediaType = manifest.MediaType
+	}
+
+	// copy the manifests for each platform of a manifest list or index
+	for _, m := range manifest.Manifests {
+		_, err = copyManifest(src, dst, m.Digest, m.Digest)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	// variable to store the blobs for the manifest
+	var blobs []ociDescriptor
+
+	// check if the manifest references a configuration
+	if manifest.Config != nil {
+		blobs = append(blobs, *manifest.Config)
+	}
+
+	for _, blob := range append(blobs, manifest.Layers...) {
+		// skip foreign layers which are not stored in the registry
+		if strings.Contains(blob.MediaType, "foreign") {
+			continue
+		}
+
+		err = copyBlob(src, dst, image.Digest(blob.Digest), blob.Size)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	// push the unmodified manifest to keep the digest
+	err = dst.PushManifest(dstRef, mediaType, data)
+	if err != nil {
+		return "", err
+	}
+
+	return digestOf(data), nil
+}
+
+// copyBlob is a helper function to copy the blob from the source
+// repository to the destination repository if it does not exist.
+func copyBlob(src, dst *Client, digest image.Digest, size int64) error {
+	exists, err := dst.BlobExists(digest)
+	if err != nil {
+		return err
+	}
+
+	// skip copying blobs which already exist
+	if exists {
+		logrus.Debugf("skipped copying existing blob %s to %s", digest, dst.Name)
+
+		return nil
+	}
+
+	r, err := src.Blob(digest)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	return dst.PushBlob(digest, size, r)
+}
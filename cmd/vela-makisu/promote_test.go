@@ -0,0 +1,201 @@
+// Copyright (c) 2022 Target Brands, Inc. All rights reserved.
+//
+// Use of this source code is governed by the LICENSE file in this repository.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestMakisu_Promote_Validate(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		promote *Promote
+		failure bool
+	}{
+		{
+			promote: &Promote{
+				Source:  "index.docker.io/octocat/hello-world:staging",
+				Targets: []string{"index.docker.io/octocat/hello-world:prod"},
+			},
+			failure: false,
+		},
+		{ // source pinned by digest
+			promote: &Promote{
+				Source:  "index.docker.io/octocat/hello-world@" + testManifestDigest,
+				Targets: []string{"index.docker.io/octocat/hello-world:prod"},
+			},
+			failure: false,
+		},
+		{ // missing source
+			promote: &Promote{
+				Targets: []string{"index.docker.io/octocat/hello-world:prod"},
+			},
+			failure: true,
+		},
+		{ // missing targets
+			promote: &Promote{
+				Source: "index.docker.io/octocat/hello-world:staging",
+			},
+			failure: true,
+		},
+		{ // target pinned by digest
+			promote: &Promote{
+				Source:  "index.docker.io/octocat/hello-world:staging",
+				Targets: []string{"index.docker.io/octocat/hello-world@" + testManifestDigest},
+			},
+			failure: true,
+		},
+		{ // target denied by policy
+			promote: &Promote{
+				Policies: []*Policy{{AllowedRegistries: []string{"registry.company.com"}}},
+				Source:   "index.docker.io/octocat/hello-world:staging",
+				Targets:  []string{"index.docker.io/octocat/hello-world:prod"},
+			},
+			failure: true,
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		err := test.promote.Validate()
+
+		if test.failure && err == nil {
+			t.Errorf("Validate should have returned err")
+		}
+
+		if !test.failure && err != nil {
+			t.Errorf("Validate returned err: %v", err)
+		}
+	}
+}
+
+func TestMakisu_Promote_Exec(t *testing.T) {
+	// setup types
+	src, srcStore := testPushRegistry(t)
+	dst, dstStore := testPushRegistry(t)
+
+	source := fmt.Sprintf("%s/octocat/hello-world:staging", strings.TrimPrefix(src.URL, "http://"))
+
+	c, err := newClient("", source)
+	if err != nil {
+		t.Errorf("newClient returned err: %v", err)
+	}
+
+	// push an artifact to use as the image for the source
+	err = c.PushArtifact("staging", []*artifactLayer{
+		{Data: []byte("octocat"), MediaType: "text/plain"},
+		{Data: []byte("127.0.0.1 localhost"), MediaType: "text/plain"},
+	})
+	if err != nil {
+		t.Errorf("PushArtifact returned err: %v", err)
+	}
+
+	p := &Promote{
+		Source: source,
+		Targets: []string{
+			fmt.Sprintf("%s/octocat/hello-world:prod", strings.TrimPrefix(dst.URL, "http://")),
+			fmt.Sprintf("%s/octocat/hello-world:1.0.0", strings.TrimPrefix(dst.URL, "http://")),
+		},
+	}
+
+	err = p.Exec("")
+	if err != nil {
+		t.Errorf("Exec returned err: %v", err)
+	}
+
+	want := srcStore.manifests["octocat/hello-world:staging"]
+
+	for _, tag := range []string{"prod", "1.0.0"} {
+		got := dstStore.manifests["octocat/hello-world:"+tag]
+
+		if !bytes.Equal(got, want) {
+			t.Errorf("Exec manifest %s is %s, want %s", tag, got, want)
+		}
+
+		if dstStore.types["octocat/hello-world:"+tag] != srcStore.types["octocat/hello-world:staging"] {
+			t.Errorf("Exec media type %s is %s", tag, dstStore.types["octocat/hello-world:"+tag])
+		}
+	}
+
+	for digest, blob := range srcStore.blobs {
+		if !bytes.Equal(dstStore.blobs[digest], blob) {
+			t.Errorf("Exec should have copied blob %s", digest)
+		}
+	}
+}
+
+func TestMakisu_Promote_Exec_ManifestList(t *testing.T) {
+	// setup types
+	src, srcStore := testPushRegistry(t)
+	dst, dstStore := testPushRegistry(t)
+
+	source := fmt.Sprintf("%s/octocat/hello-world:staging", strings.TrimPrefix(src.URL, "http://"))
+
+	c, err := newClient("", source)
+	if err != nil {
+		t.Errorf("newClient returned err: %v", err)
+	}
+
+	// push an artifact to use as the manifest for a platform
+	err = c.PushArtifact("amd64", []*artifactLayer{{Data: []byte("hello world"), MediaType: "text/plain"}})
+	if err != nil {
+		t.Errorf("PushArtifact returned err: %v", err)
+	}
+
+	platform := srcStore.manifests["octocat/hello-world:amd64"]
+
+	index, _ := json.Marshal(&promoteManifest{
+		MediaType: ociIndexMediaType,
+		Manifests: []ociDescriptor{descriptor(ociManifestMediaType, platform)},
+	})
+
+	err = c.PushManifest("staging", ociIndexMediaType, index)
+	if err != nil {
+		t.Errorf("PushManifest returned err: %v", err)
+	}
+
+	p := &Promote{
+		Source:  source,
+		Targets: []string{fmt.Sprintf("%s/octocat/hello-world:prod", strings.TrimPrefix(dst.URL, "http://"))},
+	}
+
+	err = p.Exec("")
+	if err != nil {
+		t.Errorf("Exec returned err: %v", err)
+	}
+
+	if got := dstStore.manifests["octocat/hello-world:prod"]; !bytes.Equal(got, index) {
+		t.Errorf("Exec index is %s, want %s", got, index)
+	}
+
+	if got := dstStore.manifests["octocat/hello-world:"+string(digestOf(platform))]; !bytes.Equal(got, platform) {
+		t.Errorf("Exec platform manifest is %s, want %s", got, platform)
+	}
+
+	if _, ok := dstStore.blobs[string(digestOf([]byte("hello world")))]; !ok {
+		t.Errorf("Exec should have copied the layers of the platform manifest")
+	}
+}
+
+func TestMakisu_Promote_Exec_MissingSource(t *testing.T) {
+	// setup types
+	s, _ := testPushRegistry(t)
+
+	registry := strings.TrimPrefix(s.URL, "http://")
+
+	p := &Promote{
+		Source:  fmt.Sprintf("%s/octocat/hello-world:staging", registry),
+		Targets: []string{fmt.Sprintf("%s/octocat/hello-world:prod", registry)},
+	}
+
+	err := p.Exec("")
+	if err == nil {
+		t.Errorf("Exec should have returned err")
+	}
+}
@@ -0,0 +1,389 @@
+// Copyright (c) 2022 Target Brands, Inc. All rights reserved.
+//
+// Use of this source code is governed by the LICENSE file in this repository.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-vela/vela-makisu/version"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+	"github.com/uber/makisu/lib/docker/image"
+)
+
+const (
+	// inTotoMediaType represents the media type for an in-toto statement.
+	inTotoMediaType = "application/vnd.in-toto+json"
+
+	// inTotoStatementType represents the type for an in-toto statement.
+	inTotoStatementType = "https://in-toto.io/Statement/v0.1"
+
+	// provenanceBuildType represents the type of build recorded in the provenance.
+	provenanceBuildType = "https://github.com/go-vela/vela-makisu/build@v1"
+
+	// slsaProvenanceType represents the predicate type for SLSA provenance.
+	slsaProvenanceType = "https://slsa.dev/provenance/v0.2"
+)
+
+// provenanceEnvironment represents the Vela environment
+// variables recorded in the provenance for the build.
+var provenanceEnvironment = []string{
+	"VELA_BUILD_BRANCH",
+	"VELA_BUILD_EVENT",
+	"VELA_BUILD_LINK",
+	"VELA_BUILD_NUMBER",
+	"VELA_BUILD_REF",
+	"VELA_REPO_FULL_NAME",
+}
+
+type (
+	// Provenance represents an in-toto statement with the
+	// SLSA provenance for the images produced by the build.
+	//
+	// https://slsa.dev/provenance/v0.2
+	Provenance struct {
+		Type          string               `json:"_type"`
+		PredicateType string               `json:"predicateType"`
+		Subject       []*ProvenanceSubject `json:"subject"`
+		Predicate     *ProvenancePredicate `json:"predicate"`
+	}
+
+	// ProvenanceSubject represents an image produced by the build.
+	ProvenanceSubject struct {
+		Name   string            `json:"name"`
+		Digest map[string]string `json:"digest"`
+	}
+
+	// ProvenancePredicate represents how the images were produced by the build.
+	ProvenancePredicate struct {
+		Builder    ProvenanceBuilder     `json:"builder"`
+		BuildType  string                `json:"buildType"`
+		Invocation ProvenanceInvocation  `json:"invocation"`
+		Metadata   ProvenanceMetadata    `json:"metadata"`
+		Materials  []*ProvenanceMaterial `json:"materials"`
+	}
+
+	// ProvenanceBuilder represents the plugin producing the images.
+	ProvenanceBuilder struct {
+		ID string `json:"id"`
+	}
+
+	// ProvenanceInvocation represents the configuration for the build.
+	ProvenanceInvocation struct {
+		ConfigSource ProvenanceMaterial     `json:"configSource"`
+		Parameters   map[string]interface{} `json:"parameters"`
+		Environment  map[string]string      `json:"environment"`
+	}
+
+	// ProvenanceMetadata represents the timing for the build.
+	ProvenanceMetadata struct {
+		BuildInvocationID string `json:"buildInvocationId,omitempty"`
+		BuildStartedOn    string `json:"buildStartedOn"`
+		BuildFinishedOn   string `json:"buildFinishedOn"`
+		Reproducible      bool   `json:"reproducible"`
+	}
+
+	// ProvenanceMaterial represents the source or an image used by the build.
+	ProvenanceMaterial struct {
+		URI    string            `json:"uri"`
+		Digest map[string]string `json:"digest,omitempty"`
+	}
+)
+
+// Provenance creates the provenance for the images
+// produced by the build from the provided results.
+func (b *Build) Provenance(results *Results, builderVersion string, started, finished time.Time) (*Provenance, error) {
+	logrus.Trace("creating provenance for the build")
+
+	subjects, err := b.subjects(results)
+	if err != nil {
+		return nil, err
+	}
+
+	parameters, err := b.parameters()
+	if err != nil {
+		return nil, err
+	}
+
+	// capture the environment for the build
+	environment := map[string]string{
+		"builder":         b.Builder,
+		"builder_version": builderVersion,
+	}
+
+	for _, name := range provenanceEnvironment {
+		if value := os.Getenv(name); len(value) > 0 {
+			environment[name] = value
+		}
+	}
+
+	source := b.source()
+
+	return &Provenance{
+		Type:          inTotoStatementType,
+		PredicateType: slsaProvenanceType,
+		Subject:       subjects,
+		Predicate: &ProvenancePredicate{
+			Builder: ProvenanceBuilder{
+				ID: fmt.Sprintf("https://github.com/go-vela/vela-makisu@%s", version.New().Semantic()),
+			},
+			BuildType: provenanceBuildType,
+			Invocation: ProvenanceInvocation{
+				ConfigSource: *source,
+				Parameters:   parameters,
+				Environment:  environment,
+			},
+			Metadata: ProvenanceMetadata{
+				BuildInvocationID: os.Getenv("VELA_BUILD_LINK"),
+				BuildStartedOn:    started.UTC().Format(time.RFC3339),
+				BuildFinishedOn:   finished.UTC().Format(time.RFC3339),
+				Reproducible:      false,
+			},
+			Materials: append([]*ProvenanceMaterial{source}, b.materials...),
+		},
+	}, nil
+}
+
+// Write outputs the Provenance to the provided path.
+func (p *Provenance) Write(path string) error {
+	logrus.Tracef("writing provenance to %s", path)
+
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// use custom filesystem which enables us to test
+	a := &afero.Afero{
+		Fs: appFS,
+	}
+
+	// create the directory for the provenance
+	err = a.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("writing provenance to %s", path)
+
+	return a.WriteFile(path, data, 0644)
+}
+
+// Push uploads the Provenance alongside each image in the
+// provided results with the tag "sha256-<digest>.att".
+func (p *Provenance) Push(registryConfig string, results *Results) error {
+	logrus.Trace("pushing provenance for the build")
+
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	for _, result := range results.Images {
+		client, err := newClient(registryConfig, result.Reference)
+		if err != nil {
+			return err
+		}
+
+		tag := artifactTag(result.Digest, "att")
+
+		logrus.Infof("pushing provenance for %s to %s/%s:%s",
+			result.Reference, client.Name.GetRegistry(), client.Name.GetRepository(), tag)
+
+		err = client.PushArtifact(tag, []*artifactLayer{
+			{
+				Annotations: map[string]string{"predicateType": slsaProvenanceType},
+				Data:        data,
+				MediaType:   inTotoMediaType,
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// subjects is a helper function to return the images
+// produced by the build from the provided results.
+func (b *Build) subjects(results *Results) ([]*ProvenanceSubject, error) {
+	// variable to store the subjects for the build
+	var subjects []*ProvenanceSubject
+
+	for _, result := range results.Images {
+		name, _ := splitTag(result.Reference)
+
+		subjects = append(subjects, &ProvenanceSubject{
+			Name:   name,
+			Digest: map[string]string{"sha256": image.Digest(result.Digest).Hex()},
+		})
+	}
+
+	// check if the image was written to a tarball
+	if len(subjects) == 0 && len(b.Destination) > 0 {
+		// variable to store the file identifying the image
+		path := b.OutputPath()
+
+		// the index identifies the image within a layout
+		if b.OutputFormat == ociLayoutFormat {
+			path = filepath.Join(path, "index.json")
+		}
+
+		f, err := appFS.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		h := sha256.New()
+
+		_, err = io.Copy(h, f)
+		if err != nil {
+			return nil, err
+		}
+
+		subjects = append(subjects, &ProvenanceSubject{
+			Name:   filepath.Base(b.OutputPath()),
+			Digest: map[string]string{"sha256": fmt.Sprintf("%x", h.Sum(nil))},
+		})
+	}
+
+	return subjects, nil
+}
+
+// parameters is a helper function to return the
+// configuration for the build with secrets redacted.
+func (b *Build) parameters() (map[string]interface{}, error) {
+	data, err := json.Marshal(b)
+	if err != nil {
+		return nil, err
+	}
+
+	// redact the sensitive values for the build
+	redactedData := redact(string(data))
+
+	for _, secret := range b.Secrets() {
+		// marshal the secret to match the escaped value
+		escaped, _ := json.Marshal(strings.TrimSpace(secret))
+
+		redactedData = strings.ReplaceAll(redactedData, strings.Trim(string(escaped), `"`), redacted)
+	}
+
+	// variable to store the parameters for the build
+	parameters := make(map[string]interface{})
+
+	err = json.Unmarshal([]byte(redactedData), &parameters)
+	if err != nil {
+		return nil, err
+	}
+
+	return parameters, nil
+}
+
+// source is a helper function to return the
+// repository and commit the build was run for.
+func (b *Build) source() *ProvenanceMaterial {
+	// capture the repository for the build
+	uri := os.Getenv("VELA_REPO_CLONE")
+	if len(uri) == 0 {
+		uri = os.Getenv("VELA_REPO_LINK")
+	}
+
+	// check if the repository is known
+	if len(uri) > 0 {
+		uri = "git+" + uri
+	}
+
+	source := &ProvenanceMaterial{
+		URI: uri,
+	}
+
+	// check if the commit is known
+	if commit := os.Getenv("VELA_BUILD_COMMIT"); len(commit) > 0 {
+		source.Digest = map[string]string{"sha1": commit}
+	}
+
+	return source
+}
+
+// resolveMaterials is a helper function to return the base
+// images the Dockerfile derives from with their digest.
+func (b *Build) resolveMaterials() []*ProvenanceMaterial {
+	// variable to store the materials for the build
+	var materials []*ProvenanceMaterial
+
+	d, err := parseDockerfile(b.Dockerfile())
+	if err != nil {
+		logrus.Warnf("unable to capture base images for provenance: %v", err)
+
+		return materials
+	}
+
+	for _, base := range b.baseImages(d) {
+		// skip images without a base image
+		if strings.EqualFold(base.Ref, "scratch") {
+			continue
+		}
+
+		material := &ProvenanceMaterial{
+			URI: fmt.Sprintf("pkg:docker/%s", base.Ref),
+		}
+
+		// capture the digest for the image
+		digest, err := b.imageDigest(base.Ref)
+		if err != nil {
+			logrus.Warnf("unable to capture digest for base image %s: %v", base.Ref, err)
+		} else {
+			material.Digest = map[string]string{"sha256": digest.Hex()}
+		}
+
+		materials = append(materials, material)
+	}
+
+	return materials
+}
+
+// imageDigest is a helper function to return the
+// digest of the manifest for the provided image.
+func (b *Build) imageDigest(ref string) (image.Digest, error) {
+	// check if the image is pinned to a digest
+	if _, digest, ok := cut(ref, "@"); ok {
+		return image.Digest(digest), nil
+	}
+
+	client, err := newClient(b.RegistryConfig, ref)
+	if err != nil {
+		return "", err
+	}
+
+	_, descriptor, err := client.Manifest()
+	if err != nil {
+		return "", err
+	}
+
+	return descriptor.Digest, nil
+}
+
+// builderVersion is a helper function to
+// capture the version of the backend.
+func builderVersion(builder Builder) string {
+	// capture the output of the version command
+	output, err := builder.Version().Output()
+	if err != nil {
+		logrus.Warnf("unable to capture builder version: %v", err)
+
+		return "unknown"
+	}
+
+	return strings.TrimSpace(string(output))
+}
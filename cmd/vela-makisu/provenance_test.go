@@ -0,0 +1,277 @@
+// Copyright (c) 2022 Target Brands, Inc. All rights reserved.
+//
+// Use of this source code is governed by the LICENSE file in this repository.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+func TestMakisu_Build_Provenance(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	_ = afero.WriteFile(appFS, "Dockerfile", []byte(
+		"FROM alpine@sha256:21a3deaa0d32a8057914f36584b5288d2e5ecc984380bc0118285c70fa8c9300 AS builder\n"+
+			"FROM scratch\nCOPY --from=builder /bin/sh /bin/sh\n",
+	), 0644)
+
+	// setup environment
+	os.Setenv("VELA_REPO_CLONE", "https://github.com/octocat/hello-world.git")
+	os.Setenv("VELA_BUILD_COMMIT", "7fd1a60b01f91b314f59955a4e4d4e80d8edf11d")
+	os.Setenv("VELA_BUILD_NUMBER", "1")
+
+	defer func() {
+		os.Unsetenv("VELA_REPO_CLONE")
+		os.Unsetenv("VELA_BUILD_COMMIT")
+		os.Unsetenv("VELA_BUILD_NUMBER")
+	}()
+
+	// setup types
+	b := &Build{
+		Builder:    makisuBuilder,
+		Context:    ".",
+		RedisCache: &RedisCache{Addr: "redis.company.com", Password: "superSecretPassword"},
+		Tag:        "octocat/hello-world:latest",
+	}
+
+	results := &Results{
+		Images: []*ImageResult{
+			{
+				Digest:    "sha256:0000000000000000000000000000000000000000000000000000000000000000",
+				Reference: "index.docker.io/octocat/hello-world:latest",
+			},
+		},
+	}
+
+	started := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// resolve the base images before the build
+	b.materials = b.resolveMaterials()
+
+	got, err := b.Provenance(results, "makisu version v0.4.2", started, started.Add(time.Minute))
+	if err != nil {
+		t.Errorf("Provenance returned err: %v", err)
+	}
+
+	wantSubject := []*ProvenanceSubject{
+		{
+			Name:   "index.docker.io/octocat/hello-world",
+			Digest: map[string]string{"sha256": "0000000000000000000000000000000000000000000000000000000000000000"},
+		},
+	}
+
+	if !reflect.DeepEqual(got.Subject, wantSubject) {
+		t.Errorf("Provenance subject is %v, want %v", got.Subject, wantSubject)
+	}
+
+	wantMaterials := []*ProvenanceMaterial{
+		{
+			URI:    "git+https://github.com/octocat/hello-world.git",
+			Digest: map[string]string{"sha1": "7fd1a60b01f91b314f59955a4e4d4e80d8edf11d"},
+		},
+		{
+			URI:    "pkg:docker/alpine@sha256:21a3deaa0d32a8057914f36584b5288d2e5ecc984380bc0118285c70fa8c9300",
+			Digest: map[string]string{"sha256": "21a3deaa0d32a8057914f36584b5288d2e5ecc984380bc0118285c70fa8c9300"},
+		},
+	}
+
+	if !reflect.DeepEqual(got.Predicate.Materials, wantMaterials) {
+		t.Errorf("Provenance materials is %v, want %v", got.Predicate.Materials, wantMaterials)
+	}
+
+	if got.Predicate.Invocation.Environment["builder_version"] != "makisu version v0.4.2" ||
+		got.Predicate.Invocation.Environment["VELA_BUILD_NUMBER"] != "1" {
+		t.Errorf("Provenance environment is %v", got.Predicate.Invocation.Environment)
+	}
+
+	if got.Predicate.Metadata.BuildFinishedOn != "2022-01-01T00:01:00Z" {
+		t.Errorf("Provenance finished is %s", got.Predicate.Metadata.BuildFinishedOn)
+	}
+
+	data, _ := json.Marshal(got)
+
+	if strings.Contains(string(data), "superSecretPassword") {
+		t.Errorf("Provenance should have redacted secrets: %s", data)
+	}
+
+	if !strings.Contains(string(data), "redis.company.com") {
+		t.Errorf("Provenance should have included parameters: %s", data)
+	}
+}
+
+// testMaterialsBuilder represents a builder
+// changing the base image while building.
+type testMaterialsBuilder struct {
+	testBuilder
+}
+
+func (t *testMaterialsBuilder) Exec(*Build) error {
+	return afero.WriteFile(appFS, "Dockerfile", []byte(
+		"FROM alpine@sha256:1111111111111111111111111111111111111111111111111111111111111111\n",
+	), 0644)
+}
+
+func TestMakisu_Build_Exec_Materials(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	_ = afero.WriteFile(appFS, "Dockerfile", []byte(
+		"FROM alpine@sha256:21a3deaa0d32a8057914f36584b5288d2e5ecc984380bc0118285c70fa8c9300\n",
+	), 0644)
+
+	// setup types
+	builders["test"] = new(testMaterialsBuilder)
+	defer delete(builders, "test")
+
+	b := &Build{
+		Builder:        "test",
+		Context:        ".",
+		ProvenancePath: "provenance.json",
+		Tag:            "octocat/hello-world:latest",
+	}
+
+	err := b.Exec()
+	if err != nil {
+		t.Errorf("Exec returned err: %v", err)
+	}
+
+	data, err := afero.ReadFile(appFS, "provenance.json")
+	if err != nil {
+		t.Errorf("ReadFile returned err: %v", err)
+	}
+
+	// the base images are resolved before the build
+	if !strings.Contains(string(data), "21a3deaa0d32a8057914f36584b5288d2e5ecc984380bc0118285c70fa8c9300") {
+		t.Errorf("Exec provenance is %s, want the base image resolved before the build", data)
+	}
+}
+
+func TestMakisu_Build_Provenance_Destination(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	_ = afero.WriteFile(appFS, "image.tar", []byte("hello world"), 0644)
+
+	// setup types
+	b := &Build{
+		Context:     ".",
+		Destination: "image.tar",
+	}
+
+	want := []*ProvenanceSubject{
+		{
+			Name:   "image.tar",
+			Digest: map[string]string{"sha256": "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"},
+		},
+	}
+
+	got, err := b.Provenance(&Results{}, "", time.Now(), time.Now())
+	if err != nil {
+		t.Errorf("Provenance returned err: %v", err)
+	}
+
+	if !reflect.DeepEqual(got.Subject, want) {
+		t.Errorf("Provenance subject is %v, want %v", got.Subject, want)
+	}
+}
+
+func TestMakisu_Build_Provenance_Layout(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	_ = afero.WriteFile(appFS, "image/index.json", []byte("hello world"), 0644)
+
+	// setup types
+	b := &Build{
+		Context:      ".",
+		Destination:  "image.tar",
+		OutputFormat: ociLayoutFormat,
+	}
+
+	want := []*ProvenanceSubject{
+		{
+			Name:   "image",
+			Digest: map[string]string{"sha256": "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"},
+		},
+	}
+
+	got, err := b.Provenance(&Results{}, "", time.Now(), time.Now())
+	if err != nil {
+		t.Errorf("Provenance returned err: %v", err)
+	}
+
+	if !reflect.DeepEqual(got.Subject, want) {
+		t.Errorf("Provenance subject is %v, want %v", got.Subject, want)
+	}
+}
+
+func TestMakisu_Provenance_Write(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	// setup types
+	p := &Provenance{
+		Type:          inTotoStatementType,
+		PredicateType: slsaProvenanceType,
+	}
+
+	err := p.Write("attestations/provenance.json")
+	if err != nil {
+		t.Errorf("Write returned err: %v", err)
+	}
+
+	data, err := afero.ReadFile(appFS, "attestations/provenance.json")
+	if err != nil {
+		t.Errorf("ReadFile returned err: %v", err)
+	}
+
+	if !strings.Contains(string(data), slsaProvenanceType) {
+		t.Errorf("Write is %s, want %s", data, slsaProvenanceType)
+	}
+}
+
+func TestMakisu_Provenance_Push(t *testing.T) {
+	// setup types
+	s, store := testPushRegistry(t)
+
+	p := &Provenance{
+		Type:          inTotoStatementType,
+		PredicateType: slsaProvenanceType,
+	}
+
+	results := &Results{
+		Images: []*ImageResult{
+			{
+				Digest:    "sha256:abc",
+				Reference: fmt.Sprintf("%s/octocat/hello-world:latest", strings.TrimPrefix(s.URL, "http://")),
+			},
+		},
+	}
+
+	err := p.Push("", results)
+	if err != nil {
+		t.Errorf("Push returned err: %v", err)
+	}
+
+	if _, ok := store.manifests["octocat/hello-world:sha256-abc.att"]; !ok {
+		t.Errorf("Push should have pushed the provenance with tag sha256-abc.att")
+	}
+}
+
+func TestMakisu_builderVersion(t *testing.T) {
+	got := builderVersion(&testBuilder{})
+
+	if got != "" {
+		t.Errorf("builderVersion is %s, want empty output", got)
+	}
+}
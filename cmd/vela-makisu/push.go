@@ -0,0 +1,106 @@
+// Copyright (c) 2022 Target Brands, Inc. All rights reserved.
+//
+// Use of this source code is governed by the LICENSE file in this repository.
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/sirupsen/logrus"
+	"github.com/uber/makisu/lib/docker/image"
+)
+
+const pushTarballAction = "push_tarball"
+
+// PushTarball pushes the image in the tarball written to the
+// Destination by a prior build with makisu without rebuilding it.
+func (b *Build) PushTarball() error {
+	logrus.Trace("running push tarball with provided configuration")
+
+	tarball, err := openTarball(b.Destination)
+	if err != nil {
+		return err
+	}
+
+	// verify the tarball was not modified since the build
+	err = tarball.Validate()
+	if err != nil {
+		return err
+	}
+
+	return b.push(b.Destination)
+}
+
+// TarballReferences outputs the references
+// the image in the tarball is pushed to.
+func (b *Build) TarballReferences() []string {
+	// check if the image is pushed to any registries
+	references := b.References()
+	if len(references) > 0 {
+		return references
+	}
+
+	return append([]string{b.Tag}, b.Replicas...)
+}
+
+// ValidateTarball verifies the Build is properly
+// configured for pushing the image in the tarball.
+func (b *Build) ValidateTarball() error {
+	logrus.Trace("validating push tarball plugin configuration")
+
+	// verify destination is provided
+	if len(b.Destination) == 0 {
+		return fmt.Errorf("no build destination provided")
+	}
+
+	// verify tag is provided
+	if len(b.Tag) == 0 {
+		return fmt.Errorf("no build tag provided")
+	}
+
+	// verify the tarball is pushed with makisu
+	if b.Builder == kanikoBuilder {
+		return fmt.Errorf("%s requires the makisu builder", pushTarballAction)
+	}
+
+	// verify the tarball was written by a prior step
+	_, err := appFS.Stat(b.Destination)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("image tarball %s not found", b.Destination)
+		}
+
+		return err
+	}
+
+	for _, reference := range b.TarballReferences() {
+		name, err := image.ParseNameForPull(reference)
+		if err != nil || !name.IsValid() {
+			return fmt.Errorf("invalid image reference provided: %s", reference)
+		}
+	}
+
+	// verify the policies for every reference the image is pushed to
+	target := &Build{
+		Replicas: b.TarballReferences(),
+		Tag:      b.Tag,
+	}
+
+	for _, policy := range b.Policies {
+		// verify policy is valid
+		err = policy.Validate()
+		if err != nil {
+			return err
+		}
+
+		// verify the registries and tags for the image
+		err = policy.Verify(target)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,241 @@
+// Copyright (c) 2022 Target Brands, Inc. All rights reserved.
+//
+// Use of this source code is governed by the LICENSE file in this repository.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+func TestMakisu_Tarball_Validate(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	testTarball(t, "image.tar",
+		[]testFile{{Name: "etc/hostname", Content: "octocat"}},
+		[]testFile{{Name: "etc/hosts", Content: "127.0.0.1 localhost"}},
+	)
+
+	// setup tests
+	tests := []struct {
+		config  string
+		failure bool
+	}{
+		{ // configuration from the tarball
+			config:  "",
+			failure: false,
+		},
+		{ // modified layer
+			config:  `{"rootfs":{"type":"layers","diff_ids":["sha256:0000000000000000000000000000000000000000000000000000000000000000","sha256:1111111111111111111111111111111111111111111111111111111111111111"]}}`,
+			failure: true,
+		},
+		{ // missing layer
+			config:  `{"rootfs":{"type":"layers","diff_ids":[]}}`,
+			failure: true,
+		},
+		{ // missing rootfs
+			config:  `{"architecture":"amd64","os":"linux"}`,
+			failure: true,
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		tarball, err := openTarball("image.tar")
+		if err != nil {
+			t.Errorf("openTarball returned err: %v", err)
+		}
+
+		if len(test.config) > 0 {
+			tarball.Config = []byte(test.config)
+		}
+
+		err = tarball.Validate()
+
+		if test.failure && err == nil {
+			t.Errorf("Validate should have returned err")
+		}
+
+		if !test.failure && err != nil {
+			t.Errorf("Validate returned err: %v", err)
+		}
+	}
+}
+
+func TestMakisu_Build_PushTarball(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	testTarball(t, "image.tar", []testFile{{Name: "etc/hostname", Content: "octocat"}})
+
+	// setup types
+	builder := new(testBuilder)
+
+	builders["test"] = builder
+	defer delete(builders, "test")
+
+	b := &Build{
+		Builder:     "test",
+		Destination: "image.tar",
+		Replicas:    []string{"docker.company.com/octocat/hello-world:1.0.0"},
+		Tag:         "index.docker.io/octocat/hello-world:latest",
+	}
+
+	err := b.PushTarball()
+	if err != nil {
+		t.Errorf("PushTarball returned err: %v", err)
+	}
+
+	if !reflect.DeepEqual(builder.pushes, []string{"image.tar"}) {
+		t.Errorf("PushTarball pushed %v, want image.tar", builder.pushes)
+	}
+}
+
+func TestMakisu_Build_PushTarball_Modified(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	_ = afero.WriteFile(appFS, "image.tar", []byte("hello world"), 0644)
+
+	// setup types
+	builder := new(testBuilder)
+
+	builders["test"] = builder
+	defer delete(builders, "test")
+
+	b := &Build{
+		Builder:     "test",
+		Destination: "image.tar",
+		Tag:         "index.docker.io/octocat/hello-world:latest",
+	}
+
+	err := b.PushTarball()
+	if err == nil {
+		t.Errorf("PushTarball should have returned err")
+	}
+
+	if len(builder.pushes) > 0 {
+		t.Errorf("PushTarball should not have pushed the image")
+	}
+}
+
+func TestMakisu_Build_TarballReferences(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		build *Build
+		want  []string
+	}{
+		{
+			build: &Build{
+				Replicas: []string{"docker.company.com/octocat/hello-world:latest"},
+				Tag:      "index.docker.io/octocat/hello-world:latest",
+			},
+			want: []string{
+				"index.docker.io/octocat/hello-world:latest",
+				"docker.company.com/octocat/hello-world:latest",
+			},
+		},
+		{
+			build: &Build{
+				Pushes: []string{"index.docker.io"},
+				Tag:    "octocat/hello-world:latest",
+			},
+			want: []string{"index.docker.io/octocat/hello-world:latest"},
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		got := test.build.TarballReferences()
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("TarballReferences is %v, want %v", got, test.want)
+		}
+	}
+}
+
+func TestMakisu_Build_ValidateTarball(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	_ = afero.WriteFile(appFS, "image.tar", []byte{}, 0644)
+
+	// setup tests
+	tests := []struct {
+		build   *Build
+		failure bool
+	}{
+		{
+			build:   &Build{Destination: "image.tar", Tag: "index.docker.io/octocat/hello-world:latest"},
+			failure: false,
+		},
+		{ // missing destination
+			build:   &Build{Tag: "index.docker.io/octocat/hello-world:latest"},
+			failure: true,
+		},
+		{ // missing tarball
+			build:   &Build{Destination: "missing.tar", Tag: "index.docker.io/octocat/hello-world:latest"},
+			failure: true,
+		},
+		{ // missing tag
+			build:   &Build{Destination: "image.tar"},
+			failure: true,
+		},
+		{ // kaniko builder
+			build:   &Build{Builder: kanikoBuilder, Destination: "image.tar", Tag: "index.docker.io/octocat/hello-world:latest"},
+			failure: true,
+		},
+		{ // registry denied by policy
+			build: &Build{
+				Destination: "image.tar",
+				Policies:    []*Policy{{AllowedRegistries: []string{"docker.company.com"}}},
+				Pushes:      []string{"index.docker.io"},
+				Tag:         "octocat/hello-world:latest",
+			},
+			failure: true,
+		},
+		{ // tag registry denied by policy
+			build: &Build{
+				Destination: "image.tar",
+				Policies:    []*Policy{{AllowedRegistries: []string{"docker.company.com"}}},
+				Tag:         "public.evil.io/app:1",
+			},
+			failure: true,
+		},
+		{ // replica registry denied by policy
+			build: &Build{
+				Destination: "image.tar",
+				Policies:    []*Policy{{AllowedRegistries: []string{"docker.company.com"}}},
+				Replicas:    []string{"public.evil.io/app:1"},
+				Tag:         "docker.company.com/app:1",
+			},
+			failure: true,
+		},
+		{ // registries allowed by policy
+			build: &Build{
+				Destination: "image.tar",
+				Policies:    []*Policy{{AllowedRegistries: []string{"docker.company.com"}}},
+				Pushes:      []string{"docker.company.com"},
+				Tag:         "octocat/hello-world:latest",
+			},
+			failure: false,
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		err := test.build.ValidateTarball()
+
+		if test.failure && err == nil {
+			t.Errorf("ValidateTarball should have returned err")
+		}
+
+		if !test.failure && err != nil {
+			t.Errorf("ValidateTarball returned err: %v", err)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright (c) 2022 Target Brands, Inc. All rights reserved.
+//
+// Use of this source code is governed by the LICENSE file in this repository.
+
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	// redacted represents the value displayed in place of a secret.
+	redacted = "***"
+
+	// secretsPrefix represents the path prefix for files containing Vela secrets.
+	secretsPrefix = "/vela/secrets/"
+
+	// minSecretLength represents the minimum length of a value loaded
+	// from a secret file to be redacted to avoid masking trivial values.
+	minSecretLength = 4
+)
+
+var (
+	// secrets represents the sensitive values redacted from the plugin output.
+	secrets []string
+
+	// secretsMu protects the sensitive values from concurrent access.
+	secretsMu sync.RWMutex
+
+	// credentialFlags represents the flags containing credentials
+	// which are never printed in dry run mode.
+	credentialFlags = map[string]bool{
+		"build.http-cache-options":  true,
+		"build.redis-cache-options": true,
+		"build.registry-config":     true,
+		"registry.docker-config":    true,
+		"registry.password":         true,
+		"registry.registries":       true,
+	}
+
+	// sensitiveHeader represents the header names containing sensitive values.
+	sensitiveHeader = regexp.MustCompile(`(?i)(auth|cookie|key|password|secret|token)`)
+)
+
+// redactHook represents a logrus hook to
+// redact secrets from the plugin logs.
+type redactHook struct{}
+
+// Levels returns the log levels the hook is fired for.
+func (h *redactHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire redacts secrets from the log entry.
+func (h *redactHook) Fire(e *logrus.Entry) error {
+	// redact secrets from the log message
+	e.Message = redact(e.Message)
+
+	// redact secrets from the log fields
+	for key, value := range e.Data {
+		if s, ok := value.(string); ok {
+			e.Data[key] = redact(s)
+		}
+	}
+
+	return nil
+}
+
+// addSecrets is a helper function to register
+// sensitive values to redact from the output.
+func addSecrets(values ...string) {
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+
+	for _, value := range values {
+		// trim any whitespace from the secret
+		value = strings.TrimSpace(value)
+
+		// skip empty values
+		if len(value) == 0 {
+			continue
+		}
+
+		secrets = append(secrets, value)
+	}
+
+	// sort longest secrets first to avoid partially redacting values
+	sort.SliceStable(secrets, func(i, j int) bool {
+		return len(secrets[i]) > len(secrets[j])
+	})
+}
+
+// redact is a helper function to replace any
+// registered secrets in the provided string.
+func redact(s string) string {
+	secretsMu.RLock()
+	defer secretsMu.RUnlock()
+
+	for _, secret := range secrets {
+		s = strings.ReplaceAll(s, secret, redacted)
+	}
+
+	return s
+}
+
+// redactArgs is a helper function to replace any
+// registered secrets in the provided arguments.
+func redactArgs(args []string) []string {
+	// variable to store redacted arguments
+	redactedArgs := make([]string, 0, len(args))
+
+	for _, arg := range args {
+		redactedArgs = append(redactedArgs, redact(arg))
+	}
+
+	return redactedArgs
+}
+
+// secretFiles is a helper function to capture the values
+// loaded from Vela secret files for the provided flags.
+func secretFiles(flags []cli.Flag) []string {
+	// use custom filesystem which enables us to test
+	a := &afero.Afero{
+		Fs: appFS,
+	}
+
+	// variable to store values from secret files
+	var values []string
+
+	for _, flag := range flags {
+		// skip boolean flags which can not contain credentials
+		if _, ok := flag.(*cli.BoolFlag); ok {
+			continue
+		}
+
+		_, filePath := flagLookup(flag)
+
+		for _, path := range strings.Split(filePath, ",") {
+			// skip paths which are not Vela secrets
+			if !strings.HasPrefix(path, secretsPrefix) {
+				continue
+			}
+
+			data, err := a.ReadFile(path)
+			if err != nil {
+				continue
+			}
+
+			// capture the full value and any list elements from the file
+			for _, value := range append([]string{string(data)}, strings.Split(string(data), ",")...) {
+				value = strings.TrimSpace(value)
+
+				// skip empty values
+				if len(value) == 0 {
+					continue
+				}
+
+				// skip very short values to avoid masking unrelated output
+				if len(value) < minSecretLength {
+					logrus.Warnf("not redacting value from secret %s shorter than %d characters", path, minSecretLength)
+
+					continue
+				}
+
+				values = append(values, value)
+			}
+		}
+	}
+
+	return values
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2022 Target Brands, Inc. All rights reserved.
+//
+// Use of this source code is governed by the LICENSE file in this repository.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+	"github.com/urfave/cli/v2"
+)
+
+func TestMakisu_redact(t *testing.T) {
+	// setup types
+	secrets = nil
+
+	addSecrets("superSecret", "superSecret123", "  ")
+
+	want := "--redis-cache-password *** --http-cache-header Authorization: ***"
+
+	got := redact("--redis-cache-password superSecret123 --http-cache-header Authorization: superSecret")
+
+	if got != want {
+		t.Errorf("redact is %s, want %s", got, want)
+	}
+}
+
+func TestMakisu_redactArgs(t *testing.T) {
+	// setup types
+	secrets = nil
+
+	addSecrets("superSecret123")
+
+	want := []string{_makisu, buildAction, "--redis-cache-password", redacted}
+
+	got := redactArgs([]string{_makisu, buildAction, "--redis-cache-password", "superSecret123"})
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("redactArgs is %v, want %v", got, want)
+	}
+}
+
+func TestMakisu_redactHook_Fire(t *testing.T) {
+	// setup types
+	secrets = nil
+
+	addSecrets("superSecret123")
+
+	h := new(redactHook)
+
+	e := &logrus.Entry{
+		Data:    logrus.Fields{"password": "superSecret123"},
+		Message: "executing cmd --redis-cache-password superSecret123",
+	}
+
+	err := h.Fire(e)
+	if err != nil {
+		t.Errorf("Fire returned err: %v", err)
+	}
+
+	if e.Message != "executing cmd --redis-cache-password ***" {
+		t.Errorf("Fire message is %s, want redacted message", e.Message)
+	}
+
+	if e.Data["password"] != redacted {
+		t.Errorf("Fire data is %v, want redacted data", e.Data)
+	}
+}
+
+func TestMakisu_secretFiles(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	_ = afero.WriteFile(appFS, "/vela/secrets/makisu/build/build_args", []byte("TOKEN=superSecret,FOO=bar"), 0644)
+	_ = afero.WriteFile(appFS, "/vela/secrets/makisu/build/tag", []byte("octocat/hello-world"), 0644)
+	_ = afero.WriteFile(appFS, "/vela/secrets/makisu/registry/password", []byte("superSecretPassword"), 0644)
+	_ = afero.WriteFile(appFS, "/vela/secrets/makisu/registry/username", []byte("foo"), 0644)
+	_ = afero.WriteFile(appFS, "/vela/secrets/makisu/build/load", []byte("true"), 0644)
+
+	// setup types
+	flags := []cli.Flag{
+		&cli.StringSliceFlag{
+			FilePath: "/vela/parameters/makisu/build/build_args,/vela/secrets/makisu/build/build_args",
+			Name:     "build.build-args",
+		},
+		&cli.StringFlag{
+			FilePath: "/vela/parameters/makisu/build/tag,/vela/secrets/makisu/build/tag",
+			Name:     "build.tag",
+		},
+		&cli.StringFlag{
+			FilePath: "/vela/parameters/makisu/registry/password,/vela/secrets/makisu/registry/password",
+			Name:     "registry.password",
+		},
+		&cli.StringFlag{
+			FilePath: "/vela/parameters/makisu/registry/username,/vela/secrets/makisu/registry/username",
+			Name:     "registry.username",
+		},
+		&cli.BoolFlag{
+			FilePath: "/vela/parameters/makisu/build/load,/vela/secrets/makisu/build/load",
+			Name:     "build.load",
+		},
+	}
+
+	// the short value and the boolean are not redacted
+	want := []string{
+		"TOKEN=superSecret,FOO=bar", "TOKEN=superSecret", "FOO=bar",
+		"octocat/hello-world", "octocat/hello-world",
+		"superSecretPassword", "superSecretPassword",
+	}
+
+	got := secretFiles(flags)
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("secretFiles is %v, want %v", got, want)
+	}
+}
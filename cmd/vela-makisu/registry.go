@@ -5,12 +5,19 @@
 package main
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"regexp"
+	"sort"
+	"strings"
 
+	"github.com/docker/engine-api/types"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/afero"
 	"github.com/uber/makisu/lib/registry"
+	"github.com/uber/makisu/lib/registry/security"
+	"github.com/uber/makisu/lib/utils/httputil"
 	"github.com/urfave/cli/v2"
 )
 
@@ -73,17 +80,54 @@ const (
  }`
 )
 
-// Registry represents the input parameters for the plugin.
-type Registry struct {
-	// full url to a Docker Registry mirror
-	Mirror string
-	// full url to Docker Registry
-	Name string
-	// password for communication with the Docker Registry
-	Password string
-	// user name for communication with the Docker Registry
-	Username string
-}
+type (
+	// Registry represents the input parameters for the plugin.
+	Registry struct {
+		// enables merging custom registry configuration JSON for push_chunk, timeout, etc.
+		Config string
+		// enables setting authentication from a Docker config.json file
+		DockerConfigRaw string
+		// full url to a Docker Registry mirror
+		Mirror string
+		// full url to Docker Registry
+		Name string
+		// password for communication with the Docker Registry
+		Password string
+		// used for translating the raw registries configuration
+		Registries []*RegistryAuth
+		// enables setting authentication for additional Docker Registries
+		RegistriesRaw string
+		// user name for communication with the Docker Registry
+		Username string
+	}
+
+	// dockerConfig represents the Docker config.json
+	// file used for authenticating to registries.
+	dockerConfig struct {
+		Auths map[string]dockerAuth `json:"auths"`
+	}
+
+	// dockerAuth represents the authentication for
+	// a registry within the Docker config.json file.
+	dockerAuth struct {
+		Auth     string `json:"auth,omitempty"`
+		Password string `json:"password,omitempty"`
+		Username string `json:"username,omitempty"`
+	}
+
+	// RegistryAuth represents the authentication for
+	// an additional Docker Registry.
+	RegistryAuth struct {
+		// full url to Docker Registry
+		Name string
+		// password for communication with the Docker Registry
+		Password string
+		// regular expression matching the repositories for the credentials (default ".*")
+		Repo string
+		// user name for communication with the Docker Registry
+		Username string
+	}
+)
 
 var (
 	// appFs represents a instance of the filesystem.
@@ -91,6 +135,12 @@ var (
 
 	// configFlags represents for config settings on the cli.
 	configFlags = []cli.Flag{
+		&cli.StringFlag{
+			EnvVars:  []string{"PARAMETER_DOCKER_CONFIG", "DOCKER_CONFIG_JSON"},
+			FilePath: string("/vela/parameters/makisu/registry/docker_config,/vela/secrets/makisu/registry/docker_config"),
+			Name:     "registry.docker-config",
+			Usage:    "Docker config.json document with auths for communication with registries",
+		},
 		&cli.StringFlag{
 			EnvVars:  []string{"PARAMETER_REGISTRY", "REGISTRY_NAME"},
 			FilePath: string("/vela/parameters/makisu/registry/name,/vela/secrets/docker/registry/name"),
@@ -110,6 +160,12 @@ var (
 			Name:     "registry.password",
 			Usage:    "password for communication with the registry",
 		},
+		&cli.StringFlag{
+			EnvVars:  []string{"PARAMETER_REGISTRIES", "REGISTRY_REGISTRIES"},
+			FilePath: string("/vela/parameters/makisu/registry/registries,/vela/secrets/makisu/registry/registries"),
+			Name:     "registry.registries",
+			Usage:    "authentication for additional registries to communicate with",
+		},
 		&cli.StringFlag{
 			EnvVars:  []string{"PARAMETER_USERNAME", "REGISTRY_USERNAME", "DOCKER_USERNAME"},
 			FilePath: string("/vela/parameters/makisu/registry/username,/vela/secrets/makisu/registry/username,/vela/secrets/makisu/username"),
@@ -122,9 +178,10 @@ var (
 	configPath = "/makisu/registry/config.json"
 )
 
-// Write creates a Docker config.json file for building and publishing the image.
-func (r *Registry) Write() error {
-	logrus.Trace("creating registry configuration file")
+// Render formats and outputs the registry configuration
+// for building and publishing the image.
+func (r *Registry) Render() ([]byte, error) {
+	logrus.Trace("rendering registry configuration")
 
 	// allocate a config registry map
 	config := make(registry.Map)
@@ -132,7 +189,7 @@ func (r *Registry) Write() error {
 	// add the anonymous docker hub config to map
 	err := json.Unmarshal([]byte(dockerHubConf), &config)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// when a mirror is provided add it to the config
@@ -146,25 +203,60 @@ func (r *Registry) Write() error {
 		// add the user config to the registry map
 		err = json.Unmarshal([]byte(mirror), &config)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 
-	// create output string for config.json file
-	registry := fmt.Sprintf(
-		registryConf,
-		r.Name,
-		r.Username,
-		r.Password,
-	)
+	// check if credentials are provided for the registry
+	if len(r.Username) > 0 {
+		// create output string for config.json file
+		registry := fmt.Sprintf(
+			registryConf,
+			r.Name,
+			r.Username,
+			r.Password,
+		)
 
-	// add the user config to the registry map
-	err = json.Unmarshal([]byte(registry), &config)
-	if err != nil {
-		return err
+		// add the user config to the registry map
+		err = json.Unmarshal([]byte(registry), &config)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// add the additional registries to the registry map
+	for _, auth := range r.Registries {
+		// check if the registry exists in the map
+		if _, ok := config[auth.Name]; !ok {
+			config[auth.Name] = make(registry.RepositoryMap)
+		}
+
+		config[auth.Name][auth.repo()] = auth.Config()
 	}
 
 	registryConf, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+
+	// check if custom registry configuration is provided
+	if len(r.Config) > 0 {
+		// merge the custom config with the generated authentication
+		registryConf, err = mergeRegistryConfig(registryConf, []byte(r.Config))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return registryConf, nil
+}
+
+// Write creates a Docker config.json file for building and publishing the image.
+func (r *Registry) Write() error {
+	logrus.Trace("creating registry configuration file")
+
+	// render the registry configuration
+	registryConf, err := r.Render()
 	if err != nil {
 		return err
 	}
@@ -177,24 +269,399 @@ func (r *Registry) Write() error {
 	return a.WriteFile(configPath, registryConf, 0644)
 }
 
+// Secrets outputs the sensitive values for configuring the Registry.
+func (r *Registry) Secrets() []string {
+	// variable to store sensitive values
+	values := []string{r.Password}
+
+	for _, auth := range r.Registries {
+		values = append(values, auth.Password)
+	}
+
+	// variable to store the credentials for the registries
+	auths := append([]*RegistryAuth{{Password: r.Password, Username: r.Username}}, r.Registries...)
+
+	for _, auth := range auths {
+		// skip registries without a password
+		if len(auth.Password) == 0 {
+			continue
+		}
+
+		// capture the encoded forms of the password i.e. the "auth" of a docker config
+		values = append(values, base64.StdEncoding.EncodeToString([]byte(auth.Username+":"+auth.Password)))
+
+		// capture the password escaped within a JSON string
+		escaped, _ := json.Marshal(auth.Password)
+		if e := strings.Trim(string(escaped), `"`); e != auth.Password {
+			values = append(values, e)
+		}
+	}
+
+	return values
+}
+
+// Unmarshal captures the provided properties and
+// serializes them into their expected form.
+func (r *Registry) Unmarshal() error {
+	logrus.Trace("unmarshaling registry options")
+
+	// check if any registries were passed
+	if len(r.RegistriesRaw) > 0 {
+		// cast raw registries into bytes
+		registries := []byte(r.RegistriesRaw)
+
+		// serialize raw registries into expected RegistryAuth type
+		err := json.Unmarshal(registries, &r.Registries)
+		if err != nil {
+			return err
+		}
+	}
+
+	// check if a docker config was passed
+	if len(r.DockerConfigRaw) > 0 {
+		// serialize raw docker config into expected RegistryAuth type
+		auths, err := parseDockerConfig(r.DockerConfigRaw)
+		if err != nil {
+			return err
+		}
+
+		// registries provided explicitly take precedence over the docker config
+		r.Registries = append(auths, r.Registries...)
+	}
+
+	return nil
+}
+
 // Validate verifies the registry is properly configured.
 func (r *Registry) Validate() error {
 	logrus.Trace("validating registry plugin configuration")
 
+	// verify url is provided
+	if len(r.Name) == 0 {
+		return fmt.Errorf("no registry address provided")
+	}
+
+	// verify custom registry configuration is valid JSON
+	if len(r.Config) > 0 && !json.Valid([]byte(r.Config)) {
+		return fmt.Errorf("invalid registry config provided: must be valid JSON")
+	}
+
+	// verify each repository in the custom registry configuration is a valid regular expression
+	if len(r.Config) > 0 {
+		config := make(map[string]map[string]json.RawMessage)
+
+		err := json.Unmarshal([]byte(r.Config), &config)
+		if err != nil {
+			return fmt.Errorf("invalid registry config provided: %w", err)
+		}
+
+		for name, repos := range config {
+			for repo := range repos {
+				_, err := regexp.Compile(repo)
+				if err != nil {
+					return fmt.Errorf("invalid repo %s provided in registry config for %s: %w", repo, name, err)
+				}
+			}
+		}
+	}
+
+	// verify each additional registry is properly configured
+	for _, auth := range r.Registries {
+		err := auth.Validate()
+		if err != nil {
+			return err
+		}
+	}
+
+	// credentials are optional when additional registries are provided
+	if len(r.Registries) > 0 && len(r.Password) == 0 && len(r.Username) == 0 {
+		return nil
+	}
+
 	// verify password are provided
 	if len(r.Password) == 0 {
 		return fmt.Errorf("no registry password provided")
 	}
 
+	// verify username is provided
+	if len(r.Username) == 0 {
+		return fmt.Errorf("no registry username provided")
+	}
+
+	return nil
+}
+
+// Config formats and outputs the makisu registry
+// configuration for authenticating to the registry.
+func (a *RegistryAuth) Config() registry.Config {
+	return registry.Config{
+		Security: security.Config{
+			TLS: &httputil.TLSConfig{},
+			BasicAuth: &security.BasicAuthConfig{
+				AuthConfig: types.AuthConfig{
+					Username: a.Username,
+					Password: a.Password,
+				},
+			},
+		},
+	}
+}
+
+// Validate verifies the additional registry is properly configured.
+func (a *RegistryAuth) Validate() error {
+	logrus.Tracef("validating registry configuration for %s", a.Name)
+
 	// verify url is provided
-	if len(r.Name) == 0 {
-		return fmt.Errorf("no registry address provided")
+	if len(a.Name) == 0 {
+		return fmt.Errorf("no address provided for registries entry")
+	}
+
+	// verify password are provided
+	if len(a.Password) == 0 {
+		return fmt.Errorf("no password provided for registry %s", a.Name)
 	}
 
 	// verify username is provided
-	if len(r.Username) == 0 {
-		return fmt.Errorf("no registry username provided")
+	if len(a.Username) == 0 {
+		return fmt.Errorf("no username provided for registry %s", a.Name)
+	}
+
+	// verify repo is a valid regular expression
+	_, err := regexp.Compile(a.repo())
+	if err != nil {
+		return fmt.Errorf("invalid repo provided for registry %s: %w", a.Name, err)
 	}
 
 	return nil
 }
+
+// mergeRegistryConfig is a helper function to deep merge custom
+// registry configuration with the generated authentication.
+//
+// The following precedence rules are applied:
+//
+//   - registries only present in the custom config are added unchanged
+//   - values in the custom config override generated values for the same registry and repository
+//   - credentials generated by the plugin can not be overridden with different credentials
+//   - repositories only present in the custom config inherit the generated ".*" credentials
+func mergeRegistryConfig(generated, custom []byte) ([]byte, error) {
+	logrus.Trace("merging custom registry configuration")
+
+	// variables to store the registry configurations
+	var gen, cust map[string]map[string]map[string]interface{}
+
+	// serialize the generated config into the generic type
+	err := json.Unmarshal(generated, &gen)
+	if err != nil {
+		return nil, err
+	}
+
+	// serialize the custom config into the generic type
+	err = json.Unmarshal(custom, &cust)
+	if err != nil {
+		return nil, fmt.Errorf("invalid registry config provided: %w", err)
+	}
+
+	for name, repos := range cust {
+		// check if the registry exists in the generated config
+		genRepos, ok := gen[name]
+		if !ok {
+			gen[name] = repos
+
+			continue
+		}
+
+		for repo, config := range repos {
+			// treat a null repository config as an empty config
+			if config == nil {
+				config = make(map[string]interface{})
+			}
+
+			// check if the repository exists in the generated config
+			genConfig, ok := genRepos[repo]
+			if !ok {
+				// inherit the generated credentials for the registry
+				basic := basicAuth(genRepos[".*"])
+				if basic != nil && basicAuth(config) == nil {
+					mergeMaps(config, map[string]interface{}{
+						"security": map[string]interface{}{"basic": basic},
+					})
+				}
+
+				genRepos[repo] = config
+
+				continue
+			}
+
+			// capture the generated credentials for the repository
+			basic := basicAuth(genConfig)
+
+			// verify the custom config does not conflict with the credentials
+			if hasCredentials(basic) {
+				custBasic := basicAuth(config)
+				if hasCredentials(custBasic) &&
+					(custBasic["username"] != basic["username"] || custBasic["password"] != basic["password"]) {
+					return nil, fmt.Errorf("registry config for %s (repo %s) conflicts with provided credentials", name, repo)
+				}
+			}
+
+			mergeMaps(genConfig, config)
+		}
+	}
+
+	return json.Marshal(gen)
+}
+
+// mergeMaps is a helper function to recursively merge
+// the values from the src map into the dst map.
+func mergeMaps(dst, src map[string]interface{}) {
+	for key, value := range src {
+		srcMap, srcOk := value.(map[string]interface{})
+		dstMap, dstOk := dst[key].(map[string]interface{})
+
+		// merge nested objects present in both maps
+		if srcOk && dstOk {
+			mergeMaps(dstMap, srcMap)
+
+			continue
+		}
+
+		dst[key] = value
+	}
+}
+
+// basicAuth is a helper function to return the
+// basic authentication from a registry config.
+func basicAuth(config map[string]interface{}) map[string]interface{} {
+	security, ok := config["security"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	basic, ok := security["basic"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	return basic
+}
+
+// hasCredentials is a helper function to determine if
+// basic authentication contains a username or password.
+func hasCredentials(basic map[string]interface{}) bool {
+	for _, key := range []string{"username", "password"} {
+		value, ok := basic[key].(string)
+		if ok && len(value) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseDockerConfig is a helper function to convert a
+// Docker config.json document into registry authentication.
+func parseDockerConfig(raw string) ([]*RegistryAuth, error) {
+	logrus.Trace("parsing docker config for registry authentication")
+
+	// cast raw docker config into bytes
+	data := []byte(strings.TrimSpace(raw))
+
+	// check if the docker config is base64 encoded
+	if !json.Valid(data) {
+		decoded, err := base64.StdEncoding.DecodeString(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("invalid docker config provided: %w", err)
+		}
+
+		data = decoded
+	}
+
+	config := new(dockerConfig)
+
+	// serialize raw docker config into expected dockerConfig type
+	err := json.Unmarshal(data, config)
+	if err != nil {
+		return nil, err
+	}
+
+	// check if the document only contains the auths
+	if len(config.Auths) == 0 {
+		err = json.Unmarshal(data, &config.Auths)
+		if err != nil {
+			return nil, fmt.Errorf("no auths found in docker config: %w", err)
+		}
+	}
+
+	// variable to store authentication for registries
+	var auths []*RegistryAuth
+
+	for name, auth := range config.Auths {
+		username, password := auth.Username, auth.Password
+
+		// check if the credentials are encoded
+		if len(auth.Auth) > 0 {
+			decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+			if err != nil {
+				return nil, fmt.Errorf("unable to decode auth for registry %s: %w", name, err)
+			}
+
+			// split the credentials into the username and password
+			creds := strings.SplitN(string(decoded), ":", 2)
+			if len(creds) != 2 {
+				return nil, fmt.Errorf("invalid auth provided for registry %s", name)
+			}
+
+			username, password = creds[0], creds[1]
+		}
+
+		// skip registries without credentials i.e. a credential helper
+		if len(username) == 0 && len(password) == 0 {
+			logrus.Warnf("no credentials found in docker config for registry %s", name)
+
+			continue
+		}
+
+		auths = append(auths, &RegistryAuth{
+			Name:     dockerRegistryName(name),
+			Password: password,
+			Username: username,
+		})
+	}
+
+	// sort the registries to produce a consistent configuration
+	sort.Slice(auths, func(i, j int) bool {
+		return auths[i].Name < auths[j].Name
+	})
+
+	return auths, nil
+}
+
+// dockerRegistryName is a helper function to convert an address
+// from the Docker config.json file into a registry name.
+func dockerRegistryName(address string) string {
+	// remove the scheme from the address
+	name := strings.TrimPrefix(address, "https://")
+	name = strings.TrimPrefix(name, "http://")
+
+	// remove any path from the address i.e. "/v1/"
+	name = strings.SplitN(name, "/", 2)[0]
+
+	// docker uses a legacy address for docker hub
+	if name == "docker.io" || name == "registry-1.docker.io" {
+		return "index.docker.io"
+	}
+
+	return name
+}
+
+// repo is a helper function to return the
+// repositories the credentials apply to.
+func (a *RegistryAuth) repo() string {
+	// default to all repositories when no repo is provided
+	if len(a.Repo) == 0 {
+		return ".*"
+	}
+
+	return a.Repo
+}
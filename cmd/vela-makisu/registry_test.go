@@ -5,9 +5,13 @@
 package main
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
 	"testing"
 
 	"github.com/spf13/afero"
+	"github.com/uber/makisu/lib/registry"
 )
 
 func TestMakisu_Registry_Write(t *testing.T) {
@@ -28,6 +32,249 @@ func TestMakisu_Registry_Write(t *testing.T) {
 	}
 }
 
+func TestMakisu_Registry_Write_Registries(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	// setup types
+	r := &Registry{
+		Name: "index.docker.io",
+		Registries: []*RegistryAuth{
+			{
+				Name:     "registry.company.com",
+				Password: "superSecretPassword",
+				Repo:     "octocat/.*",
+				Username: "octocat",
+			},
+			{
+				Name:     "index.docker.io",
+				Password: "superSecretPassword",
+				Username: "octocat",
+			},
+		},
+	}
+
+	err := r.Write()
+	if err != nil {
+		t.Errorf("Write returned err: %v", err)
+	}
+
+	got, err := afero.ReadFile(appFS, configPath)
+	if err != nil {
+		t.Errorf("ReadFile returned err: %v", err)
+	}
+
+	config := make(registry.Map)
+
+	err = json.Unmarshal(got, &config)
+	if err != nil {
+		t.Errorf("Unmarshal returned err: %v", err)
+	}
+
+	company := config["registry.company.com"]["octocat/.*"].Security.BasicAuth
+	if company == nil || company.Username != "octocat" || company.Password != "superSecretPassword" {
+		t.Errorf("Write is %v, want credentials for registry.company.com", config)
+	}
+
+	hub := config["index.docker.io"][".*"].Security.BasicAuth
+	if hub == nil || hub.Username != "octocat" || hub.Password != "superSecretPassword" {
+		t.Errorf("Write is %v, want credentials for index.docker.io", config)
+	}
+}
+
+func TestMakisu_Registry_Write_Config(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	// setup types
+	r := &Registry{
+		Config: `
+  {
+    "index.docker.io": {".*": {"push_chunk": -1, "timeout": "30s"}, "octocat/.*": {"concurrency": 5}},
+    "gcr.io": {".*": {"push_chunk": -1}}
+  }
+`,
+		Name:     "index.docker.io",
+		Password: "superSecretPassword",
+		Username: "octocat",
+	}
+
+	err := r.Write()
+	if err != nil {
+		t.Errorf("Write returned err: %v", err)
+	}
+
+	got, err := afero.ReadFile(appFS, configPath)
+	if err != nil {
+		t.Errorf("ReadFile returned err: %v", err)
+	}
+
+	var config map[string]map[string]map[string]interface{}
+
+	err = json.Unmarshal(got, &config)
+	if err != nil {
+		t.Errorf("Unmarshal returned err: %v", err)
+	}
+
+	hub := config["index.docker.io"][".*"]
+	if hub["push_chunk"] != float64(-1) || hub["timeout"] != "30s" || !hasCredentials(basicAuth(hub)) {
+		t.Errorf("Write is %v, want merged config for index.docker.io", hub)
+	}
+
+	repo := config["index.docker.io"]["octocat/.*"]
+	if repo["concurrency"] != float64(5) || !hasCredentials(basicAuth(repo)) {
+		t.Errorf("Write is %v, want inherited credentials for octocat/.*", repo)
+	}
+
+	if config["gcr.io"][".*"]["push_chunk"] != float64(-1) {
+		t.Errorf("Write is %v, want custom config for gcr.io", config["gcr.io"])
+	}
+}
+
+func TestMakisu_Registry_Render_NullConfig(t *testing.T) {
+	// setup types
+	r := &Registry{
+		Config:   `{"index.docker.io": {"foo/.*": null, ".*": null}, "gcr.io": null}`,
+		Name:     "index.docker.io",
+		Password: "superSecretPassword",
+		Username: "octocat",
+	}
+
+	got, err := r.Render()
+	if err != nil {
+		t.Errorf("Render returned err: %v", err)
+	}
+
+	var config map[string]map[string]map[string]interface{}
+
+	err = json.Unmarshal(got, &config)
+	if err != nil {
+		t.Errorf("Unmarshal returned err: %v", err)
+	}
+
+	if !hasCredentials(basicAuth(config["index.docker.io"]["foo/.*"])) {
+		t.Errorf("Render is %s, want inherited credentials for foo/.*", got)
+	}
+
+	if !hasCredentials(basicAuth(config["index.docker.io"][".*"])) {
+		t.Errorf("Render is %s, want credentials for .*", got)
+	}
+}
+
+func TestMakisu_Registry_Write_ConfigConflict(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	// setup types
+	r := &Registry{
+		Config:   `{"index.docker.io": {".*": {"security": {"basic": {"username": "foo", "password": "bar"}}}}}`,
+		Name:     "index.docker.io",
+		Password: "superSecretPassword",
+		Username: "octocat",
+	}
+
+	err := r.Write()
+	if err == nil {
+		t.Errorf("Write should have returned err")
+	}
+}
+
+func TestMakisu_Registry_Secrets(t *testing.T) {
+	// setup types
+	r := &Registry{
+		Password: "superSecretPassword",
+		Registries: []*RegistryAuth{
+			{Name: "registry.company.com", Password: "superSecret123", Username: "octocat"},
+		},
+	}
+
+	want := []string{
+		"superSecretPassword",
+		"superSecret123",
+		base64.StdEncoding.EncodeToString([]byte(":superSecretPassword")),
+		base64.StdEncoding.EncodeToString([]byte("octocat:superSecret123")),
+	}
+
+	got := r.Secrets()
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Secrets is %v, want %v", got, want)
+	}
+}
+
+func TestMakisu_Registry_Unmarshal(t *testing.T) {
+	// setup types
+	r := &Registry{
+		RegistriesRaw: `
+  [{"name": "registry.company.com", "username": "octocat", "password": "superSecretPassword", "repo": "octocat/.*"}]
+`,
+	}
+
+	want := []*RegistryAuth{
+		{
+			Name:     "registry.company.com",
+			Password: "superSecretPassword",
+			Repo:     "octocat/.*",
+			Username: "octocat",
+		},
+	}
+
+	err := r.Unmarshal()
+	if err != nil {
+		t.Errorf("Unmarshal returned err: %v", err)
+	}
+
+	if !reflect.DeepEqual(r.Registries, want) {
+		t.Errorf("Unmarshal is %v, want %v", r.Registries, want)
+	}
+}
+
+func TestMakisu_Registry_Unmarshal_DockerConfig(t *testing.T) {
+	// setup types
+	r := &Registry{
+		DockerConfigRaw: `
+  {"auths": {"https://index.docker.io/v1/": {"auth": "b2N0b2NhdDpzdXBlclNlY3JldFBhc3N3b3Jk"}}}
+`,
+		RegistriesRaw: `
+  [{"name": "registry.company.com", "username": "octocat", "password": "superSecretPassword"}]
+`,
+	}
+
+	want := []*RegistryAuth{
+		{
+			Name:     "index.docker.io",
+			Password: "superSecretPassword",
+			Username: "octocat",
+		},
+		{
+			Name:     "registry.company.com",
+			Password: "superSecretPassword",
+			Username: "octocat",
+		},
+	}
+
+	err := r.Unmarshal()
+	if err != nil {
+		t.Errorf("Unmarshal returned err: %v", err)
+	}
+
+	if !reflect.DeepEqual(r.Registries, want) {
+		t.Errorf("Unmarshal is %v, want %v", r.Registries, want)
+	}
+}
+
+func TestMakisu_Registry_Unmarshal_Failure(t *testing.T) {
+	// setup types
+	r := &Registry{
+		RegistriesRaw: "!@#$%^&*()",
+	}
+
+	err := r.Unmarshal()
+	if err == nil {
+		t.Errorf("Unmarshal should have returned err")
+	}
+}
+
 func TestMakisu_Registry_Validate(t *testing.T) {
 	// setup types
 	r := &Registry{
@@ -42,6 +289,86 @@ func TestMakisu_Registry_Validate(t *testing.T) {
 	}
 }
 
+func TestMakisu_Registry_Validate_Registries(t *testing.T) {
+	// setup types
+	r := &Registry{
+		Name: "index.docker.io",
+		Registries: []*RegistryAuth{
+			{
+				Name:     "registry.company.com",
+				Password: "superSecretPassword",
+				Username: "octocat",
+			},
+		},
+	}
+
+	err := r.Validate()
+	if err != nil {
+		t.Errorf("Validate returned err: %v", err)
+	}
+}
+
+func TestMakisu_Registry_Validate_BadRegistries(t *testing.T) {
+	// setup tests
+	tests := []*RegistryAuth{
+		{Password: "superSecretPassword", Username: "octocat"},
+		{Name: "registry.company.com", Username: "octocat"},
+		{Name: "registry.company.com", Password: "superSecretPassword"},
+		{Name: "registry.company.com", Password: "superSecretPassword", Repo: "(", Username: "octocat"},
+	}
+
+	// run tests
+	for _, test := range tests {
+		r := &Registry{
+			Name:       "index.docker.io",
+			Registries: []*RegistryAuth{test},
+		}
+
+		err := r.Validate()
+		if err == nil {
+			t.Errorf("Validate should have returned err for %v", test)
+		}
+	}
+}
+
+func TestMakisu_Registry_Validate_BadConfig(t *testing.T) {
+	// setup types
+	r := &Registry{
+		Config:   "/path/to/config.yaml",
+		Name:     "index.docker.io",
+		Password: "superSecretPassword",
+		Username: "octocat",
+	}
+
+	err := r.Validate()
+	if err == nil {
+		t.Errorf("Validate should have returned err")
+	}
+}
+
+func TestMakisu_Registry_Validate_BadConfigRepo(t *testing.T) {
+	// setup tests
+	tests := []string{
+		`{"index.docker.io": {"(": {}}}`,
+		`{"index.docker.io": ["octocat/.*"]}`,
+	}
+
+	// run tests
+	for _, test := range tests {
+		r := &Registry{
+			Config:   test,
+			Name:     "index.docker.io",
+			Password: "superSecretPassword",
+			Username: "octocat",
+		}
+
+		err := r.Validate()
+		if err == nil {
+			t.Errorf("Validate should have returned err for %s", test)
+		}
+	}
+}
+
 func TestMakisu_Registry_Validate_NoPassword(t *testing.T) {
 	// setup types
 	r := &Registry{
@@ -80,3 +407,62 @@ func TestMakisu_Registry_Validate_NoUsername(t *testing.T) {
 		t.Errorf("Validate should have returned err")
 	}
 }
+
+func TestMakisu_parseDockerConfig(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		raw  string
+		want []*RegistryAuth
+	}{
+		{
+			// full docker config
+			raw: `{"auths": {"registry.company.com": {"auth": "b2N0b2NhdDpzdXBlcjpTZWNyZXQ="}}, "credsStore": "desktop"}`,
+			want: []*RegistryAuth{
+				{Name: "registry.company.com", Password: "super:Secret", Username: "octocat"},
+			},
+		},
+		{
+			// auths only with plain credentials and a credential helper
+			raw: `{"https://registry.company.com/v2/": {"username": "octocat", "password": "superSecretPassword"}, "ghcr.io": {}}`,
+			want: []*RegistryAuth{
+				{Name: "registry.company.com", Password: "superSecretPassword", Username: "octocat"},
+			},
+		},
+		{
+			// base64 encoded docker config
+			raw: "eyJhdXRocyI6eyJkb2NrZXIuaW8iOnsiYXV0aCI6ImIyTjBiMk5oZERwd1lYTnoifX19",
+			want: []*RegistryAuth{
+				{Name: "index.docker.io", Password: "pass", Username: "octocat"},
+			},
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		got, err := parseDockerConfig(test.raw)
+		if err != nil {
+			t.Errorf("parseDockerConfig returned err: %v", err)
+		}
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parseDockerConfig is %v, want %v", got, test.want)
+		}
+	}
+}
+
+func TestMakisu_parseDockerConfig_Failure(t *testing.T) {
+	// setup tests
+	tests := []string{
+		"!@#$%^&*()",
+		`{"auths": {"registry.company.com": {"auth": "!@#$%^&*()"}}}`,
+		`{"auths": {"registry.company.com": {"auth": "b2N0b2NhdA=="}}}`,
+	}
+
+	// run tests
+	for _, test := range tests {
+		_, err := parseDockerConfig(test)
+		if err == nil {
+			t.Errorf("parseDockerConfig should have returned err for %s", test)
+		}
+	}
+}
@@ -0,0 +1,307 @@
+// Copyright (c) 2022 Target Brands, Inc. All rights reserved.
+//
+// Use of this source code is governed by the LICENSE file in this repository.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+)
+
+var (
+	// startedPushMessage represents the makisu log message when an image push starts.
+	startedPushMessage = regexp.MustCompile(`^\* Started pushing image `)
+
+	// finishedMessage represents the makisu log messages when the build steps complete.
+	finishedMessage = regexp.MustCompile(`^(Finished building target stage|Computed total image size)`)
+)
+
+type (
+	// Report represents the performance data captured
+	// while building and publishing an image.
+	Report struct {
+		// attempt of the build the report was captured for
+		Attempt int `json:"attempt"`
+		// ratio of the steps which used the cache
+		CacheHitRatio float64 `json:"cache_hit_ratio"`
+		// number of steps which used the cache
+		CacheHits int `json:"cache_hits"`
+		// total duration of the build in seconds
+		Duration float64 `json:"duration"`
+		// last error message for the build
+		Error string `json:"error,omitempty"`
+		// exit code of the build command
+		ExitCode int `json:"exit_code"`
+		// time the build finished
+		Finished time.Time `json:"finished"`
+		// number of layers committed for the image
+		LayerCount int `json:"layer_count"`
+		// total size of the layers committed for the image in bytes
+		LayerSize int64 `json:"layer_size"`
+		// layers committed for the image
+		Layers []*LayerReport `json:"layers"`
+		// duration of pushing the image in seconds
+		PushDuration float64 `json:"push_duration"`
+		// stages executed for the image
+		Stages []*StageReport `json:"stages"`
+		// time the build started
+		Started time.Time `json:"started"`
+		// number of steps executed for the image
+		Steps int `json:"steps"`
+		// tag the image was built with
+		Tag string `json:"tag"`
+
+		// timestamp of the last makisu log line
+		last float64
+		// timestamp when the first image push started
+		pushStarted float64
+		// timestamp when the last image push finished
+		pushFinished float64
+	}
+
+	// StageReport represents the performance
+	// data captured for a build stage.
+	StageReport struct {
+		// duration of the stage in seconds
+		Duration float64 `json:"duration"`
+		// instruction starting the stage i.e. "FROM alpine AS builder"
+		Name string `json:"name"`
+		// steps executed for the stage
+		Steps []*StepReport `json:"steps"`
+
+		// timestamp when the running stage started
+		started float64
+	}
+
+	// StepReport represents the performance
+	// data captured for a build step.
+	StepReport struct {
+		// indicates if the step used the cache
+		Cached bool `json:"cached"`
+		// duration of the step in seconds
+		Duration float64 `json:"duration"`
+		// instruction for the step i.e. "RUN apk add curl"
+		Instruction string `json:"instruction"`
+
+		// timestamp when the running step started
+		started float64
+	}
+
+	// LayerReport represents a layer committed for the image.
+	LayerReport struct {
+		// digest of the gzipped layer
+		Digest string `json:"digest"`
+		// size of the gzipped layer in bytes
+		Size int64 `json:"size"`
+	}
+)
+
+// Record captures the performance data from the provided makisu log entry.
+func (r *Report) Record(entry *logEntry) {
+	msg := entry.Msg
+
+	// check if the log entry has a timestamp
+	if entry.TS > 0 {
+		r.last = entry.TS
+	}
+
+	// capture the last error logged by makisu
+	if entry.failed() {
+		r.Error = msg
+
+		return
+	}
+
+	switch {
+	case stageMessage.MatchString(msg):
+		r.endStage()
+
+		r.Stages = append(r.Stages, &StageReport{
+			Name:    stageMessage.FindStringSubmatch(msg)[2],
+			started: r.last,
+		})
+	case stepMessage.MatchString(msg):
+		r.endStep()
+
+		// capture a stage for steps logged without a stage
+		if len(r.Stages) == 0 {
+			r.Stages = append(r.Stages, &StageReport{started: r.last})
+		}
+
+		stage := r.Stages[len(r.Stages)-1]
+
+		stage.Steps = append(stage.Steps, &StepReport{
+			Instruction: stepMessage.FindStringSubmatch(msg)[2],
+			started:     r.last,
+		})
+	case cacheMessage.MatchString(msg), skipMessage.MatchString(msg):
+		// check if a step is running
+		if step := r.step(); step != nil {
+			step.Cached = true
+		}
+	case committedMessage.MatchString(msg):
+		m := committedMessage.FindStringSubmatch(msg)
+
+		size, _ := strconv.ParseInt(m[2], 10, 64)
+
+		r.Layers = append(r.Layers, &LayerReport{
+			Digest: m[1],
+			Size:   size,
+		})
+	case finishedMessage.MatchString(msg):
+		r.endStage()
+	case startedPushMessage.MatchString(msg):
+		r.endStage()
+
+		// capture the start of the first push
+		if r.pushStarted == 0 {
+			r.pushStarted = r.last
+		}
+	case pushedMessage.MatchString(msg):
+		r.pushFinished = r.last
+	}
+}
+
+// Finish captures the lifecycle of the build command
+// and computes the totals for the report.
+func (r *Report) Finish(started, finished time.Time, err error) {
+	r.endStage()
+
+	r.Started = started
+	r.Finished = finished
+	r.Duration = seconds(finished.Sub(started).Seconds())
+
+	// check if the build command failed
+	if err != nil {
+		r.ExitCode = 1
+
+		var exitErr *exec.ExitError
+
+		// capture the exit code from the build command
+		if errors.As(err, &exitErr) {
+			r.ExitCode = exitErr.ExitCode()
+		}
+
+		// fallback to the error from the build command
+		if len(r.Error) == 0 {
+			r.Error = err.Error()
+		}
+	}
+
+	for _, stage := range r.Stages {
+		for _, step := range stage.Steps {
+			r.Steps++
+
+			if step.Cached {
+				r.CacheHits++
+			}
+		}
+	}
+
+	// check if any steps were executed
+	if r.Steps > 0 {
+		r.CacheHitRatio = float64(r.CacheHits) / float64(r.Steps)
+	}
+
+	r.LayerCount = len(r.Layers)
+
+	for _, layer := range r.Layers {
+		r.LayerSize += layer.Size
+	}
+
+	// check if the image was pushed
+	if r.pushStarted > 0 && r.pushFinished >= r.pushStarted {
+		r.PushDuration = seconds(r.pushFinished - r.pushStarted)
+	}
+}
+
+// Write creates the report file at the provided path.
+func (r *Report) Write(path string) error {
+	logrus.Tracef("creating report file %s", path)
+
+	// use custom filesystem which enables us to test
+	a := &afero.Afero{
+		Fs: appFS,
+	}
+
+	// create the directory for the report file
+	err := a.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+
+	report, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return a.WriteFile(path, report, 0644)
+}
+
+// step is a helper function to return the step currently running.
+func (r *Report) step() *StepReport {
+	// check if a stage is running
+	if len(r.Stages) == 0 {
+		return nil
+	}
+
+	stage := r.Stages[len(r.Stages)-1]
+
+	// check if a step is running
+	if len(stage.Steps) == 0 {
+		return nil
+	}
+
+	return stage.Steps[len(stage.Steps)-1]
+}
+
+// endStep is a helper function to capture the
+// duration of the step currently running.
+func (r *Report) endStep() {
+	step := r.step()
+
+	// check if the step is running
+	if step == nil || step.started == 0 {
+		return
+	}
+
+	step.Duration = seconds(r.last - step.started)
+	step.started = 0
+}
+
+// endStage is a helper function to capture the
+// duration of the stage currently running.
+func (r *Report) endStage() {
+	r.endStep()
+
+	// check if a stage is running
+	if len(r.Stages) == 0 {
+		return
+	}
+
+	stage := r.Stages[len(r.Stages)-1]
+
+	// check if the stage is running
+	if stage.started == 0 {
+		return
+	}
+
+	stage.Duration = seconds(r.last - stage.started)
+	stage.started = 0
+}
+
+// seconds is a helper function to round the
+// provided seconds to the nearest millisecond.
+func seconds(s float64) float64 {
+	return math.Round(s*1000) / 1000
+}
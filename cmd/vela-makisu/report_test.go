@@ -0,0 +1,150 @@
+// Copyright (c) 2022 Target Brands, Inc. All rights reserved.
+//
+// Use of this source code is governed by the LICENSE file in this repository.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+func TestMakisu_Report_Record(t *testing.T) {
+	// setup types
+	logs := []*logEntry{
+		{Level: "info", TS: 100, Msg: "* Stage 1/2 : FROM golang:1.17 AS builder"},
+		{Level: "info", TS: 100, Msg: "* Step 1/2 (commit) : FROM golang:1.17 AS builder"},
+		{Level: "info", TS: 102, Msg: "* Step 2/2 (commit) : RUN go build"},
+		{Level: "info", TS: 102.5, Msg: "* Skipping execution; cache was applied *"},
+		{Level: "info", TS: 103, Msg: "* Stage 2/2 : FROM alpine"},
+		{Level: "info", TS: 103, Msg: "* Step 1/2 (commit) : FROM alpine"},
+		{Level: "info", TS: 104, Msg: "* Step 2/2 (commit) : COPY --from=builder /app /app"},
+		{Level: "info", TS: 105.25, Msg: "* Executed COPY --from=builder /app /app", Duration: 1.25},
+		{Level: "info", TS: 105.5, Msg: "* Committed gzipped layer sha256:abc (1024 bytes)"},
+		{Level: "info", TS: 106, Msg: "Computed total image size 4096"},
+		{Level: "info", TS: 107, Msg: "* Started pushing image index.docker.io/octocat/hello-world:latest"},
+		{Level: "info", TS: 109.5, Msg: "* Pushed image index.docker.io/octocat/hello-world:latest", Duration: 2.5},
+	}
+
+	started := time.Unix(99, 0).UTC()
+	finished := time.Unix(110, 0).UTC()
+
+	want := &Report{
+		CacheHitRatio: 0.25,
+		CacheHits:     1,
+		Duration:      11,
+		Finished:      finished,
+		LayerCount:    1,
+		LayerSize:     1024,
+		Layers: []*LayerReport{
+			{Digest: "sha256:abc", Size: 1024},
+		},
+		PushDuration: 2.5,
+		Stages: []*StageReport{
+			{
+				Duration: 3,
+				Name:     "FROM golang:1.17 AS builder",
+				Steps: []*StepReport{
+					{Duration: 2, Instruction: "FROM golang:1.17 AS builder"},
+					{Cached: true, Duration: 1, Instruction: "RUN go build"},
+				},
+			},
+			{
+				Duration: 3,
+				Name:     "FROM alpine",
+				Steps: []*StepReport{
+					{Duration: 1, Instruction: "FROM alpine"},
+					{Duration: 2, Instruction: "COPY --from=builder /app /app"},
+				},
+			},
+		},
+		Started: started,
+		Steps:   4,
+		Tag:     "octocat/hello-world:latest",
+	}
+
+	// run test
+	got := &Report{
+		Tag: "octocat/hello-world:latest",
+	}
+
+	for _, entry := range logs {
+		got.Record(entry)
+	}
+
+	got.Finish(started, finished, nil)
+
+	// clear the internal timestamps
+	got.last, got.pushStarted, got.pushFinished = 0, 0, 0
+
+	if !reflect.DeepEqual(got, want) {
+		gotJSON, _ := json.Marshal(got)
+		wantJSON, _ := json.Marshal(want)
+
+		t.Errorf("Record is %s, want %s", gotJSON, wantJSON)
+	}
+}
+
+func TestMakisu_Report_Finish_Failure(t *testing.T) {
+	// setup types
+	r := new(Report)
+
+	r.Record(&logEntry{Level: "info", TS: 100, Msg: "* Stage 1/1 : FROM alpine"})
+	r.Record(&logEntry{Level: "error", TS: 101, Msg: "failed to pull image: not found"})
+
+	// run test
+	r.Finish(time.Now(), time.Now(), errors.New("exit status 1"))
+
+	if r.ExitCode != 1 {
+		t.Errorf("Finish exit code is %d, want 1", r.ExitCode)
+	}
+
+	if r.Error != "failed to pull image: not found" {
+		t.Errorf("Finish error is %s, want %s", r.Error, "failed to pull image: not found")
+	}
+
+	if r.Stages[0].Duration != 1 {
+		t.Errorf("Finish stage duration is %v, want 1", r.Stages[0].Duration)
+	}
+}
+
+func TestMakisu_Report_Write(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	// setup types
+	r := &Report{
+		CacheHitRatio: 0.5,
+		CacheHits:     1,
+		Duration:      10.5,
+		Stages:        []*StageReport{},
+		Steps:         2,
+		Tag:           "octocat/hello-world:latest",
+	}
+
+	err := r.Write("reports/makisu.json")
+	if err != nil {
+		t.Errorf("Write returned err: %v", err)
+	}
+
+	data, err := afero.ReadFile(appFS, "reports/makisu.json")
+	if err != nil {
+		t.Errorf("ReadFile returned err: %v", err)
+	}
+
+	got := new(Report)
+
+	err = json.Unmarshal(data, got)
+	if err != nil {
+		t.Errorf("Unmarshal returned err: %v", err)
+	}
+
+	if !reflect.DeepEqual(got, r) {
+		t.Errorf("Write is %v, want %v", got, r)
+	}
+}
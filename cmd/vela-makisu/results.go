@@ -0,0 +1,66 @@
+// Copyright (c) 2022 Target Brands, Inc. All rights reserved.
+//
+// Use of this source code is governed by the LICENSE file in this repository.
+
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+)
+
+type (
+	// Results represents the output captured after
+	// building and publishing an image.
+	Results struct {
+		// digest of the image manifest pushed for the tag
+		Digest string `json:"digest,omitempty"`
+		// images pushed to the registries
+		Images []*ImageResult `json:"images"`
+		// alternative targets the image was pushed to
+		Replicas []string `json:"replicas"`
+		// total size of the image config and layers
+		Size int64 `json:"size,omitempty"`
+		// tag the image was built with
+		Tag string `json:"tag"`
+	}
+
+	// ImageResult represents the output captured
+	// for an image pushed to a registry.
+	ImageResult struct {
+		// digest of the image manifest
+		Digest string `json:"digest"`
+		// full reference to the image by digest i.e. "<registry>/<repo>@sha256:..."
+		Pinned string `json:"pinned"`
+		// full reference to the image by tag i.e. "<registry>/<repo>:<tag>"
+		Reference string `json:"reference"`
+		// total size of the image config and layers
+		Size int64 `json:"size"`
+	}
+)
+
+// Write creates the results file at the provided path.
+func (r *Results) Write(path string) error {
+	logrus.Tracef("creating results file %s", path)
+
+	// use custom filesystem which enables us to test
+	a := &afero.Afero{
+		Fs: appFS,
+	}
+
+	// create the directory for the results file
+	err := a.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+
+	results, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return a.WriteFile(path, results, 0644)
+}
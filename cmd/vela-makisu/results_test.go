@@ -0,0 +1,54 @@
+// Copyright (c) 2022 Target Brands, Inc. All rights reserved.
+//
+// Use of this source code is governed by the LICENSE file in this repository.
+
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+func TestMakisu_Results_Write(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	// setup types
+	r := &Results{
+		Digest: testManifestDigest,
+		Images: []*ImageResult{
+			{
+				Digest:    testManifestDigest,
+				Pinned:    "index.docker.io/octocat/hello-world@" + testManifestDigest,
+				Reference: "index.docker.io/octocat/hello-world:latest",
+				Size:      1100,
+			},
+		},
+		Size: 1100,
+		Tag:  "octocat/hello-world:latest",
+	}
+
+	err := r.Write("results/makisu.json")
+	if err != nil {
+		t.Errorf("Write returned err: %v", err)
+	}
+
+	data, err := afero.ReadFile(appFS, "results/makisu.json")
+	if err != nil {
+		t.Errorf("ReadFile returned err: %v", err)
+	}
+
+	got := new(Results)
+
+	err = json.Unmarshal(data, got)
+	if err != nil {
+		t.Errorf("Unmarshal returned err: %v", err)
+	}
+
+	if !reflect.DeepEqual(got, r) {
+		t.Errorf("Write is %v, want %v", got, r)
+	}
+}
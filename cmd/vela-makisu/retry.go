@@ -0,0 +1,178 @@
+// Copyright (c) 2022 Target Brands, Inc. All rights reserved.
+//
+// Use of this source code is governed by the LICENSE file in this repository.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// maxOutputSize represents the maximum amount of build
+// output captured for classifying a failed build.
+const maxOutputSize = 64 * 1024
+
+var (
+	// transientError represents the errors from a build which are retried.
+	transientError = regexp.MustCompile(
+		`(?i)(\b(500|502|503|504|429)\b|timeout|timed out|deadline exceeded|connection reset|connection refused|` +
+			`broken pipe|unexpected EOF|TLS handshake|temporary failure|too many requests|service unavailable|bad gateway)`,
+	)
+
+	// sleep represents the function used to wait before retrying a build.
+	sleep = time.Sleep
+)
+
+// Retry represents the policy for retrying a build
+// which failed with a transient error.
+type Retry struct {
+	// enables setting the number of attempts for the build (default 1)
+	Attempts int
+	// enables setting the delay before the first retry, doubled for each retry i.e. "10s"
+	Backoff string
+	// enables setting the maximum random delay added to the backoff i.e. "5s"
+	Jitter string
+}
+
+// Delay outputs the time to wait before retrying
+// the build after the provided attempt.
+func (r *Retry) Delay(attempt int) time.Duration {
+	// capture the delay for the first retry
+	backoff, _ := time.ParseDuration(r.Backoff)
+
+	// double the delay for each retry
+	for i := 1; i < attempt; i++ {
+		backoff *= 2
+	}
+
+	jitter, _ := time.ParseDuration(r.Jitter)
+
+	// check if Jitter is provided
+	if jitter > 0 {
+		// nolint: gosec // the jitter does not require a secure random number
+		backoff += time.Duration(rand.Int63n(int64(jitter)))
+	}
+
+	return backoff
+}
+
+// Validate verifies the Retry is properly configured.
+func (r *Retry) Validate() error {
+	logrus.Trace("validating retry configuration")
+
+	// verify attempts are valid
+	if r.Attempts < 0 {
+		return fmt.Errorf("invalid retry attempts provided: %d", r.Attempts)
+	}
+
+	// verify the durations are valid
+	for _, d := range []string{r.Backoff, r.Jitter} {
+		if len(d) == 0 {
+			continue
+		}
+
+		duration, err := time.ParseDuration(d)
+		if err != nil {
+			return fmt.Errorf("invalid retry duration provided: %w", err)
+		}
+
+		if duration < 0 {
+			return fmt.Errorf("invalid retry duration provided: %s", d)
+		}
+	}
+
+	return nil
+}
+
+// attempts is a helper function to return the
+// number of attempts for the build.
+func (r *Retry) attempts() int {
+	// default to a single attempt
+	if r == nil || r.Attempts < 1 {
+		return 1
+	}
+
+	return r.Attempts
+}
+
+// retryable is a helper function to classify if the
+// build failed with a transient error from the output.
+func retryable(err error, output string) bool {
+	var exitErr *exec.ExitError
+
+	// check if the build command failed to start
+	if !errors.As(err, &exitErr) {
+		return transientError.MatchString(err.Error())
+	}
+
+	// only the final error is classified to ignore
+	// the output from the instructions in the build
+	return transientError.MatchString(finalError(output))
+}
+
+// finalError is a helper function to return the
+// final error logged by the build in the output.
+func finalError(output string) string {
+	processor := newLogProcessor(io.Discard)
+
+	_, _ = processor.Write([]byte(output))
+	_ = processor.Close()
+
+	// check if an error was logged in the JSON logs
+	if len(processor.LastError) > 0 {
+		return processor.LastError
+	}
+
+	// fallback to the last line logged by the build
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+
+	return lines[len(lines)-1]
+}
+
+// outputTail represents a writer capturing the
+// last output of a command for classifying errors.
+type outputTail struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+// Write captures the provided output.
+func (o *outputTail) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.buf = append(o.buf, p...)
+
+	// check if the output exceeds the maximum
+	if len(o.buf) > maxOutputSize {
+		o.buf = o.buf[len(o.buf)-maxOutputSize:]
+	}
+
+	return len(p), nil
+}
+
+// Reset removes the captured output.
+func (o *outputTail) Reset() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.buf = nil
+}
+
+// String outputs the captured output.
+func (o *outputTail) String() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return string(o.buf)
+}
@@ -0,0 +1,196 @@
+// Copyright (c) 2022 Target Brands, Inc. All rights reserved.
+//
+// Use of this source code is governed by the LICENSE file in this repository.
+
+package main
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMakisu_Retry_Delay(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		retry   *Retry
+		attempt int
+		min     time.Duration
+		max     time.Duration
+	}{
+		{ // no backoff
+			retry:   &Retry{Attempts: 3},
+			attempt: 1,
+			min:     0,
+			max:     0,
+		},
+		{ // backoff for first retry
+			retry:   &Retry{Attempts: 3, Backoff: "10s"},
+			attempt: 1,
+			min:     10 * time.Second,
+			max:     10 * time.Second,
+		},
+		{ // backoff doubled for each retry
+			retry:   &Retry{Attempts: 3, Backoff: "10s"},
+			attempt: 3,
+			min:     40 * time.Second,
+			max:     40 * time.Second,
+		},
+		{ // backoff with jitter
+			retry:   &Retry{Attempts: 3, Backoff: "10s", Jitter: "5s"},
+			attempt: 2,
+			min:     20 * time.Second,
+			max:     25 * time.Second,
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		got := test.retry.Delay(test.attempt)
+
+		if got < test.min || got > test.max {
+			t.Errorf("Delay is %s, want between %s and %s", got, test.min, test.max)
+		}
+	}
+}
+
+func TestMakisu_Retry_Validate(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		retry   *Retry
+		failure bool
+	}{
+		{
+			retry:   &Retry{Attempts: 3, Backoff: "10s", Jitter: "5s"},
+			failure: false,
+		},
+		{
+			retry:   &Retry{},
+			failure: false,
+		},
+		{
+			retry:   &Retry{Attempts: -1},
+			failure: true,
+		},
+		{
+			retry:   &Retry{Attempts: 3, Backoff: "foo"},
+			failure: true,
+		},
+		{
+			retry:   &Retry{Attempts: 3, Jitter: "-5s"},
+			failure: true,
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		err := test.retry.Validate()
+
+		if test.failure {
+			if err == nil {
+				t.Errorf("Validate should have returned err for %+v", test.retry)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Validate returned err: %v", err)
+		}
+	}
+}
+
+func TestMakisu_retryable(t *testing.T) {
+	// setup types
+	exitErr := exec.Command("false").Run()
+
+	// setup tests
+	tests := []struct {
+		err    error
+		output string
+		want   bool
+	}{
+		{
+			err:    exitErr,
+			output: `{"level":"error","msg":"push layer: Put https://index.docker.io/v2/: 503 Service Unavailable"}`,
+			want:   true,
+		},
+		{
+			err:    exitErr,
+			output: `{"level":"error","msg":"read tcp 10.0.0.1:443: read: connection reset by peer"}`,
+			want:   true,
+		},
+		{
+			err:    exitErr,
+			output: `{"level":"error","msg":"net/http: TLS handshake timeout"}`,
+			want:   true,
+		},
+		{
+			err:    exitErr,
+			output: `{"level":"error","msg":"failed to parse Dockerfile: unsupported directive FOO"}`,
+			want:   false,
+		},
+		{
+			err:    exitErr,
+			output: `{"level":"error","msg":"push manifest: 401 Unauthorized"}`,
+			want:   false,
+		},
+		{
+			err: exitErr,
+			output: `{"level":"info","msg":"curl: (7) Failed to connect to localhost: connection refused"}
+{"level":"error","msg":"failed to execute build command: exit status 7"}`,
+			want: false,
+		},
+		{
+			err:    exitErr,
+			output: "curl: (7) Failed to connect to localhost: connection refused\nerror building image: exit status 7",
+			want:   false,
+		},
+		{
+			err:    exitErr,
+			output: "Step 1/2 : FROM alpine\nerror building image: GET https://index.docker.io/v2/: 503 Service Unavailable\n",
+			want:   true,
+		},
+		{
+			err:    errors.New("fork/exec /bin/makisu: no such file or directory"),
+			output: "",
+			want:   false,
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		got := retryable(test.err, test.output)
+
+		if got != test.want {
+			t.Errorf("retryable is %v for %s, want %v", got, test.output, test.want)
+		}
+	}
+}
+
+func TestMakisu_outputTail_Write(t *testing.T) {
+	// setup types
+	o := new(outputTail)
+
+	// run test
+	_, _ = o.Write([]byte(strings.Repeat("a", maxOutputSize)))
+	_, _ = o.Write([]byte("connection reset"))
+
+	got := o.String()
+
+	if len(got) != maxOutputSize {
+		t.Errorf("Write captured %d bytes, want %d", len(got), maxOutputSize)
+	}
+
+	if !strings.HasSuffix(got, "connection reset") {
+		t.Errorf("Write is missing the last output")
+	}
+
+	o.Reset()
+
+	if len(o.String()) > 0 {
+		t.Errorf("Reset did not remove the output")
+	}
+}
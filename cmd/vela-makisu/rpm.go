@@ -0,0 +1,841 @@
+// Copyright (c) 2022 Target Brands, Inc. All rights reserved.
+//
+// Use of this source code is governed by the LICENSE file in this repository.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// tags for the rpm header of an installed package.
+//
+// https://github.com/rpm-software-management/rpm/blob/master/include/rpm/rpmtag.h
+const (
+	rpmTagName      = 1000
+	rpmTagVersion   = 1001
+	rpmTagRelease   = 1002
+	rpmTagEpoch     = 1003
+	rpmTagLicense   = 1014
+	rpmTagSourceRPM = 1044
+)
+
+// types for the values of the rpm header.
+const (
+	rpmTypeInt32  = 4
+	rpmTypeString = 6
+	rpmTypeI18N   = 9
+)
+
+const (
+	// sqliteMagic represents the header for an rpm package database in the SQLite format.
+	sqliteMagic = "SQLite format 3\x00"
+
+	// ndbMagic represents the header for an rpm package database in the NDB format i.e. "RpmP".
+	ndbMagic = 0x506d7052
+
+	// ndbSlotMagic represents the header for a slot of the NDB format i.e. "Slot".
+	ndbSlotMagic = 0x746f6c53
+
+	// ndbBlobMagic represents the header for a blob of the NDB format i.e. "BlbS".
+	ndbBlobMagic = 0x53626c42
+
+	// bdbHashMagic represents the header for an rpm package database in the Berkeley DB hash format.
+	bdbHashMagic = 0x00061561
+)
+
+// rpmDatabase represents a function reading
+// the package headers from an rpm package database.
+type rpmDatabase func(data []byte) ([][]byte, error)
+
+// parseRpmDatabase is a helper function to capture the installed packages
+// from an rpm package database in the SQLite, NDB or Berkeley DB format.
+func parseRpmDatabase(location string, data []byte) ([]*Package, error) {
+	// variable to store the reader for the database format
+	var read rpmDatabase
+
+	switch {
+	case bytes.HasPrefix(data, []byte(sqliteMagic)):
+		read = sqliteRpmHeaders
+	case len(data) >= 4 && binary.LittleEndian.Uint32(data) == ndbMagic:
+		read = ndbRpmHeaders
+	case len(data) >= 16 && (binary.LittleEndian.Uint32(data[12:]) == bdbHashMagic ||
+		binary.BigEndian.Uint32(data[12:]) == bdbHashMagic):
+		read = bdbRpmHeaders
+	default:
+		return nil, fmt.Errorf("unknown format for rpm package database %s", location)
+	}
+
+	headers, err := read(data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read rpm package database %s: %w", location, err)
+	}
+
+	// variable to store the packages for the database
+	var packages []*Package
+
+	for _, header := range headers {
+		pkg, err := parseRpmHeader(header)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read rpm package database %s: %w", location, err)
+		}
+
+		// skip the public keys imported into the database
+		if len(pkg.Name) == 0 || pkg.Name == "gpg-pubkey" {
+			continue
+		}
+
+		pkg.Location = location
+
+		packages = append(packages, pkg)
+	}
+
+	return packages, nil
+}
+
+// parseRpmHeader is a helper function to capture
+// the package from the header of an installed rpm.
+//
+// https://rpm-software-management.github.io/rpm/manual/format_header.html
+func parseRpmHeader(data []byte) (*Package, error) {
+	// verify the header contains the index and data lengths
+	if len(data) < 8 {
+		return nil, fmt.Errorf("invalid rpm header with %d bytes", len(data))
+	}
+
+	entries := int(binary.BigEndian.Uint32(data))
+	size := int(binary.BigEndian.Uint32(data[4:]))
+
+	// verify the header contains the index and the data
+	if entries < 0 || size < 0 || entries > len(data)/16 || 8+entries*16+size > len(data) {
+		return nil, fmt.Errorf("invalid rpm header with %d entries and %d bytes of data", entries, size)
+	}
+
+	store := data[8+entries*16 : 8+entries*16+size]
+
+	// variable to store the values for the tags
+	values := make(map[int]string)
+
+	for i := 0; i < entries; i++ {
+		entry := data[8+i*16:]
+
+		tag := int(binary.BigEndian.Uint32(entry))
+		kind := binary.BigEndian.Uint32(entry[4:])
+		offset := int(int32(binary.BigEndian.Uint32(entry[8:])))
+
+		// skip tags which are not captured for the package
+		switch tag {
+		case rpmTagName, rpmTagVersion, rpmTagRelease, rpmTagEpoch, rpmTagLicense, rpmTagSourceRPM:
+		default:
+			continue
+		}
+
+		if offset < 0 || offset >= len(store) {
+			return nil, fmt.Errorf("invalid offset %d for rpm header tag %d", offset, tag)
+		}
+
+		switch kind {
+		case rpmTypeInt32:
+			if offset+4 > len(store) {
+				return nil, fmt.Errorf("invalid offset %d for rpm header tag %d", offset, tag)
+			}
+
+			values[tag] = strconv.Itoa(int(int32(binary.BigEndian.Uint32(store[offset:]))))
+		case rpmTypeString, rpmTypeI18N:
+			value := store[offset:]
+
+			// the strings are terminated by a null byte
+			if end := bytes.IndexByte(value, 0); end >= 0 {
+				value = value[:end]
+			}
+
+			values[tag] = string(value)
+		}
+	}
+
+	pkg := &Package{
+		License: values[rpmTagLicense],
+		Name:    values[rpmTagName],
+		Source:  rpmSourceName(values[rpmTagSourceRPM]),
+		Type:    rpmPackage,
+		Version: values[rpmTagVersion] + "-" + values[rpmTagRelease],
+	}
+
+	// check if the package has an epoch
+	if epoch, ok := values[rpmTagEpoch]; ok && epoch != "0" {
+		pkg.Version = epoch + ":" + pkg.Version
+	}
+
+	return pkg, nil
+}
+
+// rpmSourceName is a helper function to return the name of the
+// source package from the file name of the source rpm i.e.
+// "openssl" for "openssl-1.1.1k-6.el8_5.src.rpm".
+func rpmSourceName(file string) string {
+	name := strings.TrimSuffix(strings.TrimSuffix(file, ".rpm"), ".src")
+
+	// remove the release and version of the source package
+	for i := 0; i < 2; i++ {
+		end := strings.LastIndex(name, "-")
+		if end < 0 {
+			return ""
+		}
+
+		name = name[:end]
+	}
+
+	return name
+}
+
+// sqliteRpmHeaders is a helper function to read the package headers
+// from the Packages table of an rpm package database in the SQLite format.
+//
+// https://www.sqlite.org/fileformat.html
+func sqliteRpmHeaders(data []byte) ([][]byte, error) {
+	if len(data) < 100 {
+		return nil, fmt.Errorf("invalid sqlite header")
+	}
+
+	db := &sqliteFile{data: data}
+
+	// capture the size of the pages where 1 represents 65536
+	db.pageSize = int(binary.BigEndian.Uint16(data[16:]))
+	if db.pageSize == 1 {
+		db.pageSize = 65536
+	}
+
+	// capture the usable size of the pages without the reserved bytes
+	db.usable = db.pageSize - int(data[20])
+
+	if db.pageSize < 512 || db.usable < 480 {
+		return nil, fmt.Errorf("invalid sqlite page size %d", db.pageSize)
+	}
+
+	// variable to store the root page for the Packages table
+	root := 0
+
+	// find the Packages table within the schema stored on the first page
+	err := db.walk(1, func(record []interface{}) error {
+		if len(record) < 4 {
+			return nil
+		}
+
+		kind, _ := record[0].(string)
+		name, _ := record[1].(string)
+		page, _ := record[3].(int64)
+
+		if kind == "table" && name == "Packages" {
+			root = int(page)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if root == 0 {
+		return nil, fmt.Errorf("no Packages table found")
+	}
+
+	// variable to store the headers for the packages
+	var headers [][]byte
+
+	err = db.walk(root, func(record []interface{}) error {
+		// capture the blob column of the table
+		for _, value := range record {
+			if blob, ok := value.([]byte); ok {
+				headers = append(headers, blob)
+
+				break
+			}
+		}
+
+		return nil
+	})
+
+	return headers, err
+}
+
+// sqliteFile represents the contents of a SQLite database.
+type sqliteFile struct {
+	// contents of the database
+	data []byte
+	// size of the pages in the database
+	pageSize int
+	// size of the pages without the reserved bytes
+	usable int
+}
+
+// page is a helper function to return the contents of the page with
+// the provided number and the offset of the b-tree header in the page.
+func (s *sqliteFile) page(number int) ([]byte, int, error) {
+	start := (number - 1) * s.pageSize
+
+	if number < 1 || start+s.pageSize > len(s.data) {
+		return nil, 0, fmt.Errorf("invalid sqlite page %d", number)
+	}
+
+	// the first page begins with the database header
+	if number == 1 {
+		return s.data[start : start+s.usable], 100, nil
+	}
+
+	return s.data[start : start+s.usable], 0, nil
+}
+
+// walk is a helper function to read each record of
+// the table b-tree stored at the provided root page.
+func (s *sqliteFile) walk(root int, fn func([]interface{}) error) error {
+	pages := []int{root}
+
+	// track the visited pages to avoid cycles in a corrupt database
+	visited := make(map[int]bool)
+
+	for len(pages) > 0 {
+		number := pages[0]
+		pages = pages[1:]
+
+		if visited[number] {
+			return fmt.Errorf("invalid sqlite page %d referenced twice", number)
+		}
+
+		visited[number] = true
+
+		page, offset, err := s.page(number)
+		if err != nil {
+			return err
+		}
+
+		if offset+12 > len(page) {
+			return fmt.Errorf("invalid sqlite page %d", number)
+		}
+
+		kind := page[offset]
+		cells := int(binary.BigEndian.Uint16(page[offset+3:]))
+
+		// variable to store the size of the b-tree header
+		header := 8
+
+		switch kind {
+		case 0x05: // interior table page
+			header = 12
+		case 0x0d: // leaf table page
+		default:
+			return fmt.Errorf("invalid sqlite table page %d with type %d", number, kind)
+		}
+
+		// variable to store the child pages in the order of the rows
+		var children []int
+
+		for i := 0; i < cells; i++ {
+			pointer := offset + header + i*2
+			if pointer+2 > len(page) {
+				return fmt.Errorf("invalid sqlite page %d", number)
+			}
+
+			cell := int(binary.BigEndian.Uint16(page[pointer:]))
+			if cell >= len(page) {
+				return fmt.Errorf("invalid sqlite cell on page %d", number)
+			}
+
+			// capture the left child of the interior cell
+			if kind == 0x05 {
+				if cell+4 > len(page) {
+					return fmt.Errorf("invalid sqlite cell on page %d", number)
+				}
+
+				children = append(children, int(binary.BigEndian.Uint32(page[cell:])))
+
+				continue
+			}
+
+			payload, err := s.payload(page[cell:])
+			if err != nil {
+				return err
+			}
+
+			record, err := sqliteRecord(payload)
+			if err != nil {
+				return err
+			}
+
+			err = fn(record)
+			if err != nil {
+				return err
+			}
+		}
+
+		// read the child pages before the remaining pages
+		if kind == 0x05 {
+			children = append(children, int(binary.BigEndian.Uint32(page[offset+8:])))
+
+			pages = append(children, pages...)
+		}
+	}
+
+	return nil
+}
+
+// payload is a helper function to return the payload of
+// the leaf table cell including any overflow pages.
+func (s *sqliteFile) payload(cell []byte) ([]byte, error) {
+	size, n := sqliteVarint(cell)
+	if n == 0 || size > uint64(len(s.data)) {
+		return nil, fmt.Errorf("invalid sqlite cell")
+	}
+
+	// skip the row id for the cell
+	_, m := sqliteVarint(cell[n:])
+	if m == 0 {
+		return nil, fmt.Errorf("invalid sqlite cell")
+	}
+
+	cell = cell[n+m:]
+	total := int(size)
+
+	// calculate the amount of the payload stored within the page
+	local := total
+	maxLocal := s.usable - 35
+
+	if total > maxLocal {
+		minLocal := (s.usable-12)*32/255 - 23
+
+		local = minLocal + (total-minLocal)%(s.usable-4)
+		if local > maxLocal {
+			local = minLocal
+		}
+	}
+
+	// verify the local payload and any overflow page number fit in the page
+	if local > len(cell) || (local < total && local+4 > len(cell)) {
+		return nil, fmt.Errorf("invalid sqlite cell")
+	}
+
+	payload := append(make([]byte, 0, total), cell[:local]...)
+
+	// follow the overflow pages for the payload
+	next := 0
+	if local < total {
+		next = int(binary.BigEndian.Uint32(cell[local:]))
+	}
+
+	for len(payload) < total {
+		page, _, err := s.page(next)
+		if err != nil {
+			return nil, err
+		}
+
+		remaining := total - len(payload)
+		if remaining > len(page)-4 {
+			remaining = len(page) - 4
+		}
+
+		payload = append(payload, page[4:4+remaining]...)
+
+		next = int(binary.BigEndian.Uint32(page))
+	}
+
+	return payload, nil
+}
+
+// sqliteRecord is a helper function to decode the values of the record
+// into nil, an int64, a float64 as the raw bits, a string or a []byte.
+func sqliteRecord(payload []byte) ([]interface{}, error) {
+	size, n := sqliteVarint(payload)
+	if n == 0 || size < uint64(n) || size > uint64(len(payload)) {
+		return nil, fmt.Errorf("invalid sqlite record")
+	}
+
+	header := payload[n:size]
+	body := payload[size:]
+
+	// variable to store the values for the record
+	var values []interface{}
+
+	for len(header) > 0 {
+		kind, n := sqliteVarint(header)
+		if n == 0 {
+			return nil, fmt.Errorf("invalid sqlite record")
+		}
+
+		header = header[n:]
+
+		// variable to store the length of the value
+		var length int
+
+		switch {
+		case kind == 0, kind == 8, kind == 9:
+			length = 0
+		case kind <= 4:
+			length = int(kind)
+		case kind == 5:
+			length = 6
+		case kind == 6, kind == 7:
+			length = 8
+		case kind >= 12:
+			length = int((kind - 12) / 2)
+		default:
+			return nil, fmt.Errorf("invalid sqlite serial type %d", kind)
+		}
+
+		if length > len(body) {
+			return nil, fmt.Errorf("invalid sqlite record")
+		}
+
+		value := body[:length]
+		body = body[length:]
+
+		switch {
+		case kind == 0:
+			values = append(values, nil)
+		case kind == 8, kind == 9:
+			values = append(values, int64(kind-8))
+		case kind <= 6:
+			// sign extend the big-endian integer
+			var i int64
+
+			for j, b := range value {
+				if j == 0 {
+					i = int64(int8(b))
+
+					continue
+				}
+
+				i = i<<8 | int64(b)
+			}
+
+			values = append(values, i)
+		case kind == 7:
+			values = append(values, binary.BigEndian.Uint64(value))
+		case kind%2 == 0:
+			values = append(values, value)
+		default:
+			values = append(values, string(value))
+		}
+	}
+
+	return values, nil
+}
+
+// sqliteVarint is a helper function to decode the SQLite variable length
+// integer and return the value with the number of bytes read.
+func sqliteVarint(data []byte) (uint64, int) {
+	var value uint64
+
+	for i := 0; i < 9 && i < len(data); i++ {
+		// the ninth byte contributes all of its bits
+		if i == 8 {
+			return value<<8 | uint64(data[i]), 9
+		}
+
+		value = value<<7 | uint64(data[i]&0x7f)
+
+		if data[i]&0x80 == 0 {
+			return value, i + 1
+		}
+	}
+
+	return 0, 0
+}
+
+// ndbRpmHeaders is a helper function to read the package headers
+// from an rpm package database in the NDB format.
+//
+// https://github.com/rpm-software-management/rpm/blob/master/lib/backend/ndb/rpmpkg.c
+func ndbRpmHeaders(data []byte) ([][]byte, error) {
+	if len(data) < 16 {
+		return nil, fmt.Errorf("invalid ndb header")
+	}
+
+	// capture the number of pages containing the slots
+	pages := int(binary.LittleEndian.Uint32(data[12:]))
+
+	// the slots follow the header in the slot pages of 4096 bytes
+	end := pages * 4096
+	if pages < 1 || end > len(data) {
+		return nil, fmt.Errorf("invalid ndb slot pages %d", pages)
+	}
+
+	// variable to store the headers for the packages
+	var headers [][]byte
+
+	for offset := 32; offset+16 <= end; offset += 16 {
+		slot := data[offset:]
+
+		if binary.LittleEndian.Uint32(slot) != ndbSlotMagic {
+			return nil, fmt.Errorf("invalid ndb slot at offset %d", offset)
+		}
+
+		// skip empty slots
+		index := binary.LittleEndian.Uint32(slot[4:])
+		if index == 0 {
+			continue
+		}
+
+		// the blobs are stored in blocks of 16 bytes
+		start := int(binary.LittleEndian.Uint32(slot[8:])) * 16
+		if start+16 > len(data) || binary.LittleEndian.Uint32(data[start:]) != ndbBlobMagic {
+			return nil, fmt.Errorf("invalid ndb blob for package %d", index)
+		}
+
+		length := int(binary.LittleEndian.Uint32(data[start+12:]))
+		if start+16+length > len(data) {
+			return nil, fmt.Errorf("invalid ndb blob for package %d", index)
+		}
+
+		headers = append(headers, data[start+16:start+16+length])
+	}
+
+	return headers, nil
+}
+
+// bdbRpmHeaders is a helper function to read the package headers from
+// an rpm package database in the Berkeley DB hash format.
+//
+// https://github.com/berkeleydb/libdb/blob/master/src/dbinc/db_page.h
+func bdbRpmHeaders(data []byte) ([][]byte, error) {
+	if len(data) < 24 {
+		return nil, fmt.Errorf("invalid bdb metadata")
+	}
+
+	// capture the byte order of the database from the metadata
+	var order binary.ByteOrder = binary.LittleEndian
+	if binary.BigEndian.Uint32(data[12:]) == bdbHashMagic {
+		order = binary.BigEndian
+	}
+
+	pageSize := int(order.Uint32(data[20:]))
+	if pageSize < 512 || pageSize > 65536 {
+		return nil, fmt.Errorf("invalid bdb page size %d", pageSize)
+	}
+
+	// variable to store the headers for the packages
+	var headers [][]byte
+
+	for start := pageSize; start+pageSize <= len(data); start += pageSize {
+		page := data[start : start+pageSize]
+
+		// skip pages which are not hash pages i.e. overflow pages
+		if page[25] != 13 && page[25] != 2 {
+			continue
+		}
+
+		entries := int(order.Uint16(page[20:]))
+
+		// the entries are pairs of a key followed by the data
+		for i := 1; i < entries; i += 2 {
+			if 26+i*2+2 > len(page) {
+				return nil, fmt.Errorf("invalid bdb hash page at offset %d", start)
+			}
+
+			item := int(order.Uint16(page[26+i*2:]))
+
+			// skip data which is not stored on overflow pages
+			if item+12 > len(page) || page[item] != 3 {
+				continue
+			}
+
+			header, err := bdbOverflow(data, pageSize, order,
+				int(order.Uint32(page[item+4:])), int(order.Uint32(page[item+8:])))
+			if err != nil {
+				return nil, err
+			}
+
+			headers = append(headers, header)
+		}
+	}
+
+	return headers, nil
+}
+
+// bdbOverflow is a helper function to read the data with the
+// provided length from the chain of Berkeley DB overflow pages.
+func bdbOverflow(data []byte, pageSize int, order binary.ByteOrder, next, length int) ([]byte, error) {
+	value := make([]byte, 0, length)
+
+	for i := 0; next != 0 && len(value) < length; i++ {
+		start := next * pageSize
+
+		// verify the page exists and the chain does not loop
+		if start+pageSize > len(data) || i > len(data)/pageSize {
+			return nil, fmt.Errorf("invalid bdb overflow page %d", next)
+		}
+
+		page := data[start : start+pageSize]
+
+		// the overflow pages store the length of the data in the free area offset
+		size := int(order.Uint16(page[22:]))
+		if page[25] != 7 || 26+size > pageSize {
+			return nil, fmt.Errorf("invalid bdb overflow page %d", next)
+		}
+
+		value = append(value, page[26:26+size]...)
+
+		next = int(order.Uint32(page[16:]))
+	}
+
+	if len(value) != length {
+		return nil, fmt.Errorf("invalid bdb overflow data with %d bytes, want %d", len(value), length)
+	}
+
+	return value, nil
+}
+
+// compareRpmVersions is a helper function to compare the provided
+// versions with the rules of rpm i.e. "[epoch:]version-release".
+func compareRpmVersions(a, b string) int {
+	epochA, versionA, releaseA := splitRpmVersion(a)
+	epochB, versionB, releaseB := splitRpmVersion(b)
+
+	if epochA != epochB {
+		return epochA - epochB
+	}
+
+	if c := rpmvercmp(versionA, versionB); c != 0 {
+		return c
+	}
+
+	return rpmvercmp(releaseA, releaseB)
+}
+
+// splitRpmVersion is a helper function to split the provided
+// version into the epoch, version and release.
+func splitRpmVersion(version string) (int, string, string) {
+	// variable to store the epoch for the version
+	epoch := 0
+
+	if e, rest, ok := cut(version, ":"); ok {
+		epoch, _ = strconv.Atoi(e)
+		version = rest
+	}
+
+	// variable to store the release for the version
+	release := ""
+
+	if i := strings.LastIndex(version, "-"); i >= 0 {
+		version, release = version[:i], version[i+1:]
+	}
+
+	return epoch, version, release
+}
+
+// rpmvercmp is a helper function to compare the segments of the
+// provided versions with the algorithm from rpm.
+//
+// https://github.com/rpm-software-management/rpm/blob/master/rpmio/rpmvercmp.c
+func rpmvercmp(a, b string) int {
+	if a == b {
+		return 0
+	}
+
+	alnum := func(c byte) bool {
+		return isDigit(c) || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+	}
+
+	for len(a) > 0 || len(b) > 0 {
+		// skip the separators between the segments
+		for len(a) > 0 && !alnum(a[0]) && a[0] != '~' && a[0] != '^' {
+			a = a[1:]
+		}
+
+		for len(b) > 0 && !alnum(b[0]) && b[0] != '~' && b[0] != '^' {
+			b = b[1:]
+		}
+
+		// a tilde sorts before everything i.e. "1.0~rc1" before "1.0"
+		if strings.HasPrefix(a, "~") || strings.HasPrefix(b, "~") {
+			if !strings.HasPrefix(a, "~") {
+				return 1
+			}
+
+			if !strings.HasPrefix(b, "~") {
+				return -1
+			}
+
+			a, b = a[1:], b[1:]
+
+			continue
+		}
+
+		// a caret sorts after the end of the version i.e. "1.0^git1" after "1.0"
+		if strings.HasPrefix(a, "^") || strings.HasPrefix(b, "^") {
+			switch {
+			case len(a) == 0:
+				return -1
+			case len(b) == 0:
+				return 1
+			case a[0] != '^':
+				return 1
+			case b[0] != '^':
+				return -1
+			}
+
+			a, b = a[1:], b[1:]
+
+			continue
+		}
+
+		if len(a) == 0 || len(b) == 0 {
+			break
+		}
+
+		// capture the numeric or alphabetic segment from each version
+		numeric := isDigit(a[0])
+
+		segment := func(s string) (string, string) {
+			i := 0
+
+			for i < len(s) && alnum(s[i]) && isDigit(s[i]) == numeric {
+				i++
+			}
+
+			return s[:i], s[i:]
+		}
+
+		var segA, segB string
+
+		segA, a = segment(a)
+		segB, b = segment(b)
+
+		// a numeric segment is newer than an alphabetic segment
+		if len(segB) == 0 {
+			if numeric {
+				return 1
+			}
+
+			return -1
+		}
+
+		if numeric {
+			segA = strings.TrimLeft(segA, "0")
+			segB = strings.TrimLeft(segB, "0")
+
+			if len(segA) != len(segB) {
+				if len(segA) > len(segB) {
+					return 1
+				}
+
+				return -1
+			}
+		}
+
+		if c := strings.Compare(segA, segB); c != 0 {
+			return c
+		}
+	}
+
+	switch {
+	case len(a) == 0 && len(b) == 0:
+		return 0
+	case len(a) == 0:
+		return -1
+	default:
+		return 1
+	}
+}
@@ -0,0 +1,377 @@
+// Copyright (c) 2022 Target Brands, Inc. All rights reserved.
+//
+// Use of this source code is governed by the LICENSE file in this repository.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testRpmHeader is a helper function to create the
+// header of an installed rpm for testing.
+func testRpmHeader(name, version, release, epoch, license string) []byte {
+	// variable to store the index and the data for the header
+	var index, store bytes.Buffer
+
+	add := func(tag, kind uint32, value []byte) {
+		_ = binary.Write(&index, binary.BigEndian, []uint32{tag, kind, uint32(store.Len()), 1})
+
+		store.Write(value)
+	}
+
+	add(rpmTagName, rpmTypeString, []byte(name+"\x00"))
+	add(rpmTagVersion, rpmTypeString, []byte(version+"\x00"))
+	add(rpmTagRelease, rpmTypeString, []byte(release+"\x00"))
+	add(rpmTagLicense, rpmTypeString, []byte(license+"\x00"))
+	add(rpmTagSourceRPM, rpmTypeString, []byte(name+"-"+version+"-"+release+".src.rpm\x00"))
+
+	// check if the package has an epoch
+	if len(epoch) > 0 {
+		// align the integer within the data
+		for store.Len()%4 != 0 {
+			store.WriteByte(0)
+		}
+
+		value := make([]byte, 4)
+		binary.BigEndian.PutUint32(value, uint32(len(epoch)))
+
+		add(rpmTagEpoch, rpmTypeInt32, value)
+	}
+
+	// pad the header so it is stored on overflow pages
+	add(1004, rpmTypeString, []byte(strings.Repeat("summary ", 100)+"\x00"))
+
+	header := new(bytes.Buffer)
+
+	_ = binary.Write(header, binary.BigEndian, []uint32{uint32(index.Len() / 16), uint32(store.Len())})
+
+	header.Write(index.Bytes())
+	header.Write(store.Bytes())
+
+	return header.Bytes()
+}
+
+// testSqliteDatabase is a helper function to create an rpm package
+// database in the SQLite format with 512 byte pages for testing.
+func testSqliteDatabase(headers ...[]byte) []byte {
+	const pageSize = 512
+
+	// variable to store the pages for the database
+	pages := [][]byte{make([]byte, pageSize), make([]byte, pageSize)}
+
+	// leaf is a helper function to write the payloads as
+	// the cells of the leaf table page with the number
+	leaf := func(number int, payloads [][]byte) {
+		page := pages[number-1]
+
+		offset := 0
+		if number == 1 {
+			offset = 100
+		}
+
+		page[offset] = 0x0d
+		binary.BigEndian.PutUint16(page[offset+3:], uint16(len(payloads)))
+
+		end := pageSize
+
+		for i, payload := range payloads {
+			// calculate the amount of the payload stored within the page
+			local := len(payload)
+			if local > pageSize-35 {
+				minLocal := (pageSize-12)*32/255 - 23
+
+				local = minLocal + (len(payload)-minLocal)%(pageSize-4)
+				if local > pageSize-35 {
+					local = minLocal
+				}
+			}
+
+			cell := append([]byte{}, testSqliteVarint(uint64(len(payload)))...)
+			cell = append(cell, testSqliteVarint(uint64(i+1))...)
+			cell = append(cell, payload[:local]...)
+
+			// write the remaining payload to overflow pages
+			if local < len(payload) {
+				cell = append(cell, 0, 0, 0, 0)
+				binary.BigEndian.PutUint32(cell[len(cell)-4:], uint32(len(pages)+1))
+
+				for rest := payload[local:]; len(rest) > 0; {
+					overflow := make([]byte, pageSize)
+
+					n := copy(overflow[4:], rest)
+					rest = rest[n:]
+
+					if len(rest) > 0 {
+						binary.BigEndian.PutUint32(overflow, uint32(len(pages)+2))
+					}
+
+					pages = append(pages, overflow)
+				}
+			}
+
+			end -= len(cell)
+			copy(page[end:], cell)
+			binary.BigEndian.PutUint16(page[offset+8+i*2:], uint16(end))
+		}
+	}
+
+	// record is a helper function to encode the values as a record
+	record := func(values ...interface{}) []byte {
+		var header, body []byte
+
+		for _, value := range values {
+			switch v := value.(type) {
+			case nil:
+				header = append(header, testSqliteVarint(0)...)
+			case int:
+				header = append(header, testSqliteVarint(1)...)
+				body = append(body, byte(v))
+			case string:
+				header = append(header, testSqliteVarint(uint64(len(v)*2+13))...)
+				body = append(body, v...)
+			case []byte:
+				header = append(header, testSqliteVarint(uint64(len(v)*2+12))...)
+				body = append(body, v...)
+			}
+		}
+
+		header = append([]byte{byte(len(header) + 1)}, header...)
+
+		return append(header, body...)
+	}
+
+	leaf(1, [][]byte{
+		record("table", "Packages", "Packages", 2, "CREATE TABLE Packages (hnum INTEGER PRIMARY KEY, blob BLOB NOT NULL)"),
+	})
+
+	// write the root of the Packages table as an interior page
+	root := pages[1]
+
+	root[0] = 0x05
+	binary.BigEndian.PutUint16(root[3:], uint16(len(headers)-1))
+
+	for i, header := range headers {
+		// write each package to a separate leaf page
+		pages = append(pages, make([]byte, pageSize))
+
+		number := len(pages)
+
+		leaf(number, [][]byte{record(nil, header)})
+
+		// the last leaf page is the right-most child of the root
+		if i == len(headers)-1 {
+			binary.BigEndian.PutUint32(root[8:], uint32(number))
+
+			break
+		}
+
+		cell := make([]byte, 5)
+
+		binary.BigEndian.PutUint32(cell, uint32(number))
+		cell[4] = byte(i + 1)
+
+		copy(root[pageSize-(i+1)*5:], cell)
+		binary.BigEndian.PutUint16(root[12+i*2:], uint16(pageSize-(i+1)*5))
+	}
+
+	copy(pages[0], sqliteMagic)
+	binary.BigEndian.PutUint16(pages[0][16:], pageSize)
+
+	return bytes.Join(pages, nil)
+}
+
+// testSqliteVarint is a helper function to encode
+// a SQLite variable length integer for testing.
+func testSqliteVarint(value uint64) []byte {
+	// variable to store the groups of 7 bits
+	var groups []byte
+
+	for {
+		groups = append([]byte{byte(value & 0x7f)}, groups...)
+
+		value >>= 7
+		if value == 0 {
+			break
+		}
+	}
+
+	for i := 0; i < len(groups)-1; i++ {
+		groups[i] |= 0x80
+	}
+
+	return groups
+}
+
+// testNdbDatabase is a helper function to create an rpm
+// package database in the NDB format for testing.
+func testNdbDatabase(headers ...[]byte) []byte {
+	data := make([]byte, 4096)
+
+	binary.LittleEndian.PutUint32(data, ndbMagic)
+	binary.LittleEndian.PutUint32(data[12:], 1)
+
+	for offset := 32; offset < 4096; offset += 16 {
+		binary.LittleEndian.PutUint32(data[offset:], ndbSlotMagic)
+	}
+
+	for i, header := range headers {
+		slot := data[32+i*16:]
+
+		binary.LittleEndian.PutUint32(slot[4:], uint32(i+1))
+		binary.LittleEndian.PutUint32(slot[8:], uint32(len(data)/16))
+
+		blob := make([]byte, 16)
+
+		binary.LittleEndian.PutUint32(blob, ndbBlobMagic)
+		binary.LittleEndian.PutUint32(blob[4:], uint32(i+1))
+		binary.LittleEndian.PutUint32(blob[12:], uint32(len(header)))
+
+		data = append(data, blob...)
+		data = append(data, header...)
+
+		// align the next blob to the blocks of 16 bytes
+		for len(data)%16 != 0 {
+			data = append(data, 0)
+		}
+	}
+
+	return data
+}
+
+// testBdbDatabase is a helper function to create an rpm package
+// database in the Berkeley DB hash format for testing.
+func testBdbDatabase(headers ...[]byte) []byte {
+	const pageSize = 512
+
+	meta := make([]byte, pageSize)
+
+	binary.LittleEndian.PutUint32(meta[12:], bdbHashMagic)
+	binary.LittleEndian.PutUint32(meta[20:], pageSize)
+
+	hash := make([]byte, pageSize)
+
+	hash[25] = 13
+	binary.LittleEndian.PutUint16(hash[20:], uint16(len(headers)*2))
+
+	pages := [][]byte{meta, hash}
+	end := pageSize
+
+	for i, header := range headers {
+		// write the record number as the key
+		key := []byte{1, byte(i + 1), 0, 0, 0}
+
+		end -= len(key)
+		copy(hash[end:], key)
+		binary.LittleEndian.PutUint16(hash[26+i*4:], uint16(end))
+
+		// write the header to overflow pages
+		item := make([]byte, 12)
+
+		item[0] = 3
+		binary.LittleEndian.PutUint32(item[4:], uint32(len(pages)))
+		binary.LittleEndian.PutUint32(item[8:], uint32(len(header)))
+
+		end -= len(item)
+		copy(hash[end:], item)
+		binary.LittleEndian.PutUint16(hash[28+i*4:], uint16(end))
+
+		for rest := header; len(rest) > 0; {
+			overflow := make([]byte, pageSize)
+
+			overflow[25] = 7
+
+			n := copy(overflow[26:], rest)
+			rest = rest[n:]
+
+			binary.LittleEndian.PutUint16(overflow[22:], uint16(n))
+
+			if len(rest) > 0 {
+				binary.LittleEndian.PutUint32(overflow[16:], uint32(len(pages)+1))
+			}
+
+			pages = append(pages, overflow)
+		}
+	}
+
+	return bytes.Join(pages, nil)
+}
+
+func TestMakisu_parseRpmDatabase(t *testing.T) {
+	// setup types
+	headers := [][]byte{
+		testRpmHeader("openssl-libs", "1.1.1k", "6.el8_5", "1", "OpenSSL and ASL 2.0"),
+		testRpmHeader("gpg-pubkey", "fd431d51", "4ae0493b", "", "pubkey"),
+		testRpmHeader("bash", "4.4.20", "3.el8", "", "GPLv3+"),
+	}
+
+	// setup tests
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "rpmdb.sqlite", data: testSqliteDatabase(headers...)},
+		{name: "Packages.db", data: testNdbDatabase(headers...)},
+		{name: "Packages", data: testBdbDatabase(headers...)},
+	}
+
+	// run tests
+	for _, test := range tests {
+		want := []*Package{
+			{License: "OpenSSL and ASL 2.0", Location: test.name, Name: "openssl-libs", Source: "openssl-libs", Type: rpmPackage, Version: "1:1.1.1k-6.el8_5"},
+			{License: "GPLv3+", Location: test.name, Name: "bash", Source: "bash", Type: rpmPackage, Version: "4.4.20-3.el8"},
+		}
+
+		got, err := parseRpmDatabase(test.name, test.data)
+		if err != nil {
+			t.Errorf("parseRpmDatabase returned err for %s: %v", test.name, err)
+		}
+
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("parseRpmDatabase is %v for %s, want %v", got, test.name, want)
+		}
+	}
+}
+
+func TestMakisu_parseRpmDatabase_Invalid(t *testing.T) {
+	// setup tests
+	tests := [][]byte{
+		[]byte("not a database"),
+		[]byte(sqliteMagic),
+		testSqliteDatabase([]byte{0, 0, 0, 9, 0, 0, 0, 1}),
+		testNdbDatabase(testRpmHeader("bash", "4.4.20", "3.el8", "", "GPLv3+"))[:4200],
+	}
+
+	// run tests
+	for _, test := range tests {
+		_, err := parseRpmDatabase("rpmdb", test)
+		if err == nil {
+			t.Errorf("parseRpmDatabase should have returned err")
+		}
+	}
+}
+
+func TestMakisu_rpmSourceName(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		file string
+		want string
+	}{
+		{file: "openssl-1.1.1k-6.el8_5.src.rpm", want: "openssl"},
+		{file: "python-pip-9.0.3-22.el8.src.rpm", want: "python-pip"},
+		{file: "", want: ""},
+	}
+
+	// run tests
+	for _, test := range tests {
+		got := rpmSourceName(test.file)
+
+		if got != test.want {
+			t.Errorf("rpmSourceName is %s, want %s", got, test.want)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+// Copyright (c) 2022 Target Brands, Inc. All rights reserved.
+//
+// Use of this source code is governed by the LICENSE file in this repository.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-vela/vela-makisu/version"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+)
+
+// formats for the software bill of materials.
+const (
+	cycloneDXFormat = "cyclonedx"
+	spdxFormat      = "spdx"
+)
+
+// now represents the function used to capture the time for documents.
+var now = time.Now
+
+type (
+	// spdxDocument represents a software bill of materials in the SPDX 2.2 JSON format.
+	//
+	// https://spdx.github.io/spdx-spec/v2.2.2/
+	spdxDocument struct {
+		SPDXVersion       string             `json:"spdxVersion"`
+		DataLicense       string             `json:"dataLicense"`
+		SPDXID            string             `json:"SPDXID"`
+		Name              string             `json:"name"`
+		DocumentNamespace string             `json:"documentNamespace"`
+		CreationInfo      spdxCreationInfo   `json:"creationInfo"`
+		Packages          []*spdxPackage     `json:"packages"`
+		Relationships     []spdxRelationship `json:"relationships"`
+	}
+
+	// spdxCreationInfo represents the creation information for an SPDX document.
+	spdxCreationInfo struct {
+		Created  string   `json:"created"`
+		Creators []string `json:"creators"`
+	}
+
+	// spdxPackage represents a package within an SPDX document.
+	spdxPackage struct {
+		Name             string            `json:"name"`
+		SPDXID           string            `json:"SPDXID"`
+		VersionInfo      string            `json:"versionInfo"`
+		DownloadLocation string            `json:"downloadLocation"`
+		FilesAnalyzed    bool              `json:"filesAnalyzed"`
+		LicenseConcluded string            `json:"licenseConcluded"`
+		LicenseDeclared  string            `json:"licenseDeclared"`
+		SourceInfo       string            `json:"sourceInfo,omitempty"`
+		ExternalRefs     []spdxExternalRef `json:"externalRefs,omitempty"`
+	}
+
+	// spdxExternalRef represents a reference to a package within an SPDX document.
+	spdxExternalRef struct {
+		ReferenceCategory string `json:"referenceCategory"`
+		ReferenceType     string `json:"referenceType"`
+		ReferenceLocator  string `json:"referenceLocator"`
+	}
+
+	// spdxRelationship represents a relationship between elements within an SPDX document.
+	spdxRelationship struct {
+		SPDXElementID      string `json:"spdxElementId"`
+		RelationshipType   string `json:"relationshipType"`
+		RelatedSPDXElement string `json:"relatedSpdxElement"`
+	}
+
+	// cycloneDXDocument represents a software bill of materials in the CycloneDX 1.4 JSON format.
+	//
+	// https://cyclonedx.org/docs/1.4/json/
+	cycloneDXDocument struct {
+		BOMFormat   string                `json:"bomFormat"`
+		SpecVersion string                `json:"specVersion"`
+		Version     int                   `json:"version"`
+		Metadata    cycloneDXMetadata     `json:"metadata"`
+		Components  []*cycloneDXComponent `json:"components"`
+	}
+
+	// cycloneDXMetadata represents the metadata for a CycloneDX document.
+	cycloneDXMetadata struct {
+		Timestamp string              `json:"timestamp"`
+		Tools     []cycloneDXTool     `json:"tools"`
+		Component *cycloneDXComponent `json:"component"`
+	}
+
+	// cycloneDXTool represents the tool creating a CycloneDX document.
+	cycloneDXTool struct {
+		Vendor  string `json:"vendor"`
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+
+	// cycloneDXComponent represents a component within a CycloneDX document.
+	cycloneDXComponent struct {
+		Type       string              `json:"type"`
+		Name       string              `json:"name"`
+		Version    string              `json:"version,omitempty"`
+		PURL       string              `json:"purl,omitempty"`
+		Licenses   []cycloneDXLicense  `json:"licenses,omitempty"`
+		Properties []cycloneDXProperty `json:"properties,omitempty"`
+	}
+
+	// cycloneDXLicense represents the license for a component within a CycloneDX document.
+	cycloneDXLicense struct {
+		License struct {
+			Name string `json:"name"`
+		} `json:"license"`
+	}
+
+	// cycloneDXProperty represents a property for a component within a CycloneDX document.
+	cycloneDXProperty struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	}
+)
+
+// WriteSBOM inspects the image written to the Destination and writes
+// a software bill of materials in the configured format next to it.
+func (b *Build) WriteSBOM() error {
+	logrus.Trace("writing software bill of materials for the build")
+
+	t, err := openTarball(b.Destination)
+	if err != nil {
+		return err
+	}
+
+	distro, packages, err := t.Packages()
+	if err != nil {
+		return err
+	}
+
+	// variable to store the document for the format
+	var document interface{}
+
+	switch b.SBOM {
+	case cycloneDXFormat:
+		document = cycloneDX(b.Tag, distro, packages)
+	default:
+		document = spdx(b.Tag, t.Config, distro, packages)
+	}
+
+	data, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := sbomPath(b.Destination, b.SBOM)
+
+	logrus.Infof("writing software bill of materials with %d packages to %s", len(packages), path)
+
+	// use custom filesystem which enables us to test
+	a := &afero.Afero{
+		Fs: appFS,
+	}
+
+	return a.WriteFile(path, data, 0644)
+}
+
+// spdx is a helper function to create an SPDX
+// document for the packages found in the image.
+func spdx(name string, config []byte, distro *Distro, packages []*Package) *spdxDocument {
+	document := &spdxDocument{
+		SPDXVersion: "SPDX-2.2",
+		DataLicense: "CC0-1.0",
+		SPDXID:      "SPDXRef-DOCUMENT",
+		Name:        name,
+		// the namespace is unique for each image by the digest of the configuration
+		DocumentNamespace: fmt.Sprintf("https://github.com/go-vela/vela-makisu/spdx/%s-%x",
+			strings.NewReplacer("/", "-", ":", "-").Replace(name), sha256.Sum256(config)),
+		CreationInfo: spdxCreationInfo{
+			Created:  now().UTC().Format(time.RFC3339),
+			Creators: []string{fmt.Sprintf("Tool: vela-makisu-%s", version.New().Semantic())},
+		},
+		Packages:      []*spdxPackage{},
+		Relationships: []spdxRelationship{},
+	}
+
+	for i, p := range packages {
+		id := fmt.Sprintf("SPDXRef-Package-%s-%d", p.Type, i+1)
+
+		document.Packages = append(document.Packages, &spdxPackage{
+			Name:             p.Name,
+			SPDXID:           id,
+			VersionInfo:      p.Version,
+			DownloadLocation: "NOASSERTION",
+			FilesAnalyzed:    false,
+			LicenseConcluded: "NOASSERTION",
+			LicenseDeclared:  "NOASSERTION",
+			SourceInfo:       fmt.Sprintf("found in /%s", p.Location),
+			ExternalRefs: []spdxExternalRef{
+				{
+					ReferenceCategory: "PACKAGE-MANAGER",
+					ReferenceType:     "purl",
+					ReferenceLocator:  p.PURL(distro),
+				},
+			},
+		})
+
+		document.Relationships = append(document.Relationships, spdxRelationship{
+			SPDXElementID:      "SPDXRef-DOCUMENT",
+			RelationshipType:   "DESCRIBES",
+			RelatedSPDXElement: id,
+		})
+	}
+
+	return document
+}
+
+// cycloneDX is a helper function to create a CycloneDX
+// document for the packages found in the image.
+func cycloneDX(name string, distro *Distro, packages []*Package) *cycloneDXDocument {
+	document := &cycloneDXDocument{
+		BOMFormat:   "CycloneDX",
+		SpecVersion: "1.4",
+		Version:     1,
+		Metadata: cycloneDXMetadata{
+			Timestamp: now().UTC().Format(time.RFC3339),
+			Tools: []cycloneDXTool{
+				{
+					Vendor:  "go-vela",
+					Name:    "vela-makisu",
+					Version: version.New().Semantic(),
+				},
+			},
+			Component: &cycloneDXComponent{
+				Type: "container",
+				Name: name,
+			},
+		},
+		Components: []*cycloneDXComponent{},
+	}
+
+	for _, p := range packages {
+		component := &cycloneDXComponent{
+			Type:    "library",
+			Name:    p.Name,
+			Version: p.Version,
+			PURL:    p.PURL(distro),
+			Properties: []cycloneDXProperty{
+				{Name: "vela-makisu:location", Value: "/" + p.Location},
+			},
+		}
+
+		// check if a license is declared for the package
+		if len(p.License) > 0 {
+			license := cycloneDXLicense{}
+			license.License.Name = p.License
+
+			component.Licenses = []cycloneDXLicense{license}
+		}
+
+		document.Components = append(document.Components, component)
+	}
+
+	return document
+}
+
+// sbomPath is a helper function to return the path for the
+// software bill of materials written next to the tarball.
+//
+// i.e. "image.tar" becomes "image.spdx.json".
+func sbomPath(destination, format string) string {
+	// variable to store the extension for the format
+	ext := ".spdx.json"
+
+	if format == cycloneDXFormat {
+		ext = ".cdx.json"
+	}
+
+	return strings.TrimSuffix(destination, filepath.Ext(destination)) + ext
+}
@@ -0,0 +1,195 @@
+// Copyright (c) 2022 Target Brands, Inc. All rights reserved.
+//
+// Use of this source code is governed by the LICENSE file in this repository.
+
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+func TestMakisu_Build_WriteSBOM(t *testing.T) {
+	// setup time
+	now = func() time.Time { return time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC) }
+	defer func() { now = time.Now }()
+
+	// setup tests
+	tests := []struct {
+		format string
+		path   string
+	}{
+		{format: spdxFormat, path: "image.spdx.json"},
+		{format: cycloneDXFormat, path: "image.cdx.json"},
+	}
+
+	// run tests
+	for _, test := range tests {
+		appFS = afero.NewMemMapFs()
+
+		testTarball(t, "image.tar",
+			[]testFile{
+				{Name: "etc/os-release", Content: "ID=alpine\nVERSION_ID=3.15.4\n"},
+				{Name: "lib/apk/db/installed", Content: testApkDatabase},
+			},
+		)
+
+		b := &Build{
+			Destination: "image.tar",
+			SBOM:        test.format,
+			Tag:         "octocat/hello-world:latest",
+		}
+
+		err := b.WriteSBOM()
+		if err != nil {
+			t.Errorf("WriteSBOM returned err: %v", err)
+		}
+
+		data, err := afero.ReadFile(appFS, test.path)
+		if err != nil {
+			t.Errorf("ReadFile returned err: %v", err)
+		}
+
+		// variable to store the purls in the document
+		var purls []string
+
+		switch test.format {
+		case cycloneDXFormat:
+			document := new(cycloneDXDocument)
+
+			_ = json.Unmarshal(data, document)
+
+			if document.Metadata.Timestamp != "2022-01-01T00:00:00Z" {
+				t.Errorf("WriteSBOM timestamp is %s", document.Metadata.Timestamp)
+			}
+
+			for _, c := range document.Components {
+				purls = append(purls, c.PURL)
+			}
+		default:
+			document := new(spdxDocument)
+
+			_ = json.Unmarshal(data, document)
+
+			if len(document.Relationships) != 2 {
+				t.Errorf("WriteSBOM relationships is %d, want %d", len(document.Relationships), 2)
+			}
+
+			for _, p := range document.Packages {
+				purls = append(purls, p.ExternalRefs[0].ReferenceLocator)
+			}
+		}
+
+		want := []string{
+			"pkg:apk/alpine/libcrypto1.1@1.1.1l-r7?distro=alpine-3.15.4",
+			"pkg:apk/alpine/musl@1.2.2-r7?distro=alpine-3.15.4",
+		}
+
+		if len(purls) != len(want) || purls[0] != want[0] || purls[1] != want[1] {
+			t.Errorf("WriteSBOM is %v for %s, want %v", purls, test.format, want)
+		}
+	}
+}
+
+func TestMakisu_Build_WriteSBOM_NoTarball(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	// setup types
+	b := &Build{
+		Destination: "image.tar",
+		SBOM:        spdxFormat,
+	}
+
+	err := b.WriteSBOM()
+	if err == nil {
+		t.Errorf("WriteSBOM should have returned err")
+	}
+}
+
+func TestMakisu_sbomPath(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		destination string
+		format      string
+		want        string
+	}{
+		{destination: "image.tar", format: spdxFormat, want: "image.spdx.json"},
+		{destination: "/workspace/image.tar", format: cycloneDXFormat, want: "/workspace/image.cdx.json"},
+		{destination: "image", format: spdxFormat, want: "image.spdx.json"},
+	}
+
+	// run tests
+	for _, test := range tests {
+		got := sbomPath(test.destination, test.format)
+
+		if got != test.want {
+			t.Errorf("sbomPath is %s, want %s", got, test.want)
+		}
+	}
+}
+
+func TestMakisu_Build_WriteSBOM_Rpm(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	testTarball(t, "image.tar",
+		[]testFile{
+			{Name: "etc/os-release", Content: "ID=rhel\nVERSION_ID=8.5\n"},
+			{Name: "usr/lib/sysimage/rpm/Packages.db", Content: string(testNdbDatabase(testRpmHeader("bash", "4.4.20", "3.el8", "", "GPLv3+")))},
+		},
+	)
+
+	// setup types
+	b := &Build{
+		Destination: "image.tar",
+		SBOM:        spdxFormat,
+	}
+
+	err := b.WriteSBOM()
+	if err != nil {
+		t.Errorf("WriteSBOM returned err: %v", err)
+	}
+
+	data, err := afero.ReadFile(appFS, "image.spdx.json")
+	if err != nil {
+		t.Errorf("ReadFile returned err: %v", err)
+	}
+
+	want := "pkg:rpm/rhel/bash@4.4.20-3.el8?distro=rhel-8.5"
+	if !strings.Contains(string(data), want) {
+		t.Errorf("WriteSBOM is %s, want %s", data, want)
+	}
+}
+
+func TestMakisu_Build_WriteSBOM_InvalidRpm(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	testTarball(t, "image.tar",
+		[]testFile{
+			{Name: "etc/os-release", Content: "ID=rhel\nVERSION_ID=8.5\n"},
+			{Name: "usr/lib/sysimage/rpm/rpmdb.sqlite", Content: "SQLite format 3"},
+		},
+	)
+
+	// setup types
+	b := &Build{
+		Destination: "image.tar",
+		SBOM:        spdxFormat,
+	}
+
+	err := b.WriteSBOM()
+	if err == nil {
+		t.Errorf("WriteSBOM should have returned err")
+	}
+
+	// verify an incomplete software bill of materials was not written
+	if ok, _ := afero.Exists(appFS, "image.spdx.json"); ok {
+		t.Errorf("WriteSBOM should not have written image.spdx.json")
+	}
+}
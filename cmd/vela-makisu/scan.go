@@ -0,0 +1,282 @@
+// Copyright (c) 2022 Target Brands, Inc. All rights reserved.
+//
+// Use of this source code is governed by the LICENSE file in this repository.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+)
+
+type (
+	// Scan represents the configuration for scanning the image
+	// for vulnerabilities before it is pushed to the registries.
+	Scan struct {
+		// enables setting the directory containing the vulnerability database in the OSV format
+		Database string `json:"database"`
+		// enables failing the scan for images, packages or vulnerabilities which can not be rated (default true)
+		FailOnUnknown bool `json:"fail_on_unknown"`
+		// enables ignoring vulnerabilities by id or alias i.e. "CVE-2022-0778"
+		Ignore []string `json:"ignore"`
+		// enables setting the path to write the findings report (default next to the destination)
+		Report string `json:"report"`
+		// enables setting the lowest severity blocking the push - options: (low|medium|high|critical)
+		Severity string `json:"severity"`
+	}
+
+	// ScanReport represents the vulnerabilities found in the image.
+	ScanReport struct {
+		Database string     `json:"database"`
+		Distro   *Distro    `json:"distro"`
+		Findings []*Finding `json:"findings"`
+		Image    string     `json:"image"`
+		Packages int        `json:"packages"`
+		Passed   bool       `json:"passed"`
+		Severity string     `json:"severity"`
+	}
+
+	// Finding represents a vulnerability affecting a package in the image.
+	Finding struct {
+		Blocking     bool     `json:"blocking"`
+		FixedVersion string   `json:"fixed_version,omitempty"`
+		ID           string   `json:"id"`
+		Aliases      []string `json:"aliases,omitempty"`
+		Location     string   `json:"location"`
+		Package      string   `json:"package"`
+		Severity     string   `json:"severity"`
+		Summary      string   `json:"summary,omitempty"`
+		Version      string   `json:"version"`
+	}
+)
+
+// Validate verifies the Scan is properly configured.
+func (s *Scan) Validate() error {
+	logrus.Trace("validating scan configuration")
+
+	// verify the database is provided
+	if len(s.Database) == 0 {
+		return fmt.Errorf("no scan database provided")
+	}
+
+	// use custom filesystem which enables us to test
+	a := &afero.Afero{
+		Fs: appFS,
+	}
+
+	// verify the database is mounted
+	ok, err := a.DirExists(s.Database)
+	if err != nil || !ok {
+		return fmt.Errorf("scan database %s is not a directory", s.Database)
+	}
+
+	// verify the severity is supported
+	if _, ok := severityRank[s.Severity]; !ok || s.Severity == unknownSeverity {
+		return fmt.Errorf("invalid scan severity provided: %s (options: %s|%s|%s|%s)",
+			s.Severity, lowSeverity, mediumSeverity, highSeverity, criticalSeverity)
+	}
+
+	return nil
+}
+
+// Run scans the packages of the image written to the provided
+// tarball against the vulnerability database for the Scan.
+func (s *Scan) Run(path string) (*ScanReport, error) {
+	logrus.Tracef("scanning image tarball %s for vulnerabilities", path)
+
+	t, err := openTarball(path)
+	if err != nil {
+		return nil, err
+	}
+
+	distro, packages, err := t.Packages()
+	if err != nil {
+		// check if the image contains packages which can not be read
+		if !errors.Is(err, errUnsupportedDatabase) {
+			return nil, err
+		}
+
+		if s.FailOnUnknown {
+			return nil, fmt.Errorf("unable to scan image: %w - set fail_on_unknown to false to scan the remaining packages", err)
+		}
+
+		logrus.Warnf("scanning image without all packages: %v", err)
+	}
+
+	// check if the distro is supported by the vulnerability database
+	if _, ok := ecosystems[distro.ID]; !ok && s.FailOnUnknown {
+		return nil, fmt.Errorf("unable to scan image: unsupported distro %q for the vulnerability database - "+
+			"set fail_on_unknown to false to push images which can not be scanned", distro.ID)
+	}
+
+	vulnerabilities, err := loadVulnerabilities(s.Database, distro)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ScanReport{
+		Database: s.Database,
+		Distro:   distro,
+		Findings: []*Finding{},
+		Image:    path,
+		Packages: len(packages),
+		Passed:   true,
+		Severity: s.Severity,
+	}
+
+	for _, pkg := range packages {
+		for _, v := range vulnerabilities[pkg.SourceName()] {
+			affected, fixed := v.Affects(ecosystems[distro.ID], distro, pkg)
+			if !affected {
+				continue
+			}
+
+			// check if the vulnerability is ignored
+			if s.ignored(v) {
+				logrus.Infof("ignoring vulnerability %s for package %s", v.ID, pkg.Name)
+
+				continue
+			}
+
+			finding := &Finding{
+				FixedVersion: fixed,
+				ID:           v.ID,
+				Aliases:      v.Aliases,
+				Location:     pkg.Location,
+				Package:      pkg.Name,
+				Severity:     v.Rating(),
+				Summary:      v.Summary,
+				Version:      pkg.Version,
+			}
+
+			// check if the vulnerability blocks the push
+			//
+			// a vulnerability without a severity may be of any severity
+			if severityRank[finding.Severity] >= severityRank[s.Severity] ||
+				(finding.Severity == unknownSeverity && s.FailOnUnknown) {
+				finding.Blocking = true
+				report.Passed = false
+			}
+
+			report.Findings = append(report.Findings, finding)
+		}
+	}
+
+	// sort the findings from the highest severity
+	sort.SliceStable(report.Findings, func(i, j int) bool {
+		return severityRank[report.Findings[i].Severity] > severityRank[report.Findings[j].Severity]
+	})
+
+	return report, nil
+}
+
+// ignored is a helper function to check if the
+// vulnerability is ignored by the id or an alias.
+func (s *Scan) ignored(v *osvVulnerability) bool {
+	for _, id := range append([]string{v.ID}, v.Aliases...) {
+		for _, ignore := range s.Ignore {
+			if strings.EqualFold(id, ignore) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Blocking outputs the number of findings blocking the push.
+func (r *ScanReport) Blocking() int {
+	// variable to store the number of blocking findings
+	count := 0
+
+	for _, finding := range r.Findings {
+		if finding.Blocking {
+			count++
+		}
+	}
+
+	return count
+}
+
+// Write outputs the ScanReport to the provided path.
+func (r *ScanReport) Write(path string) error {
+	logrus.Tracef("writing scan report to %s", path)
+
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// use custom filesystem which enables us to test
+	a := &afero.Afero{
+		Fs: appFS,
+	}
+
+	// create the directory for the report
+	err = a.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("writing scan report with %d findings to %s", len(r.Findings), path)
+
+	return a.WriteFile(path, data, 0644)
+}
+
+// Gate scans the image written to the Destination for vulnerabilities
+// and pushes the image to the registries for the Build only when no
+// vulnerability reaches the severity configured for the Scan.
+func (b *Build) Gate() error {
+	logrus.Trace("gating push of the image on the vulnerability scan")
+
+	report, err := b.Scan.Run(b.Destination)
+	if err != nil {
+		return err
+	}
+
+	// capture the path for the report
+	path := b.Scan.Report
+	if len(path) == 0 {
+		path = strings.TrimSuffix(b.Destination, filepath.Ext(b.Destination)) + ".vulnerabilities.json"
+	}
+
+	err = report.Write(path)
+	if err != nil {
+		return err
+	}
+
+	for _, finding := range report.Findings {
+		logrus.Infof("found %s vulnerability %s in %s %s (fixed in %q)",
+			finding.Severity, finding.ID, finding.Package, finding.Version, finding.FixedVersion)
+	}
+
+	// check if the image failed the scan
+	if !report.Passed {
+		threshold := b.Scan.Severity + " or higher"
+
+		// check if vulnerabilities without a severity are blocking
+		if b.Scan.FailOnUnknown {
+			threshold += " or unknown"
+		}
+
+		return fmt.Errorf("found %d vulnerabilities with severity %s - image not pushed",
+			report.Blocking(), threshold)
+	}
+
+	logrus.Infof("image passed vulnerability scan with %d findings below severity %s",
+		len(report.Findings), b.Scan.Severity)
+
+	// check if the image is pushed to any registries
+	if len(b.References()) == 0 {
+		return nil
+	}
+
+	return b.push(b.Destination)
+}
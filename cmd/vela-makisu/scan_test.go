@@ -0,0 +1,333 @@
+// Copyright (c) 2022 Target Brands, Inc. All rights reserved.
+//
+// Use of this source code is governed by the LICENSE file in this repository.
+
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+// testScanTarball is a helper function to write an image tarball
+// and a vulnerability database affecting the image for testing.
+func testScanTarball(t *testing.T) {
+	t.Helper()
+
+	testTarball(t, "image.tar",
+		[]testFile{
+			{Name: "etc/os-release", Content: "ID=alpine\nVERSION_ID=3.15.4\n"},
+			{Name: "lib/apk/db/installed", Content: testApkDatabase},
+		},
+	)
+
+	_ = afero.WriteFile(appFS, "/vela/vulndb/ALPINE-CVE-2022-0778.json", []byte(testVulnerability), 0644)
+}
+
+func TestMakisu_Scan_Validate(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	_ = appFS.MkdirAll("/vela/vulndb", 0755)
+
+	// setup tests
+	tests := []struct {
+		scan    *Scan
+		failure bool
+	}{
+		{
+			scan:    &Scan{Database: "/vela/vulndb", Severity: criticalSeverity},
+			failure: false,
+		},
+		{ // missing database
+			scan:    &Scan{Severity: criticalSeverity},
+			failure: true,
+		},
+		{ // database not mounted
+			scan:    &Scan{Database: "/vela/missing", Severity: criticalSeverity},
+			failure: true,
+		},
+		{ // invalid severity
+			scan:    &Scan{Database: "/vela/vulndb", Severity: unknownSeverity},
+			failure: true,
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		err := test.scan.Validate()
+
+		if test.failure && err == nil {
+			t.Errorf("Validate should have returned err")
+		}
+
+		if !test.failure && err != nil {
+			t.Errorf("Validate returned err: %v", err)
+		}
+	}
+}
+
+func TestMakisu_Scan_Run(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	testScanTarball(t)
+
+	// setup tests
+	tests := []struct {
+		scan     *Scan
+		findings int
+		passed   bool
+	}{
+		{ // high vulnerability below critical severity
+			scan:     &Scan{Database: "/vela/vulndb", Severity: criticalSeverity},
+			findings: 1,
+			passed:   true,
+		},
+		{ // high vulnerability at high severity
+			scan:     &Scan{Database: "/vela/vulndb", Severity: highSeverity},
+			findings: 1,
+			passed:   false,
+		},
+		{ // ignored vulnerability
+			scan:     &Scan{Database: "/vela/vulndb", Ignore: []string{"cve-2022-0778"}, Severity: lowSeverity},
+			findings: 0,
+			passed:   true,
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		got, err := test.scan.Run("image.tar")
+		if err != nil {
+			t.Errorf("Run returned err: %v", err)
+		}
+
+		if len(got.Findings) != test.findings || got.Passed != test.passed {
+			t.Errorf("Run is %d findings passed %v, want %d passed %v",
+				len(got.Findings), got.Passed, test.findings, test.passed)
+		}
+	}
+}
+
+func TestMakisu_Scan_Run_Unknown(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	testScanTarball(t)
+
+	_ = afero.WriteFile(appFS, "/vela/vulndb/ALPINE-CVE-2022-1234.json", []byte(`{
+  "id": "ALPINE-CVE-2022-1234",
+  "affected": [{"package": {"ecosystem": "Alpine:v3.15", "name": "musl"}, "versions": ["1.2.2-r7"]}]
+}`), 0644)
+
+	// setup tests
+	tests := []struct {
+		scan   *Scan
+		passed bool
+	}{
+		{ // unknown vulnerability blocking the push
+			scan:   &Scan{Database: "/vela/vulndb", FailOnUnknown: true, Severity: criticalSeverity},
+			passed: false,
+		},
+		{ // unknown vulnerability allowed
+			scan:   &Scan{Database: "/vela/vulndb", Severity: criticalSeverity},
+			passed: true,
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		got, err := test.scan.Run("image.tar")
+		if err != nil {
+			t.Errorf("Run returned err: %v", err)
+		}
+
+		if len(got.Findings) != 2 || got.Passed != test.passed {
+			t.Errorf("Run is %d findings passed %v, want 2 passed %v", len(got.Findings), got.Passed, test.passed)
+		}
+	}
+}
+
+func TestMakisu_Scan_Run_Rpm(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	testTarball(t, "image.tar",
+		[]testFile{
+			{Name: "etc/os-release", Content: "ID=rocky\nVERSION_ID=8.5\n"},
+			{Name: "var/lib/rpm/Packages", Content: string(testBdbDatabase(testRpmHeader("bash", "4.4.20", "3.el8", "", "GPLv3+")))},
+		},
+	)
+
+	_ = afero.WriteFile(appFS, "/vela/vulndb/RLSA-2022-1234.json", []byte(`{
+  "id": "RLSA-2022:1234",
+  "affected": [{
+    "package": {"ecosystem": "Rocky Linux:8", "name": "bash"},
+    "ranges": [{"type": "ECOSYSTEM", "events": [{"introduced": "0"}, {"fixed": "0:4.4.20-4.el8_6"}]}]
+  }],
+  "database_specific": {"severity": "Important"}
+}`), 0644)
+
+	// setup types
+	s := &Scan{Database: "/vela/vulndb", FailOnUnknown: true, Severity: highSeverity}
+
+	got, err := s.Run("image.tar")
+	if err != nil {
+		t.Errorf("Run returned err: %v", err)
+	}
+
+	if got == nil || got.Packages != 1 || len(got.Findings) != 1 || got.Passed {
+		t.Errorf("Run is %+v, want 1 blocking finding", got)
+	}
+}
+
+func TestMakisu_Scan_Run_Unsupported(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	_ = appFS.MkdirAll("/vela/vulndb", 0755)
+
+	testTarball(t, "scratch.tar",
+		[]testFile{
+			{Name: "app", Content: "#!/bin/sh\necho hello\n", Mode: 0755},
+		},
+	)
+
+	testTarball(t, "ubi.tar",
+		[]testFile{
+			{Name: "etc/os-release", Content: "ID=rhel\nVERSION_ID=8.5\n"},
+			{Name: "var/lib/rpm/rpmdb.sqlite", Content: "SQLite format 3"},
+		},
+	)
+
+	// setup tests
+	tests := []struct {
+		path    string
+		scan    *Scan
+		failure bool
+	}{
+		{ // unsupported distro
+			path:    "scratch.tar",
+			scan:    &Scan{Database: "/vela/vulndb", FailOnUnknown: true, Severity: criticalSeverity},
+			failure: true,
+		},
+		{ // unreadable package database
+			path:    "ubi.tar",
+			scan:    &Scan{Database: "/vela/vulndb", FailOnUnknown: true, Severity: criticalSeverity},
+			failure: true,
+		},
+		{ // unsupported distro allowed
+			path:    "scratch.tar",
+			scan:    &Scan{Database: "/vela/vulndb", Severity: criticalSeverity},
+			failure: false,
+		},
+		{ // unreadable package database allowed
+			path:    "ubi.tar",
+			scan:    &Scan{Database: "/vela/vulndb", Severity: criticalSeverity},
+			failure: false,
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		got, err := test.scan.Run(test.path)
+
+		if test.failure {
+			if err == nil {
+				t.Errorf("Run should have returned err for %s", test.path)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Run returned err for %s: %v", test.path, err)
+		}
+
+		if got == nil || !got.Passed {
+			t.Errorf("Run is %+v for %s, want passed", got, test.path)
+		}
+	}
+}
+
+func TestMakisu_Build_Gate(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	testScanTarball(t)
+
+	// setup types
+	builder := new(testBuilder)
+
+	builders["test"] = builder
+	defer delete(builders, "test")
+
+	b := &Build{
+		Builder:     "test",
+		Destination: "image.tar",
+		Pushes:      []string{"index.docker.io"},
+		Scan:        &Scan{Database: "/vela/vulndb", Severity: criticalSeverity},
+		Tag:         "octocat/hello-world:latest",
+	}
+
+	err := b.Gate()
+	if err != nil {
+		t.Errorf("Gate returned err: %v", err)
+	}
+
+	if !reflect.DeepEqual(builder.pushes, []string{"image.tar"}) {
+		t.Errorf("Gate pushed %v, want image.tar", builder.pushes)
+	}
+
+	data, err := afero.ReadFile(appFS, "image.vulnerabilities.json")
+	if err != nil {
+		t.Errorf("ReadFile returned err: %v", err)
+	}
+
+	report := new(ScanReport)
+
+	_ = json.Unmarshal(data, report)
+
+	if len(report.Findings) != 1 || report.Findings[0].FixedVersion != "1.1.1n-r0" || report.Findings[0].Blocking {
+		t.Errorf("Gate report is %s", data)
+	}
+}
+
+func TestMakisu_Build_Gate_Blocked(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	testScanTarball(t)
+
+	// setup types
+	builder := new(testBuilder)
+
+	builders["test"] = builder
+	defer delete(builders, "test")
+
+	b := &Build{
+		Builder:     "test",
+		Destination: "image.tar",
+		Pushes:      []string{"index.docker.io"},
+		Scan:        &Scan{Database: "/vela/vulndb", Report: "reports/scan.json", Severity: highSeverity},
+		Tag:         "octocat/hello-world:latest",
+	}
+
+	err := b.Gate()
+	if err == nil {
+		t.Errorf("Gate should have returned err")
+	}
+
+	if len(builder.pushes) > 0 {
+		t.Errorf("Gate should not have pushed the image")
+	}
+
+	_, err = afero.ReadFile(appFS, "reports/scan.json")
+	if err != nil {
+		t.Errorf("Gate should have written the report: %v", err)
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright (c) 2022 Target Brands, Inc. All rights reserved.
+//
+// Use of this source code is governed by the LICENSE file in this repository.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+)
+
+// secretsDir represents the directory the secrets are staged in
+// for the build which is ignored when creating the image layers.
+const secretsDir = "/run/secrets"
+
+// secretID represents the characters allowed in the ID of a secret.
+var secretID = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+
+// BuildSecret represents a secret staged as a file for the
+// Dockerfile to read without persisting it in the image.
+type BuildSecret struct {
+	// enables setting the environment variable containing the secret
+	Env string `json:"env"`
+	// enables setting the name of the file in /run/secrets for the secret
+	ID string `json:"id"`
+	// enables setting the file containing the secret i.e. "/vela/secrets/npm_token"
+	Src string `json:"src"`
+
+	// value of the secret loaded from the environment variable or file
+	value string
+}
+
+// Load captures the value for the BuildSecret from
+// the environment variable or the file.
+func (s *BuildSecret) Load() error {
+	logrus.Tracef("loading value for secret %s", s.ID)
+
+	// check if the secret is provided by an environment variable
+	if len(s.Env) > 0 {
+		s.value = os.Getenv(s.Env)
+
+		// verify the environment variable is set
+		if len(s.value) == 0 {
+			return fmt.Errorf("no value provided for secret %s from environment variable %s", s.ID, s.Env)
+		}
+
+		return nil
+	}
+
+	// use custom filesystem which enables us to test
+	a := &afero.Afero{
+		Fs: appFS,
+	}
+
+	content, err := a.ReadFile(s.Src)
+	if err != nil {
+		return fmt.Errorf("unable to read secret %s: %w", s.ID, err)
+	}
+
+	s.value = string(content)
+
+	return nil
+}
+
+// Validate verifies the BuildSecret is properly configured.
+func (s *BuildSecret) Validate() error {
+	logrus.Trace("validating secret configuration")
+
+	// verify the ID can be used as a file name
+	if !secretID.MatchString(s.ID) {
+		return fmt.Errorf("invalid secret id provided: %q", s.ID)
+	}
+
+	// verify exactly one source is provided
+	if (len(s.Env) == 0) == (len(s.Src) == 0) {
+		return fmt.Errorf("secret %s requires one of env or src", s.ID)
+	}
+
+	return nil
+}
+
+// StageSecrets writes the secrets for the Build to the secrets
+// directory and outputs a function to remove them after the build.
+func (b *Build) StageSecrets() (func(), error) {
+	logrus.Trace("staging secrets for the build")
+
+	// variable to store the staged files
+	var staged []string
+
+	// cleanup removes the staged files
+	cleanup := func() {
+		for _, path := range staged {
+			err := appFS.Remove(path)
+			if err != nil {
+				logrus.Errorf("unable to remove secret %s: %v", path, err)
+			}
+		}
+	}
+
+	// check if secrets are provided
+	if len(b.BuildSecrets) == 0 {
+		return cleanup, nil
+	}
+
+	err := appFS.MkdirAll(secretsDir, 0700)
+	if err != nil {
+		return cleanup, fmt.Errorf("unable to create %s: %w", secretsDir, err)
+	}
+
+	for _, s := range b.BuildSecrets {
+		path := filepath.Join(secretsDir, s.ID)
+
+		logrus.Debugf("staging secret %s at %s", s.ID, path)
+
+		err = afero.WriteFile(appFS, path, []byte(s.value), 0400)
+		if err != nil {
+			cleanup()
+
+			return func() {}, fmt.Errorf("unable to stage secret %s: %w", s.ID, err)
+		}
+
+		staged = append(staged, path)
+	}
+
+	return cleanup, nil
+}
+
+// verifyBuildArgs is a helper function to verify the
+// values of the secrets are not provided as build args.
+func (b *Build) verifyBuildArgs(args []string) error {
+	for _, arg := range args {
+		name, value, ok := cut(arg, "=")
+
+		// check if the build arg only provides the name
+		if !ok {
+			value = os.Getenv(name)
+		}
+
+		for _, s := range b.BuildSecrets {
+			// skip trivial values to avoid matching unrelated build args
+			if len(strings.TrimSpace(s.value)) < minSecretLength {
+				continue
+			}
+
+			if strings.Contains(value, strings.TrimSpace(s.value)) {
+				return fmt.Errorf("build arg %s contains the value of secret %s, read it from %s instead",
+					name, s.ID, filepath.Join(secretsDir, s.ID))
+			}
+		}
+	}
+
+	return nil
+}
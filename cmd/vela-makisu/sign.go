@@ -0,0 +1,205 @@
+// Copyright (c) 2022 Target Brands, Inc. All rights reserved.
+//
+// Use of this source code is governed by the LICENSE file in this repository.
+
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+)
+
+const (
+	// cosignSignatureAnnotation represents the annotation
+	// containing the signature for the payload of a layer.
+	cosignSignatureAnnotation = "dev.cosignproject.cosign/signature"
+
+	// cosignSignatureType represents the type for a simple signing payload.
+	cosignSignatureType = "cosign container image signature"
+
+	// simpleSigningMediaType represents the media type for a simple signing payload.
+	simpleSigningMediaType = "application/vnd.dev.cosign.simplesigning.v1+json"
+
+	// defaultSignKey represents the default path for the key to sign the image.
+	defaultSignKey = "/vela/secrets/makisu/cosign.key"
+)
+
+type (
+	// simpleSigning represents the payload signed for an image in
+	// the format of the "atomic container signature" used by cosign.
+	//
+	// https://github.com/containers/image/blob/main/docs/containers-signature.5.md
+	simpleSigning struct {
+		Critical simpleSigningCritical `json:"critical"`
+		Optional map[string]string     `json:"optional"`
+	}
+
+	// simpleSigningCritical represents the signed claims for an image.
+	simpleSigningCritical struct {
+		Identity struct {
+			DockerReference string `json:"docker-reference"`
+		} `json:"identity"`
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+		Type string `json:"type"`
+	}
+)
+
+// SignImages signs each image in the provided results with the key for
+// the Build and uploads the signature alongside the image with
+// the tag "sha256-<digest>.sig" in the format used by cosign.
+func (b *Build) SignImages(results *Results) error {
+	logrus.Trace("signing images for the build")
+
+	// verify the image was pushed to a registry
+	if len(results.Images) == 0 {
+		return fmt.Errorf("no pushed images to sign")
+	}
+
+	// variable to store the images already signed
+	signed := make(map[string]bool)
+
+	for _, result := range results.Images {
+		client, err := newClient(b.RegistryConfig, result.Reference)
+		if err != nil {
+			return err
+		}
+
+		repository := fmt.Sprintf("%s/%s", client.Name.GetRegistry(), client.Name.GetRepository())
+
+		// skip images with the same repository and digest
+		if signed[repository+"@"+result.Digest] {
+			continue
+		}
+
+		payload, err := signaturePayload(repository, result.Digest)
+		if err != nil {
+			return err
+		}
+
+		signature, err := signPayload(b.signer, payload)
+		if err != nil {
+			return fmt.Errorf("unable to sign %s: %w", result.Reference, err)
+		}
+
+		tag := artifactTag(result.Digest, "sig")
+
+		logrus.Infof("pushing signature for %s to %s:%s", result.Reference, repository, tag)
+
+		err = client.PushArtifact(tag, []*artifactLayer{
+			{
+				Annotations: map[string]string{
+					cosignSignatureAnnotation: base64.StdEncoding.EncodeToString(signature),
+				},
+				Data:      payload,
+				MediaType: simpleSigningMediaType,
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("unable to push signature for %s: %w", result.Reference, err)
+		}
+
+		signed[repository+"@"+result.Digest] = true
+	}
+
+	return nil
+}
+
+// loadSigner is a helper function to read the
+// private key for signing from the provided path.
+func loadSigner(path string) (crypto.Signer, error) {
+	logrus.Tracef("loading signing key from %s", path)
+
+	// use custom filesystem which enables us to test
+	a := &afero.Afero{
+		Fs: appFS,
+	}
+
+	data, err := a.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read signing key: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM encoded signing key found in %s", path)
+	}
+
+	// check if the key is encrypted
+	if strings.Contains(block.Type, "ENCRYPTED") {
+		return nil, fmt.Errorf("encrypted signing keys are not supported: %s in %s - "+
+			"provide an unencrypted PKCS#8, EC or RSA PEM private key i.e. created with "+
+			"openssl instead of cosign generate-key-pair", block.Type, path)
+	}
+
+	// variable to store the parsed key
+	var key interface{}
+
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse signing key: %w", err)
+	}
+
+	switch k := key.(type) {
+	case *ecdsa.PrivateKey:
+		return k, nil
+	case *rsa.PrivateKey:
+		return k, nil
+	case ed25519.PrivateKey:
+		return k, nil
+	default:
+		return nil, fmt.Errorf("unsupported signing key type %T", key)
+	}
+}
+
+// signaturePayload is a helper function to return the
+// payload signed for the provided repository and digest.
+func signaturePayload(repository, digest string) ([]byte, error) {
+	payload := new(simpleSigning)
+
+	payload.Critical.Identity.DockerReference = repository
+	payload.Critical.Image.DockerManifestDigest = digest
+	payload.Critical.Type = cosignSignatureType
+
+	return json.Marshal(payload)
+}
+
+// signPayload is a helper function to sign the
+// provided payload with the provided key.
+func signPayload(signer crypto.Signer, payload []byte) ([]byte, error) {
+	// verify a key is loaded
+	if signer == nil {
+		return nil, fmt.Errorf("no signing key loaded")
+	}
+
+	// ed25519 keys sign the payload without hashing
+	if _, ok := signer.(ed25519.PrivateKey); ok {
+		return signer.Sign(rand.Reader, payload, crypto.Hash(0))
+	}
+
+	digest := sha256.Sum256(payload)
+
+	return signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+}
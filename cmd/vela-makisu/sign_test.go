@@ -0,0 +1,189 @@
+// Copyright (c) 2022 Target Brands, Inc. All rights reserved.
+//
+// Use of this source code is governed by the LICENSE file in this repository.
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+// testSigningKey is a helper function to write a PEM
+// encoded ECDSA private key to the provided path for testing.
+func testSigningKey(t *testing.T, path string) *ecdsa.PrivateKey {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unable to generate key: %v", err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("unable to marshal key: %v", err)
+	}
+
+	err = afero.WriteFile(appFS, path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600)
+	if err != nil {
+		t.Fatalf("unable to write key: %v", err)
+	}
+
+	return key
+}
+
+func TestMakisu_Build_SignImages(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	key := testSigningKey(t, defaultSignKey)
+
+	// setup types
+	s, store := testPushRegistry(t)
+
+	registry := strings.TrimPrefix(s.URL, "http://")
+
+	signer, err := loadSigner(defaultSignKey)
+	if err != nil {
+		t.Errorf("loadSigner returned err: %v", err)
+	}
+
+	b := &Build{
+		signer: signer,
+	}
+
+	results := &Results{
+		Images: []*ImageResult{
+			{
+				Digest:    "sha256:abc",
+				Reference: fmt.Sprintf("%s/octocat/hello-world:latest", registry),
+			},
+			{
+				Digest:    "sha256:abc",
+				Reference: fmt.Sprintf("%s/octocat/hello-world:1.0.0", registry),
+			},
+		},
+	}
+
+	err = b.SignImages(results)
+	if err != nil {
+		t.Errorf("SignImages returned err: %v", err)
+	}
+
+	data, ok := store.manifests["octocat/hello-world:sha256-abc.sig"]
+	if !ok {
+		t.Fatalf("SignImages should have pushed the signature with tag sha256-abc.sig")
+	}
+
+	manifest := new(ociManifest)
+
+	_ = json.Unmarshal(data, manifest)
+
+	if len(manifest.Layers) != 1 || manifest.Layers[0].MediaType != simpleSigningMediaType {
+		t.Fatalf("SignImages manifest is %s, want a simple signing layer", data)
+	}
+
+	payload := store.blobs[manifest.Layers[0].Digest]
+
+	want := fmt.Sprintf(`{"critical":{"identity":{"docker-reference":"%s/octocat/hello-world"},`+
+		`"image":{"docker-manifest-digest":"sha256:abc"},"type":"cosign container image signature"},"optional":null}`,
+		registry)
+
+	if string(payload) != want {
+		t.Errorf("SignImages payload is %s, want %s", payload, want)
+	}
+
+	signature, _ := base64.StdEncoding.DecodeString(manifest.Layers[0].Annotations[cosignSignatureAnnotation])
+	digest := sha256.Sum256(payload)
+
+	if !ecdsa.VerifyASN1(&key.PublicKey, digest[:], signature) {
+		t.Errorf("SignImages signature is not valid for the payload")
+	}
+}
+
+func TestMakisu_Build_SignImages_NoImages(t *testing.T) {
+	// setup types
+	b := &Build{}
+
+	err := b.SignImages(&Results{})
+	if err == nil {
+		t.Errorf("SignImages should have returned err")
+	}
+}
+
+func TestMakisu_loadSigner(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	testSigningKey(t, "ecdsa.key")
+
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	der, _ := x509.MarshalPKCS8PrivateKey(edKey)
+
+	_ = afero.WriteFile(appFS, "ed25519.key", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600)
+	_ = afero.WriteFile(appFS, "encrypted.key", pem.EncodeToMemory(&pem.Block{Type: "ENCRYPTED COSIGN PRIVATE KEY", Bytes: []byte("foo")}), 0600)
+	_ = afero.WriteFile(appFS, "invalid.key", []byte("not a key"), 0600)
+
+	// setup tests
+	tests := []struct {
+		path    string
+		failure bool
+	}{
+		{path: "ecdsa.key", failure: false},
+		{path: "ed25519.key", failure: false},
+		{path: "encrypted.key", failure: true},
+		{path: "invalid.key", failure: true},
+		{path: "missing.key", failure: true},
+	}
+
+	// run tests
+	for _, test := range tests {
+		signer, err := loadSigner(test.path)
+
+		if test.failure {
+			if err == nil {
+				t.Errorf("loadSigner should have returned err for %s", test.path)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("loadSigner returned err for %s: %v", test.path, err)
+		}
+
+		_, err = signPayload(signer, []byte("payload"))
+		if err != nil {
+			t.Errorf("signPayload returned err for %s: %v", test.path, err)
+		}
+	}
+}
+
+func TestMakisu_loadSigner_Encrypted(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	_ = afero.WriteFile(appFS, "cosign.key", pem.EncodeToMemory(&pem.Block{Type: "ENCRYPTED SIGSTORE PRIVATE KEY", Bytes: []byte("foo")}), 0600)
+
+	_, err := loadSigner("cosign.key")
+	if err == nil {
+		t.Errorf("loadSigner should have returned err")
+	}
+
+	// verify the error explains the supported keys
+	if err != nil && !strings.Contains(err.Error(), "unencrypted PKCS#8, EC or RSA PEM private key") {
+		t.Errorf("loadSigner returned err %v, want the supported keys", err)
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright (c) 2022 Target Brands, Inc. All rights reserved.
+//
+// Use of this source code is governed by the LICENSE file in this repository.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// maxTagLength represents the maximum length of a Docker tag.
+	maxTagLength = 128
+
+	// shortCommitLength represents the length of a short commit SHA.
+	shortCommitLength = 7
+)
+
+// invalidTagChars represents the characters not allowed in a Docker tag.
+var invalidTagChars = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+
+// autoTags is a helper function to derive the tags
+// for an image from the Vela build metadata.
+//
+// The tags are derived in the following order:
+//
+//   - VELA_BUILD_TAG with major and minor fan-out for semantic versions i.e. "1.2.3", "1.2", "1"
+//   - VELA_BUILD_COMMIT as a short SHA i.e. "a1b2c3d"
+//   - VELA_BUILD_BRANCH when the build is not for a tag i.e. "main"
+func autoTags() []string {
+	logrus.Trace("deriving tags from vela build metadata")
+
+	// variable to store derived tags
+	var tags []string
+
+	// capture the tag for the build
+	buildTag := os.Getenv("VELA_BUILD_TAG")
+	if len(buildTag) > 0 {
+		tags = append(tags, versionTags(buildTag)...)
+	}
+
+	// capture the commit for the build
+	commit := os.Getenv("VELA_BUILD_COMMIT")
+	if len(commit) > shortCommitLength {
+		commit = commit[:shortCommitLength]
+	}
+
+	tags = append(tags, commit)
+
+	// capture the branch for builds not created from a tag
+	if len(buildTag) == 0 {
+		tags = append(tags, os.Getenv("VELA_BUILD_BRANCH"))
+	}
+
+	// variable to store unique sanitized tags
+	var unique []string
+
+	// variable to track tags already captured
+	seen := make(map[string]bool)
+
+	for _, tag := range tags {
+		tag = sanitizeTag(tag)
+
+		// skip empty and duplicate tags
+		if len(tag) == 0 || seen[tag] {
+			continue
+		}
+
+		seen[tag] = true
+
+		unique = append(unique, tag)
+	}
+
+	return unique
+}
+
+// versionTags is a helper function to fan-out a
+// semantic version into the major and minor tags.
+func versionTags(tag string) []string {
+	v, err := semver.NewVersion(tag)
+	if err != nil {
+		logrus.Debugf("build tag %s is not a semantic version", tag)
+
+		return []string{tag}
+	}
+
+	// pre-release versions should not update the major and minor tags
+	if len(v.Prerelease()) > 0 {
+		return []string{v.String()}
+	}
+
+	return []string{
+		v.String(),
+		fmt.Sprintf("%d.%d", v.Major(), v.Minor()),
+		fmt.Sprintf("%d", v.Major()),
+	}
+}
+
+// sanitizeTag is a helper function to replace the
+// characters not allowed in a Docker tag.
+func sanitizeTag(tag string) string {
+	// replace any invalid characters i.e. "feature/foo" -> "feature-foo"
+	tag = invalidTagChars.ReplaceAllString(tag, "-")
+
+	// tags must not start with a period or dash
+	tag = strings.TrimLeft(tag, ".-")
+
+	// tags must not exceed the maximum length
+	if len(tag) > maxTagLength {
+		tag = tag[:maxTagLength]
+	}
+
+	return tag
+}
+
+// splitTag is a helper function to split an image
+// name into the repository and the tag.
+func splitTag(name string) (string, string) {
+	// capture the position of the last tag separator
+	i := strings.LastIndex(name, ":")
+
+	// check if the separator is part of a registry port
+	if i < 0 || i < strings.LastIndex(name, "/") {
+		return name, ""
+	}
+
+	return name[:i], name[i+1:]
+}
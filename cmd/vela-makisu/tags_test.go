@@ -0,0 +1,99 @@
+// Copyright (c) 2022 Target Brands, Inc. All rights reserved.
+//
+// Use of this source code is governed by the LICENSE file in this repository.
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMakisu_autoTags(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		tag    string
+		commit string
+		branch string
+		want   []string
+	}{
+		{
+			tag:    "v1.2.3",
+			commit: "b0bb040e6a6d71ddf98684349c42d36fa6c539ad",
+			branch: "main",
+			want:   []string{"1.2.3", "1.2", "1", "b0bb040"},
+		},
+		{
+			tag:    "v1.2.3-rc.1",
+			commit: "b0bb040e6a6d71ddf98684349c42d36fa6c539ad",
+			want:   []string{"1.2.3-rc.1", "b0bb040"},
+		},
+		{
+			commit: "b0bb040e6a6d71ddf98684349c42d36fa6c539ad",
+			branch: "feature/foo",
+			want:   []string{"b0bb040", "feature-foo"},
+		},
+		{
+			want: nil,
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		t.Setenv("VELA_BUILD_TAG", test.tag)
+		t.Setenv("VELA_BUILD_COMMIT", test.commit)
+		t.Setenv("VELA_BUILD_BRANCH", test.branch)
+
+		got := autoTags()
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("autoTags is %v, want %v", got, test.want)
+		}
+	}
+}
+
+func TestMakisu_sanitizeTag(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		tag  string
+		want string
+	}{
+		{tag: "1.2.3", want: "1.2.3"},
+		{tag: "feature/foo@bar", want: "feature-foo-bar"},
+		{tag: "-.foo", want: "foo"},
+		{tag: strings.Repeat("a", 200), want: strings.Repeat("a", maxTagLength)},
+	}
+
+	// run tests
+	for _, test := range tests {
+		got := sanitizeTag(test.tag)
+
+		if got != test.want {
+			t.Errorf("sanitizeTag is %s, want %s", got, test.want)
+		}
+	}
+}
+
+func TestMakisu_splitTag(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		name string
+		repo string
+		tag  string
+	}{
+		{name: "octocat/hello-world:latest", repo: "octocat/hello-world", tag: "latest"},
+		{name: "octocat/hello-world", repo: "octocat/hello-world"},
+		{name: "localhost:5000/octocat/hello-world", repo: "localhost:5000/octocat/hello-world"},
+		{name: "localhost:5000/octocat/hello-world:1", repo: "localhost:5000/octocat/hello-world", tag: "1"},
+	}
+
+	// run tests
+	for _, test := range tests {
+		repo, tag := splitTag(test.name)
+
+		if repo != test.repo || tag != test.tag {
+			t.Errorf("splitTag is %s %s, want %s %s", repo, tag, test.repo, test.tag)
+		}
+	}
+}
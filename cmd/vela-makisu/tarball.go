@@ -0,0 +1,314 @@
+// Copyright (c) 2022 Target Brands, Inc. All rights reserved.
+//
+// Use of this source code is governed by the LICENSE file in this repository.
+
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/uber/makisu/lib/docker/image"
+)
+
+const (
+	// whiteoutPrefix represents the prefix for a file
+	// within a layer removing a file from a lower layer.
+	whiteoutPrefix = ".wh."
+
+	// opaqueWhiteout represents the file within a layer
+	// removing the contents of a directory from lower layers.
+	opaqueWhiteout = ".wh..wh..opq"
+)
+
+// errEntryNotFound represents the error returned when
+// an entry can not be found within a tarball.
+var errEntryNotFound = errors.New("entry not found")
+
+// Tarball represents an image written by the builder
+// to the Destination in the format of "docker save".
+type Tarball struct {
+	// configuration for the image
+	Config []byte
+	// manifest listing the configuration and layers for the image
+	Manifest image.ExportManifest
+	// path to the tarball
+	Path string
+
+	// location of each file within the tarball
+	entries map[string]tarballEntry
+}
+
+// tarballEntry represents the location
+// of a file within the tarball.
+type tarballEntry struct {
+	// offset of the contents of the file
+	offset int64
+	// size of the contents of the file
+	size int64
+}
+
+// openTarball is a helper function to capture the
+// manifest and configuration from the provided tarball.
+func openTarball(path string) (*Tarball, error) {
+	logrus.Tracef("opening image tarball %s", path)
+
+	t := &Tarball{
+		Path: path,
+	}
+
+	// index the files within the tarball
+	err := t.index()
+	if err != nil {
+		return nil, fmt.Errorf("invalid image tarball %s: %w", path, err)
+	}
+
+	// capture the manifests for the images in the tarball
+	var manifests []image.ExportManifest
+
+	err = t.Entry(image.ExportManifestFileName, func(r io.Reader) error {
+		return json.NewDecoder(r).Decode(&manifests)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid image tarball %s: %w", path, err)
+	}
+
+	// verify the tarball contains a single image
+	if len(manifests) != 1 {
+		return nil, fmt.Errorf("invalid image tarball %s: found %d images, expected 1", path, len(manifests))
+	}
+
+	t.Manifest = manifests[0]
+
+	err = t.Entry(t.Manifest.Config.String(), func(r io.Reader) error {
+		t.Config, err = io.ReadAll(r)
+
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid image tarball %s: %w", path, err)
+	}
+
+	return t, nil
+}
+
+// index is a helper function to capture the location of
+// each file within the tarball by reading it once.
+func (t *Tarball) index() error {
+	f, err := appFS.Open(t.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	t.entries = make(map[string]tarballEntry)
+
+	tr := tar.NewReader(f)
+
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		// skip entries which are not regular files
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		// capture the offset of the contents following the header
+		offset, err := f.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return err
+		}
+
+		t.entries[cleanPath(hdr.Name)] = tarballEntry{
+			offset: offset,
+			size:   hdr.Size,
+		}
+	}
+}
+
+// Entry calls the provided function with the
+// contents of the named entry within the tarball.
+func (t *Tarball) Entry(name string, fn func(io.Reader) error) error {
+	entry, ok := t.entries[cleanPath(name)]
+	if !ok {
+		return fmt.Errorf("%s: %w", name, errEntryNotFound)
+	}
+
+	f, err := appFS.Open(t.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return fn(io.NewSectionReader(f, entry.offset, entry.size))
+}
+
+// Layer calls the provided function with the uncompressed
+// contents of the layer at the index within the tarball.
+func (t *Tarball) Layer(i int, fn func(*tar.Reader) error) error {
+	return t.uncompressed(t.Manifest.Layers[i].String(), func(r io.Reader) error {
+		return fn(tar.NewReader(r))
+	})
+}
+
+// Validate verifies the layers within the tarball
+// match the layers listed by the image configuration.
+func (t *Tarball) Validate() error {
+	logrus.Tracef("validating image tarball %s", t.Path)
+
+	config, err := image.NewImageConfigFromJSON(t.Config)
+	if err != nil {
+		return fmt.Errorf("invalid image tarball %s: %w", t.Path, err)
+	}
+
+	// verify the configuration lists every layer
+	if len(config.RootFS.DiffIDs) != len(t.Manifest.Layers) {
+		return fmt.Errorf("invalid image tarball %s: found %d layers, expected %d",
+			t.Path, len(t.Manifest.Layers), len(config.RootFS.DiffIDs))
+	}
+
+	for i, layer := range t.Manifest.Layers {
+		err = t.uncompressed(layer.String(), func(r io.Reader) error {
+			h := sha256.New()
+
+			_, err := io.Copy(h, r)
+			if err != nil {
+				return err
+			}
+
+			// verify the layer matches the configuration
+			digest := image.Digest(fmt.Sprintf("sha256:%x", h.Sum(nil)))
+			if digest != config.RootFS.DiffIDs[i] {
+				return fmt.Errorf("digest is %s, expected %s", digest, config.RootFS.DiffIDs[i])
+			}
+
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("invalid layer %s in image tarball %s: %w", layer, t.Path, err)
+		}
+	}
+
+	return nil
+}
+
+// uncompressed calls the provided function with the contents
+// of the named entry within the tarball without compression.
+func (t *Tarball) uncompressed(name string, fn func(io.Reader) error) error {
+	return t.Entry(name, func(r io.Reader) error {
+		br := bufio.NewReader(r)
+
+		// check if the entry is compressed
+		magic, err := br.Peek(2)
+		if err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+			gr, err := gzip.NewReader(br)
+			if err != nil {
+				return err
+			}
+			defer gr.Close()
+
+			return fn(gr)
+		}
+
+		return fn(br)
+	})
+}
+
+// Files outputs the contents of the files within the image after
+// applying every layer for the files accepted by the extract function.
+//
+// The extract function outputs the data captured for the file and
+// if the file should be included i.e. skipping unrelated files.
+func (t *Tarball) Files(extract func(*tar.Header, io.Reader) ([]byte, bool, error)) (map[string][]byte, error) {
+	logrus.Tracef("reading files from image tarball %s", t.Path)
+
+	files := make(map[string][]byte)
+
+	for i := range t.Manifest.Layers {
+		err := t.Layer(i, func(tr *tar.Reader) error {
+			for {
+				hdr, err := tr.Next()
+				if errors.Is(err, io.EOF) {
+					return nil
+				}
+
+				if err != nil {
+					return err
+				}
+
+				name := cleanPath(hdr.Name)
+				dir, base := path.Split(name)
+
+				// check if the entry removes the contents of a directory
+				if base == opaqueWhiteout {
+					removeFiles(files, strings.TrimSuffix(dir, "/"), false)
+
+					continue
+				}
+
+				// check if the entry removes a file
+				if strings.HasPrefix(base, whiteoutPrefix) {
+					removeFiles(files, path.Join(dir, strings.TrimPrefix(base, whiteoutPrefix)), true)
+
+					continue
+				}
+
+				// skip entries which are not regular files
+				if hdr.Typeflag != tar.TypeReg {
+					continue
+				}
+
+				data, ok, err := extract(hdr, tr)
+				if err != nil {
+					return fmt.Errorf("unable to read %s: %w", name, err)
+				}
+
+				// check if the file is overwritten by a file which is not included
+				if !ok {
+					delete(files, name)
+
+					continue
+				}
+
+				files[name] = data
+			}
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unable to read layer %s: %w", t.Manifest.Layers[i], err)
+		}
+	}
+
+	return files, nil
+}
+
+// removeFiles is a helper function to remove the files
+// within the directory and optionally the file itself.
+func removeFiles(files map[string][]byte, name string, self bool) {
+	for file := range files {
+		if (self && file == name) || strings.HasPrefix(file, name+"/") {
+			delete(files, file)
+		}
+	}
+}
+
+// cleanPath is a helper function to normalize the
+// path for an entry within a tarball i.e. "./etc/" -> "etc".
+func cleanPath(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
@@ -0,0 +1,268 @@
+// Copyright (c) 2022 Target Brands, Inc. All rights reserved.
+//
+// Use of this source code is governed by the LICENSE file in this repository.
+
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+// testFile represents a file within a layer for testing.
+type testFile struct {
+	Mode    int64
+	Name    string
+	Content string
+}
+
+// testTarball is a helper function to write an image tarball in
+// the format of "docker save" with the provided layers for testing.
+func testTarball(t *testing.T, path string, layers ...[]testFile) {
+	t.Helper()
+
+	// variable to store the entries for the tarball
+	entries := map[string][]byte{}
+
+	// variable to store the manifest for the tarball
+	manifest := map[string]interface{}{
+		"Config":   "config.json",
+		"RepoTags": []string{"index.docker.io/octocat/hello-world:latest"},
+	}
+
+	// variable to store the layers for the manifest
+	var names []string
+
+	// variable to store the digests of the uncompressed layers
+	diffIDs := []string{}
+
+	for i, files := range layers {
+		buf := new(bytes.Buffer)
+		tw := tar.NewWriter(buf)
+
+		for _, f := range files {
+			mode := f.Mode
+			if mode == 0 {
+				mode = 0644
+			}
+
+			_ = tw.WriteHeader(&tar.Header{
+				Typeflag: tar.TypeReg,
+				Name:     f.Name,
+				Mode:     mode,
+				Size:     int64(len(f.Content)),
+			})
+
+			_, _ = tw.Write([]byte(f.Content))
+		}
+
+		_ = tw.Close()
+
+		diffIDs = append(diffIDs, fmt.Sprintf("sha256:%x", sha256.Sum256(buf.Bytes())))
+
+		// compress every other layer to verify both are supported
+		if i%2 == 0 {
+			compressed := new(bytes.Buffer)
+
+			gw := gzip.NewWriter(compressed)
+			_, _ = gw.Write(buf.Bytes())
+			_ = gw.Close()
+
+			buf = compressed
+		}
+
+		name := fmt.Sprintf("layer%d/layer.tar", i)
+
+		entries[name] = buf.Bytes()
+		names = append(names, name)
+	}
+
+	manifest["Layers"] = names
+
+	config, _ := json.Marshal(map[string]interface{}{
+		"architecture": "amd64",
+		"os":           "linux",
+		"rootfs":       map[string]interface{}{"type": "layers", "diff_ids": diffIDs},
+	})
+
+	entries["config.json"] = config
+
+	data, _ := json.Marshal([]interface{}{manifest})
+
+	entries["manifest.json"] = data
+
+	buf := new(bytes.Buffer)
+	tw := tar.NewWriter(buf)
+
+	for _, name := range []string{"manifest.json", "config.json"} {
+		_ = tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(entries[name]))})
+		_, _ = tw.Write(entries[name])
+	}
+
+	for _, name := range names {
+		_ = tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(entries[name]))})
+		_, _ = tw.Write(entries[name])
+	}
+
+	_ = tw.Close()
+
+	err := afero.WriteFile(appFS, path, buf.Bytes(), 0644)
+	if err != nil {
+		t.Fatalf("unable to write tarball: %v", err)
+	}
+}
+
+func TestMakisu_openTarball(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	testTarball(t, "image.tar", []testFile{{Name: "etc/hostname", Content: "octocat"}})
+
+	got, err := openTarball("image.tar")
+	if err != nil {
+		t.Errorf("openTarball returned err: %v", err)
+	}
+
+	if got.Manifest.Config.String() != "config.json" {
+		t.Errorf("openTarball config is %s, want %s", got.Manifest.Config, "config.json")
+	}
+
+	if len(got.Manifest.Layers) != 1 {
+		t.Errorf("openTarball layers is %d, want %d", len(got.Manifest.Layers), 1)
+	}
+
+	if !bytes.Contains(got.Config, []byte("amd64")) {
+		t.Errorf("openTarball config is %s, want architecture", got.Config)
+	}
+}
+
+func TestMakisu_openTarball_Invalid(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	_ = afero.WriteFile(appFS, "invalid.tar", []byte("not a tarball"), 0644)
+
+	// setup tests
+	tests := []string{"missing.tar", "invalid.tar"}
+
+	// run tests
+	for _, test := range tests {
+		_, err := openTarball(test)
+		if err == nil {
+			t.Errorf("openTarball should have returned err for %s", test)
+		}
+	}
+}
+
+func TestMakisu_Tarball_Files(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	testTarball(t, "image.tar",
+		[]testFile{
+			{Name: "./etc/hostname", Content: "octocat"},
+			{Name: "etc/hosts", Content: "127.0.0.1 localhost"},
+			{Name: "var/cache/apk/a", Content: "a"},
+			{Name: "usr/bin/app", Content: "app"},
+		},
+		[]testFile{
+			{Name: "etc/.wh.hosts"},
+			{Name: "var/cache/apk/.wh..wh..opq"},
+			{Name: "usr/bin/app", Content: "app v2"},
+			{Name: "tmp/ignored", Content: "ignored"},
+		},
+	)
+
+	// setup types
+	want := map[string][]byte{
+		"etc/hostname": []byte("octocat"),
+		"usr/bin/app":  []byte("app v2"),
+	}
+
+	tarball, err := openTarball("image.tar")
+	if err != nil {
+		t.Errorf("openTarball returned err: %v", err)
+	}
+
+	got, err := tarball.Files(func(hdr *tar.Header, r io.Reader) ([]byte, bool, error) {
+		// skip the temporary files
+		if cleanPath(hdr.Name) == "tmp/ignored" {
+			return nil, false, nil
+		}
+
+		data, err := io.ReadAll(r)
+
+		return data, true, err
+	})
+	if err != nil {
+		t.Errorf("Files returned err: %v", err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Files is %s, want %s", got, want)
+	}
+}
+
+func TestMakisu_Tarball_Entry(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	testTarball(t, "image.tar", []testFile{{Name: "etc/hostname", Content: "octocat"}})
+
+	tarball, err := openTarball("image.tar")
+	if err != nil {
+		t.Errorf("openTarball returned err: %v", err)
+	}
+
+	var got []byte
+
+	err = tarball.Entry("./config.json", func(r io.Reader) error {
+		got, err = io.ReadAll(r)
+
+		return err
+	})
+	if err != nil {
+		t.Errorf("Entry returned err: %v", err)
+	}
+
+	if !bytes.Equal(got, tarball.Config) {
+		t.Errorf("Entry is %s, want %s", got, tarball.Config)
+	}
+
+	err = tarball.Entry("missing.json", func(r io.Reader) error { return nil })
+	if !errors.Is(err, errEntryNotFound) {
+		t.Errorf("Entry returned err %v, want %v", err, errEntryNotFound)
+	}
+}
+
+func TestMakisu_cleanPath(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "etc/hosts", want: "etc/hosts"},
+		{name: "./etc/hosts", want: "etc/hosts"},
+		{name: "/etc/hosts", want: "etc/hosts"},
+		{name: "etc/", want: "etc"},
+	}
+
+	// run tests
+	for _, test := range tests {
+		got := cleanPath(test.name)
+
+		if got != test.want {
+			t.Errorf("cleanPath is %s for %s, want %s", got, test.name, test.want)
+		}
+	}
+}
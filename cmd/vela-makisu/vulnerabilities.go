@@ -0,0 +1,536 @@
+// Copyright (c) 2022 Target Brands, Inc. All rights reserved.
+//
+// Use of this source code is governed by the LICENSE file in this repository.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+)
+
+// severities for the vulnerabilities from lowest to highest.
+const (
+	unknownSeverity  = "unknown"
+	lowSeverity      = "low"
+	mediumSeverity   = "medium"
+	highSeverity     = "high"
+	criticalSeverity = "critical"
+)
+
+// severityRank represents the order of the severities for vulnerabilities.
+var severityRank = map[string]int{
+	unknownSeverity:  0,
+	lowSeverity:      1,
+	mediumSeverity:   2,
+	highSeverity:     3,
+	criticalSeverity: 4,
+}
+
+// ecosystems represents the OSV ecosystem for the packages of each distro.
+var ecosystems = map[string]string{
+	"almalinux": "AlmaLinux",
+	"alpine":    "Alpine",
+	"debian":    "Debian",
+	"rocky":     "Rocky Linux",
+	"ubuntu":    "Ubuntu",
+}
+
+type (
+	// osvVulnerability represents a vulnerability in the OSV format.
+	//
+	// https://ossf.github.io/osv-schema/
+	osvVulnerability struct {
+		ID               string                 `json:"id"`
+		Aliases          []string               `json:"aliases"`
+		Summary          string                 `json:"summary"`
+		Affected         []osvAffected          `json:"affected"`
+		Severity         []osvSeverity          `json:"severity"`
+		DatabaseSpecific map[string]interface{} `json:"database_specific"`
+	}
+
+	// osvAffected represents the versions of a package affected by a vulnerability.
+	osvAffected struct {
+		Package struct {
+			Ecosystem string `json:"ecosystem"`
+			Name      string `json:"name"`
+		} `json:"package"`
+		Ranges   []osvRange `json:"ranges"`
+		Versions []string   `json:"versions"`
+	}
+
+	// osvRange represents a range of versions affected by a vulnerability.
+	osvRange struct {
+		Type   string     `json:"type"`
+		Events []osvEvent `json:"events"`
+	}
+
+	// osvEvent represents a version introducing or fixing a vulnerability.
+	osvEvent struct {
+		Introduced   string `json:"introduced,omitempty"`
+		Fixed        string `json:"fixed,omitempty"`
+		LastAffected string `json:"last_affected,omitempty"`
+	}
+
+	// osvSeverity represents the severity for a vulnerability.
+	osvSeverity struct {
+		Type  string `json:"type"`
+		Score string `json:"score"`
+	}
+
+	// Vulnerabilities represents the vulnerabilities from the
+	// database for the packages of a distro by package name.
+	Vulnerabilities map[string][]*osvVulnerability
+)
+
+// loadVulnerabilities is a helper function to read the vulnerabilities
+// affecting the packages of the provided distro from the directory
+// containing the database with one OSV document per file.
+func loadVulnerabilities(path string, distro *Distro) (Vulnerabilities, error) {
+	logrus.Tracef("loading vulnerability database from %s", path)
+
+	vulnerabilities := make(Vulnerabilities)
+
+	ecosystem, ok := ecosystems[distro.ID]
+	if !ok {
+		logrus.Warnf("unsupported distro %q for the vulnerability database - no packages are scanned", distro.ID)
+	}
+
+	// use custom filesystem which enables us to test
+	a := &afero.Afero{
+		Fs: appFS,
+	}
+
+	err := a.Walk(path, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// skip files which are not OSV documents
+		if info.IsDir() || filepath.Ext(file) != ".json" {
+			return nil
+		}
+
+		data, err := a.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		v := new(osvVulnerability)
+
+		err = json.Unmarshal(data, v)
+		if err != nil {
+			return fmt.Errorf("invalid vulnerability %s: %w", file, err)
+		}
+
+		// variable to store the packages already indexed for the vulnerability
+		indexed := make(map[string]bool)
+
+		for _, affected := range v.Affected {
+			// skip packages for other ecosystems
+			if !matchEcosystem(affected.Package.Ecosystem, ecosystem, distro) || indexed[affected.Package.Name] {
+				continue
+			}
+
+			indexed[affected.Package.Name] = true
+
+			vulnerabilities[affected.Package.Name] = append(vulnerabilities[affected.Package.Name], v)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to load vulnerability database: %w", err)
+	}
+
+	return vulnerabilities, nil
+}
+
+// matchEcosystem is a helper function to check if the provided OSV ecosystem
+// i.e. "Alpine:v3.15" or "Debian:11" applies to the packages of the distro.
+func matchEcosystem(value, ecosystem string, distro *Distro) bool {
+	name, release, ok := cut(value, ":")
+
+	// verify the ecosystem matches the distro
+	if len(ecosystem) == 0 || name != ecosystem {
+		return false
+	}
+
+	// check if the ecosystem applies to every release
+	if !ok || len(distro.VersionID) == 0 {
+		return true
+	}
+
+	// remove the prefix and qualifiers for the release i.e. "v3.15" or "22.04:LTS"
+	release, _, _ = cut(strings.TrimPrefix(release, "v"), ":")
+
+	// match the release against the major and minor version i.e. "3.15" for "3.15.4"
+	return distro.VersionID == release || strings.HasPrefix(distro.VersionID, release+".")
+}
+
+// Affects checks if the provided version of the package
+// for the ecosystem is affected by the vulnerability and
+// outputs the version fixing the vulnerability if known.
+func (v *osvVulnerability) Affects(ecosystem string, distro *Distro, pkg *Package) (bool, string) {
+	for _, affected := range v.Affected {
+		// skip other packages
+		if affected.Package.Name != pkg.SourceName() || !matchEcosystem(affected.Package.Ecosystem, ecosystem, distro) {
+			continue
+		}
+
+		for _, version := range affected.Versions {
+			if version == pkg.Version {
+				return true, ""
+			}
+		}
+
+		for _, r := range affected.Ranges {
+			// skip ranges which are not comparable with the package versions
+			if r.Type != "ECOSYSTEM" {
+				continue
+			}
+
+			if vulnerable, fixed := r.affects(pkg); vulnerable {
+				return true, fixed
+			}
+		}
+	}
+
+	return false, ""
+}
+
+// affects is a helper function to evaluate the events of the
+// range in order for the provided version of the package.
+func (r *osvRange) affects(pkg *Package) (bool, string) {
+	// variable to store if the version is within the range
+	vulnerable := false
+
+	// variable to store the version fixing the vulnerability
+	fixed := ""
+
+	for _, event := range r.Events {
+		switch {
+		case len(event.Introduced) > 0:
+			if event.Introduced == "0" || compareVersions(pkg.Type, pkg.Version, event.Introduced) >= 0 {
+				vulnerable = true
+			}
+		case len(event.Fixed) > 0:
+			if compareVersions(pkg.Type, pkg.Version, event.Fixed) >= 0 {
+				vulnerable = false
+
+				continue
+			}
+
+			// capture the version fixing the vulnerability
+			if vulnerable && len(fixed) == 0 {
+				fixed = event.Fixed
+			}
+		case len(event.LastAffected) > 0:
+			if compareVersions(pkg.Type, pkg.Version, event.LastAffected) > 0 {
+				vulnerable = false
+			}
+		}
+	}
+
+	return vulnerable, fixed
+}
+
+// Rating outputs the severity for the vulnerability from the database
+// specific severity or the base score of a CVSS v3 vector.
+func (v *osvVulnerability) Rating() string {
+	// check if the database provides a severity i.e. "HIGH" or "MODERATE"
+	if s, ok := v.DatabaseSpecific["severity"].(string); ok {
+		return normalizeSeverity(s)
+	}
+
+	// variable to store the highest severity
+	rating := unknownSeverity
+
+	for _, s := range v.Severity {
+		// variable to store the severity for the score
+		severity := normalizeSeverity(s.Score)
+
+		// check if the score is a CVSS vector
+		if strings.HasPrefix(s.Score, "CVSS:3") {
+			score, err := cvssScore(s.Score)
+			if err != nil {
+				logrus.Warnf("invalid severity for vulnerability %s: %v", v.ID, err)
+
+				continue
+			}
+
+			severity = cvssRating(score)
+		}
+
+		if severityRank[severity] > severityRank[rating] {
+			rating = severity
+		}
+	}
+
+	return rating
+}
+
+// normalizeSeverity is a helper function to return the
+// severity for the provided rating from a database.
+func normalizeSeverity(value string) string {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "critical":
+		return criticalSeverity
+	case "high", "important":
+		return highSeverity
+	case "medium", "moderate":
+		return mediumSeverity
+	case "low", "negligible":
+		return lowSeverity
+	default:
+		return unknownSeverity
+	}
+}
+
+// cvssScore is a helper function to compute the base
+// score for the provided CVSS v3 vector.
+//
+// https://www.first.org/cvss/v3.1/specification-document#7-1-Base-Metrics-Equations
+func cvssScore(vector string) (float64, error) {
+	// variable to store the metrics for the vector
+	metrics := make(map[string]string)
+
+	for _, part := range strings.Split(vector, "/")[1:] {
+		key, value, ok := cut(part, ":")
+		if !ok {
+			return 0, fmt.Errorf("invalid CVSS vector %s", vector)
+		}
+
+		metrics[key] = value
+	}
+
+	// weights for the base metrics
+	weights := map[string]map[string]float64{
+		"AV": {"N": 0.85, "A": 0.62, "L": 0.55, "P": 0.2},
+		"AC": {"L": 0.77, "H": 0.44},
+		"PR": {"N": 0.85, "L": 0.62, "H": 0.27},
+		"UI": {"N": 0.85, "R": 0.62},
+		"C":  {"H": 0.56, "L": 0.22, "N": 0},
+		"I":  {"H": 0.56, "L": 0.22, "N": 0},
+		"A":  {"H": 0.56, "L": 0.22, "N": 0},
+	}
+
+	// variable to store the weight for each metric
+	w := make(map[string]float64)
+
+	for metric, values := range weights {
+		weight, ok := values[metrics[metric]]
+		if !ok {
+			return 0, fmt.Errorf("invalid CVSS vector %s: missing %s", vector, metric)
+		}
+
+		w[metric] = weight
+	}
+
+	changed := metrics["S"] == "C"
+
+	// privileges required are weighted higher when the scope changes
+	if changed {
+		switch metrics["PR"] {
+		case "L":
+			w["PR"] = 0.68
+		case "H":
+			w["PR"] = 0.5
+		}
+	}
+
+	iss := 1 - ((1 - w["C"]) * (1 - w["I"]) * (1 - w["A"]))
+
+	impact := 6.42 * iss
+	if changed {
+		impact = 7.52*(iss-0.029) - 3.25*math.Pow(iss-0.02, 15)
+	}
+
+	exploitability := 8.22 * w["AV"] * w["AC"] * w["PR"] * w["UI"]
+
+	if impact <= 0 {
+		return 0, nil
+	}
+
+	if changed {
+		return roundUp(math.Min(1.08*(impact+exploitability), 10)), nil
+	}
+
+	return roundUp(math.Min(impact+exploitability, 10)), nil
+}
+
+// roundUp is a helper function to round the provided
+// value up to one decimal as defined for CVSS v3.1.
+func roundUp(value float64) float64 {
+	i := int(math.Round(value * 100000))
+
+	if i%10000 == 0 {
+		return float64(i) / 100000.0
+	}
+
+	return (math.Floor(float64(i)/10000.0) + 1) / 10.0
+}
+
+// cvssRating is a helper function to return the
+// severity for the provided CVSS v3 base score.
+func cvssRating(score float64) string {
+	switch {
+	case score >= 9:
+		return criticalSeverity
+	case score >= 7:
+		return highSeverity
+	case score >= 4:
+		return mediumSeverity
+	case score > 0:
+		return lowSeverity
+	default:
+		return unknownSeverity
+	}
+}
+
+// compareVersions is a helper function to compare the provided versions
+// of a package with the rules of the package manager for the type.
+//
+// The output is negative when a is lower than b, zero when
+// they are equal and positive when a is higher than b.
+func compareVersions(kind, a, b string) int {
+	// check if the versions are for an rpm package
+	if kind == rpmPackage {
+		return compareRpmVersions(a, b)
+	}
+
+	// check if the versions are for an apk package
+	if kind == apkPackage {
+		// pre-releases sort before the release i.e. "1.0_rc1" before "1.0"
+		r := strings.NewReplacer("_alpha", "~alpha", "_beta", "~beta", "_pre", "~pre", "_rc", "~rc")
+
+		a, b = r.Replace(a), r.Replace(b)
+	}
+
+	return compareDebianVersions(a, b)
+}
+
+// compareDebianVersions is a helper function to compare the provided
+// versions with the rules of dpkg i.e. "[epoch:]upstream[-revision]".
+//
+// https://www.debian.org/doc/debian-policy/ch-controlfields.html#version
+func compareDebianVersions(a, b string) int {
+	// split the versions into the epoch, upstream version and revision
+	epochA, upstreamA, revisionA := splitDebianVersion(a)
+	epochB, upstreamB, revisionB := splitDebianVersion(b)
+
+	if epochA != epochB {
+		return epochA - epochB
+	}
+
+	if c := compareDebianPart(upstreamA, upstreamB); c != 0 {
+		return c
+	}
+
+	return compareDebianPart(revisionA, revisionB)
+}
+
+// splitDebianVersion is a helper function to split the provided
+// version into the epoch, upstream version and revision.
+func splitDebianVersion(version string) (int, string, string) {
+	// variable to store the epoch for the version
+	epoch := 0
+
+	if e, rest, ok := cut(version, ":"); ok {
+		epoch, _ = strconv.Atoi(e)
+		version = rest
+	}
+
+	// the revision follows the last hyphen of the version
+	if i := strings.LastIndex(version, "-"); i >= 0 {
+		return epoch, version[:i], version[i+1:]
+	}
+
+	return epoch, version, ""
+}
+
+// compareDebianPart is a helper function to compare the provided
+// parts of a version with alternating non-digit and digit segments.
+func compareDebianPart(a, b string) int {
+	for len(a) > 0 || len(b) > 0 {
+		// compare the leading non-digit segments
+		i, j := 0, 0
+
+		for (i < len(a) && !isDigit(a[i])) || (j < len(b) && !isDigit(b[j])) {
+			ca, cb := debianOrder(a, i), debianOrder(b, j)
+			if ca != cb {
+				return ca - cb
+			}
+
+			if i < len(a) && !isDigit(a[i]) {
+				i++
+			}
+
+			if j < len(b) && !isDigit(b[j]) {
+				j++
+			}
+		}
+
+		a, b = a[i:], b[j:]
+
+		// compare the leading digit segments
+		i, j = 0, 0
+
+		for i < len(a) && isDigit(a[i]) {
+			i++
+		}
+
+		for j < len(b) && isDigit(b[j]) {
+			j++
+		}
+
+		na, nb := strings.TrimLeft(a[:i], "0"), strings.TrimLeft(b[:j], "0")
+
+		// longer numbers without leading zeros are higher
+		if len(na) != len(nb) {
+			return len(na) - len(nb)
+		}
+
+		if c := strings.Compare(na, nb); c != 0 {
+			return c
+		}
+
+		a, b = a[i:], b[j:]
+	}
+
+	return 0
+}
+
+// debianOrder is a helper function to return the weight for the character
+// at the index of the provided non-digit segment of a version where "~"
+// sorts before the end of the segment and letters sort before symbols.
+func debianOrder(s string, i int) int {
+	// check if the segment ended
+	if i >= len(s) || isDigit(s[i]) {
+		return 0
+	}
+
+	c := s[i]
+
+	switch {
+	case c == '~':
+		return -1
+	case (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z'):
+		return int(c)
+	default:
+		return int(c) + 256
+	}
+}
+
+// isDigit is a helper function to check if the provided character is a digit.
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
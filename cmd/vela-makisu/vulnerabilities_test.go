@@ -0,0 +1,239 @@
+// Copyright (c) 2022 Target Brands, Inc. All rights reserved.
+//
+// Use of this source code is governed by the LICENSE file in this repository.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+// testVulnerability represents a vulnerability in the OSV format for testing.
+const testVulnerability = `{
+  "id": "ALPINE-CVE-2022-0778",
+  "aliases": ["CVE-2022-0778"],
+  "summary": "infinite loop in BN_mod_sqrt",
+  "affected": [
+    {
+      "package": {"ecosystem": "Alpine:v3.15", "name": "openssl"},
+      "ranges": [{"type": "ECOSYSTEM", "events": [{"introduced": "0"}, {"fixed": "1.1.1n-r0"}]}]
+    },
+    {
+      "package": {"ecosystem": "Alpine:v3.14", "name": "openssl"},
+      "ranges": [{"type": "ECOSYSTEM", "events": [{"introduced": "0"}, {"fixed": "1.1.1m-r0"}]}]
+    }
+  ],
+  "severity": [{"type": "CVSS_V3", "score": "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:N/I:N/A:H"}]
+}`
+
+func TestMakisu_loadVulnerabilities(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	_ = afero.WriteFile(appFS, "/vela/vulndb/ALPINE-CVE-2022-0778.json", []byte(testVulnerability), 0644)
+	_ = afero.WriteFile(appFS, "/vela/vulndb/README.md", []byte("# vulnerabilities"), 0644)
+
+	// setup types
+	distro := &Distro{ID: "alpine", VersionID: "3.15.4"}
+
+	got, err := loadVulnerabilities("/vela/vulndb", distro)
+	if err != nil {
+		t.Errorf("loadVulnerabilities returned err: %v", err)
+	}
+
+	if len(got["openssl"]) != 1 {
+		t.Fatalf("loadVulnerabilities is %v, want openssl", got)
+	}
+
+	// setup tests
+	tests := []struct {
+		pkg      *Package
+		affected bool
+		fixed    string
+	}{
+		{
+			pkg:      &Package{Name: "libcrypto1.1", Source: "openssl", Type: apkPackage, Version: "1.1.1l-r7"},
+			affected: true,
+			fixed:    "1.1.1n-r0",
+		},
+		{
+			pkg:      &Package{Name: "libcrypto1.1", Source: "openssl", Type: apkPackage, Version: "1.1.1n-r0"},
+			affected: false,
+		},
+		{
+			pkg:      &Package{Name: "musl", Type: apkPackage, Version: "1.2.2-r7"},
+			affected: false,
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		affected, fixed := got["openssl"][0].Affects("Alpine", distro, test.pkg)
+
+		if affected != test.affected || fixed != test.fixed {
+			t.Errorf("Affects is %v %s for %s, want %v %s", affected, fixed, test.pkg.Version, test.affected, test.fixed)
+		}
+	}
+
+	if got["openssl"][0].Rating() != highSeverity {
+		t.Errorf("Rating is %s, want %s", got["openssl"][0].Rating(), highSeverity)
+	}
+}
+
+func TestMakisu_loadVulnerabilities_Invalid(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	_ = afero.WriteFile(appFS, "/vela/vulndb/invalid.json", []byte("not json"), 0644)
+
+	_, err := loadVulnerabilities("/vela/vulndb", &Distro{ID: "alpine"})
+	if err == nil {
+		t.Errorf("loadVulnerabilities should have returned err")
+	}
+}
+
+func TestMakisu_matchEcosystem(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		value  string
+		distro *Distro
+		want   bool
+	}{
+		{value: "Alpine:v3.15", distro: &Distro{ID: "alpine", VersionID: "3.15.4"}, want: true},
+		{value: "Alpine:v3.14", distro: &Distro{ID: "alpine", VersionID: "3.15.4"}, want: false},
+		{value: "Debian:11", distro: &Distro{ID: "debian", VersionID: "11"}, want: true},
+		{value: "Debian", distro: &Distro{ID: "debian", VersionID: "11"}, want: true},
+		{value: "Ubuntu:22.04:LTS", distro: &Distro{ID: "ubuntu", VersionID: "22.04"}, want: true},
+		{value: "Debian:11", distro: &Distro{ID: "alpine", VersionID: "3.15.4"}, want: false},
+	}
+
+	// run tests
+	for _, test := range tests {
+		got := matchEcosystem(test.value, ecosystems[test.distro.ID], test.distro)
+
+		if got != test.want {
+			t.Errorf("matchEcosystem is %v for %s, want %v", got, test.value, test.want)
+		}
+	}
+}
+
+func TestMakisu_osvVulnerability_Rating(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		vulnerability *osvVulnerability
+		want          string
+	}{
+		{
+			vulnerability: &osvVulnerability{DatabaseSpecific: map[string]interface{}{"severity": "MODERATE"}},
+			want:          mediumSeverity,
+		},
+		{
+			vulnerability: &osvVulnerability{Severity: []osvSeverity{{Type: "Ubuntu", Score: "low"}}},
+			want:          lowSeverity,
+		},
+		{
+			vulnerability: &osvVulnerability{
+				Severity: []osvSeverity{{Type: "CVSS_V3", Score: "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H"}},
+			},
+			want: criticalSeverity,
+		},
+		{
+			vulnerability: &osvVulnerability{},
+			want:          unknownSeverity,
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		got := test.vulnerability.Rating()
+
+		if got != test.want {
+			t.Errorf("Rating is %s, want %s", got, test.want)
+		}
+	}
+}
+
+func TestMakisu_cvssScore(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		vector  string
+		want    float64
+		failure bool
+	}{
+		{vector: "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H", want: 9.8},
+		{vector: "CVSS:3.1/AV:N/AC:L/PR:N/UI:R/S:C/C:L/I:L/A:N", want: 6.1},
+		{vector: "CVSS:3.1/AV:L/AC:L/PR:L/UI:N/S:U/C:H/I:N/A:N", want: 5.5},
+		{vector: "CVSS:3.1/AV:N/AC:L/PR:L/UI:N/S:C/C:H/I:H/A:H", want: 9.9},
+		{vector: "CVSS:3.1/AV:N/AC:H/PR:N/UI:N/S:U/C:N/I:N/A:N", want: 0},
+		{vector: "CVSS:3.1/AV:N/AC:L", failure: true},
+	}
+
+	// run tests
+	for _, test := range tests {
+		got, err := cvssScore(test.vector)
+
+		if test.failure {
+			if err == nil {
+				t.Errorf("cvssScore should have returned err for %s", test.vector)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("cvssScore returned err for %s: %v", test.vector, err)
+		}
+
+		if got != test.want {
+			t.Errorf("cvssScore is %v for %s, want %v", got, test.vector, test.want)
+		}
+	}
+}
+
+func TestMakisu_compareVersions(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		kind string
+		a    string
+		b    string
+		want int
+	}{
+		{kind: debPackage, a: "1.0-1", b: "1.0-2", want: -1},
+		{kind: debPackage, a: "1:0.9", b: "2.0", want: 1},
+		{kind: debPackage, a: "1.0~rc1", b: "1.0", want: -1},
+		{kind: debPackage, a: "2.31-13+deb11u3", b: "2.31-13", want: 1},
+		{kind: debPackage, a: "1.10", b: "1.9", want: 1},
+		{kind: debPackage, a: "1.01", b: "1.1", want: 0},
+		{kind: apkPackage, a: "1.2.2-r7", b: "1.2.3-r0", want: -1},
+		{kind: apkPackage, a: "1.0_rc1", b: "1.0", want: -1},
+		{kind: apkPackage, a: "1.0_p1", b: "1.0", want: 1},
+		{kind: apkPackage, a: "1.1.1n-r0", b: "1.1.1l-r0", want: 1},
+		{kind: rpmPackage, a: "1:1.1.1k-6.el8_5", b: "1:1.1.1k-7.el8_6", want: -1},
+		{kind: rpmPackage, a: "1:1.0-1", b: "2.0-1", want: 1},
+		{kind: rpmPackage, a: "1.0~rc1-1", b: "1.0-1", want: -1},
+		{kind: rpmPackage, a: "1.0^git1-1", b: "1.0-1", want: 1},
+		{kind: rpmPackage, a: "1.0a-1", b: "1.0-1", want: 1},
+		{kind: rpmPackage, a: "1.0.1-1", b: "1.0a-1", want: 1},
+		{kind: rpmPackage, a: "2.28-189.el8", b: "2.28-189.1.el8", want: -1},
+		{kind: rpmPackage, a: "1.010-1", b: "1.10-1", want: 0},
+	}
+
+	// run tests
+	for _, test := range tests {
+		got := compareVersions(test.kind, test.a, test.b)
+
+		// normalize the result to the sign of the comparison
+		switch {
+		case got < 0:
+			got = -1
+		case got > 0:
+			got = 1
+		}
+
+		if got != test.want {
+			t.Errorf("compareVersions is %d for %s and %s, want %d", got, test.a, test.b, test.want)
+		}
+	}
+}